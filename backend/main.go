@@ -1,31 +1,130 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/peterzdhuang/rplace/backend/server"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
-	go server.HubInstance.Run()
+	if err := server.Configure(server.LoadConfig()); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := server.RestoreSnapshot(); err != nil {
+		slog.Error("Failed to restore board snapshot", "error", err)
+		os.Exit(1)
+	}
+	if err := server.RestoreEventLog(); err != nil {
+		slog.Error("Failed to restore event log", "error", err)
+		os.Exit(1)
+	}
+	server.StartHub()
+	server.StartSnapshots()
 
 	r := gin.Default()
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+	if err := r.SetTrustedProxies(server.TrustedProxies()); err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	r.Use(server.CORS())
+	r.GET("/ws", server.InitWebSocket())
+	r.GET("/board", server.GetBoard())
+	r.GET("/board.png", server.GetBoardPNG())
+	r.GET("/board/heat.png", server.GetHeatPNG())
+	r.GET("/board/raw", server.GetBoardRaw())
+	r.GET("/board/colors", server.GetColors())
+	r.GET("/board/changes", server.GetChanges())
+	r.GET("/board/at", server.GetBoardAt())
+	r.GET("/timelapse.gif", server.GetTimelapse())
+	r.GET("/pixel", server.GetPixel())
+	r.POST("/pixel", server.PostPixel())
+	r.GET("/cooldown", server.GetCooldown())
+	r.GET("/users", server.GetUsers())
+	r.GET("/metrics", server.Metrics())
+	r.GET("/stats", server.GetStats())
+	r.GET("/leaderboard", server.GetLeaderboard())
+	r.GET("/healthz", server.Healthz())
+	r.GET("/readyz", server.Readyz())
+
+	admin := r.Group("/admin", server.AdminAuth())
+	admin.POST("/reset", server.ResetBoard())
+	admin.POST("/resize", server.ResizeBoard())
+	admin.GET("/export", server.ExportBoard())
+	admin.POST("/import", server.ImportBoard())
+	admin.POST("/lock", server.LockRegion())
+	admin.POST("/unlock", server.UnlockRegion())
+	admin.POST("/freeze", server.FreezeBoard())
+	admin.POST("/unfreeze", server.UnfreezeBoard())
+	admin.POST("/region/fill", server.StampImage())
+	admin.POST("/message", server.SendNotice())
+	admin.GET("/bans", server.GetBans())
+	admin.POST("/ban", server.BanIdentity())
+	admin.POST("/unban", server.UnbanIdentity())
+	admin.POST("/drain", server.DrainServer())
+	admin.GET("/trusted", server.GetTrustedUsers())
+	admin.PUT("/trusted", server.SetTrustedUsers())
+
+	srv := &http.Server{
+		Addr:    server.ListenAddr(),
+		Handler: r,
+	}
+	cert, key := server.TLSFiles()
+	go func() {
+		slog.Info("Server starting", "addr", srv.Addr, "tls", cert != "")
+		var err error
+		if cert != "" {
+			err = srv.ListenAndServeTLS(cert, key)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Server error", "error", err)
+			os.Exit(1)
 		}
+	}()
 
-		c.Next()
-	})
-	fmt.Println("Server starting on :8080")
-	r.GET("/ws", server.InitWebSocket())
-	r.Run(":8000")
+	var redirect *http.Server
+	if addr := server.RedirectAddr(); addr != "" {
+		redirect = &http.Server{
+			Addr:    addr,
+			Handler: server.RedirectHTTPS(),
+		}
+		go func() {
+			slog.Info("HTTP redirect starting", "addr", addr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("HTTP redirect error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	slog.Info("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server shutdown error", "error", err)
+	}
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			slog.Error("HTTP redirect shutdown error", "error", err)
+		}
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Hub shutdown error", "error", err)
+	}
 }
@@ -0,0 +1,29 @@
+package server
+
+// AckMessage confirms to a client that its placement with request id ReqID
+// was applied as sequence number Seq. For fill and draw operations Seq is
+// that of the last cell painted. Placements without a reqId are not
+// acknowledged; rejections of ones with a reqId come as a "nack" error.
+type AckMessage struct {
+	Type  string `json:"type"`
+	ReqID string `json:"reqId"`
+	Seq   uint64 `json:"seq"`
+}
+
+// ack confirms message to its sender if it carries a request id.
+func (h *Hub) ack(message Update, seq uint64) {
+	if message.ReqID == "" {
+		return
+	}
+	h.sendTo(message.SenderUUID, AckMessage{Type: "ack", ReqID: message.ReqID, Seq: seq})
+}
+
+// nack turns reply into the rejection of the request reqID, if the client
+// gave one.
+func nack(reply ErrorMessage, reqID string) ErrorMessage {
+	if reqID != "" {
+		reply.Type = "nack"
+		reply.ReqID = reqID
+	}
+	return reply
+}
@@ -0,0 +1,38 @@
+package server
+
+import "testing"
+
+func TestAcknowledgements(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+
+	send(t, conn, map[string]any{"type": "update", "x": 0, "y": 0, "color": "#000000", "reqId": "r1"})
+	var ack AckMessage
+	readType(t, conn, "ack", &ack)
+	if ack.ReqID != "r1" || ack.Seq != 1 {
+		t.Fatalf("got %+v, want the ack of r1 at seq 1", ack)
+	}
+
+	// Placements without a reqId are not acknowledged.
+	send(t, conn, map[string]any{"type": "update", "x": 1, "y": 0, "color": "#000000"})
+	send(t, conn, map[string]any{"type": "update", "x": 2, "y": 0, "color": "#000000", "reqId": "r2"})
+	readType(t, conn, "ack", &ack)
+	if ack.ReqID != "r2" || ack.Seq != 3 {
+		t.Fatalf("got %+v, want the ack of r2 at seq 3", ack)
+	}
+
+	send(t, conn, map[string]any{"type": "update", "x": 16, "y": 0, "color": "#000000", "reqId": "r3"})
+	var nack ErrorMessage
+	readType(t, conn, "nack", &nack)
+	if nack.ReqID != "r3" || nack.Code != "out_of_bounds" {
+		t.Fatalf("got %+v, want the nack of r3 for out_of_bounds", nack)
+	}
+
+	// Without a reqId a rejection is a plain error.
+	send(t, conn, map[string]any{"type": "update", "x": 16, "y": 0, "color": "#000000"})
+	var plain ErrorMessage
+	readType(t, conn, "error", &plain)
+	if plain.ReqID != "" || plain.Code != "out_of_bounds" {
+		t.Fatalf("got %+v", plain)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// lastActive returns the LastActive /users reports for username.
+func lastActive(t *testing.T, srv *httptest.Server, username string) time.Time {
+	t.Helper()
+	var users []UserInfo
+	getJSON(t, srv, "/users", &users)
+	for _, u := range users {
+		if u.Username == username {
+			return u.LastActive
+		}
+	}
+	t.Fatalf("/users does not list %s", username)
+	return time.Time{}
+}
+
+func TestMessagesAdvanceLastActive(t *testing.T) {
+	srv := startServer(t, nil)
+	var clock *fakeClock
+	HubInstance.do(func() { clock = useFakeClock(HubInstance) })
+	start := clock.Now()
+
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+	if got := lastActive(t, srv, "a"); !got.Equal(start) {
+		t.Fatalf("LastActive on connect is %v, want %v", got, start)
+	}
+
+	clock.advance(time.Minute)
+	place(t, conn, 0, 0, "#000000")
+	if got := lastActive(t, srv, "a"); !got.Equal(start.Add(time.Minute)) {
+		t.Fatalf("LastActive after a message is %v, want %v", got, start.Add(time.Minute))
+	}
+
+	// Pongs count too. They get no reply, so wait for the change.
+	clock.advance(time.Minute)
+	if err := conn.WriteControl(websocket.PongMessage, nil, time.Now().Add(readTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	want := start.Add(2 * time.Minute)
+	for deadline := time.Now().Add(readTimeout); !lastActive(t, srv, "a").Equal(want); {
+		if time.Now().After(deadline) {
+			t.Fatalf("LastActive after a pong is %v, want %v", lastActive(t, srv, "a"), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminAuth only lets through requests carrying the configured admin token
+// as a bearer token. Admin endpoints are disabled when no token is set.
+// Every admin request is audited.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer auditAdmin(c)
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if config.AdminToken == "" || !ok ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func ResetBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		var err error
+		if !hub.do(func() { err = hub.reset() }) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("Board reset", "room", hub.name, "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"seq": hub.changes.seq()})
+	}
+}
+
+// do runs fn on the hub's Run loop and waits for it to finish. It reports
+// false if the hub has stopped.
+func (h *Hub) do(fn func()) bool {
+	done := make(chan struct{})
+	select {
+	case h.commands <- func() {
+		fn()
+		close(done)
+	}:
+	case <-h.done:
+		return false
+	}
+	<-done
+	return true
+}
+
+// reset clears the board and sends every client the empty board.
+func (h *Hub) reset() error {
+	if err := h.store.Reset(); err != nil {
+		return err
+	}
+	h.resetClients()
+	return nil
+}
+
+// resetClients brings the hub and its clients up to date with a board that
+// was just cleared, here or by another instance sharing the store. Pending
+// batched updates and votes predate the reset and are dropped, and
+// milestones may be announced again.
+func (h *Hub) resetClients() {
+	h.batch.take()
+	h.milestone = 0
+	h.votes = make(map[cell]map[Pixel]map[uuid.UUID]time.Time)
+	seq := h.changes.invalidate()
+	h.record(Event{Type: "reset", Seq: seq, Ts: h.clock()})
+	h.broadcastMessage(InitBoardState{
+		Type:   "init",
+		Seq:    seq,
+		Pixels: h.store.Snapshot(),
+	}, uuid.Nil)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResetBoard(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	place(t, conn, 1, 1, "#000000")
+
+	for _, header := range []http.Header{nil, {"Authorization": {"Bearer wrong"}}} {
+		if status := postJSON(t, srv, "/admin/reset", nil, header, nil); status != http.StatusUnauthorized {
+			t.Fatalf("reset with %v: %d, want 401", header, status)
+		}
+	}
+	if p, _ := HubInstance.store.Get(1, 1); p == config.FillColor {
+		t.Fatal("unauthorized reset cleared the board")
+	}
+
+	if status := postJSON(t, srv, "/admin/reset", nil, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("authorized reset: %d", status)
+	}
+	var init InitBoardState
+	readType(t, conn, "init", &init)
+	if init.Pixels[1][1] != config.FillColor {
+		t.Fatalf("clients were sent %v at (1, 1) after the reset", init.Pixels[1][1])
+	}
+	if p, meta := HubInstance.store.Get(1, 1); p != config.FillColor || meta.Username != "" {
+		t.Fatalf("board has %v by %q after the reset", p, meta.Username)
+	}
+}
+
+func TestResetReachesOtherInstances(t *testing.T) {
+	redis := startRedis(t)
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Store = "redis"
+		cfg.RedisAddr = redis.addr()
+	})
+	peer, listener := startPeer(t, redis)
+	place(t, dial(t, srv, "username=a"), 1, 1, "#000000")
+	receive[Update](t, listener)
+
+	if status := postJSON(t, srv, "/admin/reset", nil, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("reset: %d", status)
+	}
+	init := receive[InitBoardState](t, listener)
+	if init.Pixels[1][1] != config.FillColor {
+		t.Fatalf("peer clients were sent %v at (1, 1) after the reset", init.Pixels[1][1])
+	}
+	if p, _ := peer.store.Get(1, 1); p != config.FillColor {
+		t.Fatalf("peer store still has %v at (1, 1)", p)
+	}
+}
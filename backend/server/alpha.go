@@ -0,0 +1,41 @@
+package server
+
+import "encoding/json"
+
+// UnmarshalJSON decodes a pixel, treating a missing "a" as fully opaque so
+// clients that only send r, g and b keep working.
+func (p *Pixel) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		R uint8  `json:"r"`
+		G uint8  `json:"g"`
+		B uint8  `json:"b"`
+		A *uint8 `json:"a"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Pixel{R: raw.R, G: raw.G, B: raw.B, A: 255}
+	if raw.A != nil {
+		p.A = *raw.A
+	}
+	return nil
+}
+
+// blendOver composites src over dst using src's alpha.
+func blendOver(src, dst Pixel) Pixel {
+	sa := uint32(src.A)
+	da := uint32(dst.A) * (255 - sa) / 255
+	outA := sa + da
+	if outA == 0 {
+		return Pixel{}
+	}
+	mix := func(s, d uint8) uint8 {
+		return uint8((uint32(s)*sa + uint32(d)*da) / outA)
+	}
+	return Pixel{
+		R: mix(src.R, dst.R),
+		G: mix(src.G, dst.G),
+		B: mix(src.B, dst.B),
+		A: uint8(outA),
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditQueueSize bounds the events waiting to be posted to a webhook.
+// Events beyond it are dropped rather than holding up the hub.
+const auditQueueSize = 1024
+
+// webhookTimeout bounds each request to the audit webhook.
+const webhookTimeout = 5 * time.Second
+
+// AuditEvent is one entry of the audit trail. Type is "connect",
+// "disconnect", "placement" or "admin"; the other fields are set when they
+// apply to it.
+type AuditEvent struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Room     string    `json:"room,omitempty"`
+	ClientID string    `json:"clientId,omitempty"`
+	Username string    `json:"username,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	X        *int      `json:"x,omitempty"`
+	Y        *int      `json:"y,omitempty"`
+	Color    string    `json:"color,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Status   int       `json:"status,omitempty"`
+}
+
+// AuditSink receives audit events. Record must not block for long, since
+// it is called from the hub's Run loop.
+type AuditSink interface {
+	Record(e AuditEvent) error
+}
+
+// auditSink is the configured sink, or nil when auditing is disabled.
+var auditSink AuditSink
+
+// audit records e with the configured sink, if any.
+func audit(e AuditEvent) {
+	if auditSink == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if err := auditSink.Record(e); err != nil {
+		logger.Error("Failed to record audit event", "type", e.Type, "error", err)
+	}
+}
+
+// newAuditSink builds the sink selected by cfg.AuditSink.
+func newAuditSink(cfg Config) (AuditSink, error) {
+	switch cfg.AuditSink {
+	case "":
+		return nil, nil
+	case "stdout":
+		return NewJSONAuditSink(os.Stdout), nil
+	case "file":
+		f, err := os.OpenFile(cfg.AuditPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening audit log: %w", err)
+		}
+		return NewJSONAuditSink(f), nil
+	case "webhook":
+		if cfg.AuditURL == "" {
+			return nil, fmt.Errorf("audit webhook needs a URL")
+		}
+		return NewWebhookAuditSink(cfg.AuditURL), nil
+	}
+	return nil, fmt.Errorf("unknown audit sink %q", cfg.AuditSink)
+}
+
+// JSONAuditSink writes each event to a writer as a JSON line.
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
+
+func (s *JSONAuditSink) Record(e AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(e)
+}
+
+// WebhookAuditSink posts each event as JSON to a URL in the background.
+type WebhookAuditSink struct {
+	url    string
+	client *http.Client
+	events chan AuditEvent
+}
+
+func NewWebhookAuditSink(url string) *WebhookAuditSink {
+	s := &WebhookAuditSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		events: make(chan AuditEvent, auditQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+func (s *WebhookAuditSink) Record(e AuditEvent) error {
+	select {
+	case s.events <- e:
+		return nil
+	default:
+		return fmt.Errorf("audit webhook queue is full, dropping event")
+	}
+}
+
+func (s *WebhookAuditSink) run() {
+	for e := range s.events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			logger.Error("Failed to encode audit event", "error", err)
+			continue
+		}
+		resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			logger.Error("Failed to post audit event", "error", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			logger.Error("Audit webhook rejected event", "status", resp.StatusCode)
+		}
+	}
+}
+
+// auditAdmin records an admin request, including refused ones, once it has
+// been handled.
+func auditAdmin(c *gin.Context) {
+	audit(AuditEvent{
+		Type:   "admin",
+		Room:   c.DefaultQuery("room", defaultRoom),
+		IP:     c.ClientIP(),
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+		Status: c.Writer.Status(),
+	})
+}
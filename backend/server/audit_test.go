@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAuditSink keeps the events it records.
+type memoryAuditSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (s *memoryAuditSink) Record(e AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// wait returns the events recorded once one of type typ has been, failing
+// the test if none is within readTimeout.
+func (s *memoryAuditSink) wait(t *testing.T, typ string) []AuditEvent {
+	t.Helper()
+	deadline := time.Now().Add(readTimeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		events := append([]AuditEvent(nil), s.events...)
+		s.mu.Unlock()
+		for _, e := range events {
+			if e.Type == typ {
+				return events
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s event was recorded", typ)
+	return nil
+}
+
+func TestAuditEvents(t *testing.T) {
+	srv := startServer(t, nil)
+	sink := &memoryAuditSink{}
+	// Set on the Run loop, which records placements and disconnects.
+	HubInstance.do(func() { auditSink = sink })
+	t.Cleanup(func() { HubInstance.do(func() { auditSink = nil }) })
+
+	conn := dial(t, srv, "username=a")
+	place(t, conn, 2, 3, "#ff4500")
+	if status := postJSON(t, srv, "/admin/freeze", nil, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("unauthorized freeze got %d", status)
+	}
+	conn.Close()
+	events := sink.wait(t, "disconnect")
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if len(events) != 4 || types[0] != "connect" || types[1] != "placement" || types[2] != "admin" || types[3] != "disconnect" {
+		t.Fatalf("recorded %v, want connect, placement, admin, disconnect", types)
+	}
+	connect := events[0]
+	if connect.ClientID == "" || connect.Username != "a" || connect.IP == "" || connect.Time.IsZero() {
+		t.Fatalf("connect event is %+v", connect)
+	}
+	id := connect.ClientID
+	if placed := events[1]; placed.ClientID != id || placed.Username != "a" || *placed.X != 2 || *placed.Y != 3 || placed.Color != "#ff4500" {
+		t.Errorf("placement event is %+v", placed)
+	}
+	if admin := events[2]; admin.Method != http.MethodPost || admin.Path != "/admin/freeze" || admin.Status != http.StatusUnauthorized {
+		t.Errorf("admin event is %+v", admin)
+	}
+	if gone := events[3]; gone.ClientID != id {
+		t.Errorf("disconnect is for %s, want %s", gone.ClientID, id)
+	}
+}
+
+func TestJSONAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	x := 4
+	if err := NewJSONAuditSink(&buf).Record(AuditEvent{Type: "placement", X: &x, Color: "#000000"}); err != nil {
+		t.Fatal(err)
+	}
+	var e AuditEvent
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("decoding %q: %v", buf.String(), err)
+	}
+	if e.Type != "placement" || e.X == nil || *e.X != 4 || e.Y != nil {
+		t.Fatalf("decoded %+v", e)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var errReadOnly = errors.New("sign in to place pixels")
+
+// authClaims are the JWT claims the server uses. The username shown to
+// others is preferred_username, or the subject if the token has none.
+type authClaims struct {
+	PreferredUsername string `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// authEnabled reports whether placing pixels requires a JWT.
+func authEnabled() bool {
+	return config.JWTSecret != ""
+}
+
+// bearerToken returns the token from the Authorization header. Browsers
+// cannot set headers on websocket upgrades, so the token query parameter is
+// accepted as well.
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// parseToken verifies a JWT signed with config.JWTSecret. Tokens without an
+// expiry or subject are rejected.
+func parseToken(raw string) (*authClaims, error) {
+	claims := &authClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(config.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+// username returns the sanitized name to show for the token's user.
+func (c *authClaims) username() string {
+	if c.PreferredUsername != "" {
+		return sanitizeUsername(c.PreferredUsername)
+	}
+	return sanitizeUsername(c.Subject)
+}
+
+// id identifies the token's user for the cooldown.
+func (c *authClaims) id() uuid.UUID {
+	return subjectID(c.Subject)
+}
+
+// subjectID is the id a JWT subject places pixels as.
+func subjectID(subject string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("rplace:sub:"+subject))
+}
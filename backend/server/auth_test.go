@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+const testJWTSecret = "test-jwt-secret"
+
+// signToken returns a JWT for subject, signed with testJWTSecret, that
+// expires after ttl, or has expired when ttl is negative.
+func signToken(t *testing.T, subject, name string, ttl time.Duration) string {
+	t.Helper()
+	claims := authClaims{
+		PreferredUsername: name,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func useJWT(cfg *Config) { cfg.JWTSecret = testJWTSecret }
+
+func TestAuthValidToken(t *testing.T) {
+	srv := startServer(t, useJWT)
+	conn := dial(t, srv, "username=impostor&token="+signToken(t, "user-1", "alice", time.Hour))
+	watcher := dial(t, srv, "")
+	readType(t, watcher, "init", &InitBoardState{})
+
+	place(t, conn, 1, 1, "#ff4500")
+	var update Update
+	readType(t, watcher, "update", &update)
+	if update.SenderName != "alice" {
+		t.Fatalf("update is from %q, want alice", update.SenderName)
+	}
+	if client := clientNamed(t, HubInstance, "alice"); client.uuid != subjectID("user-1") {
+		t.Fatalf("alice placed as %v, want the id of user-1", client.uuid)
+	}
+}
+
+func TestAuthExpiredToken(t *testing.T) {
+	srv := startServer(t, useJWT)
+	expired := signToken(t, "user-1", "alice", -time.Minute)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?token=" + expired
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("upgrade with an expired token was accepted")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("upgrade with an expired token got %v, want status %d", resp, http.StatusUnauthorized)
+	}
+
+	header := http.Header{"Authorization": {"Bearer " + expired}}
+	if status := postJSON(t, srv, "/pixel", map[string]any{"x": 0, "y": 0}, header, nil); status != http.StatusUnauthorized {
+		t.Fatalf("POST /pixel with an expired token: status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthReadOnlyFallback(t *testing.T) {
+	srv := startServer(t, useJWT)
+	anonymous := dial(t, srv, "username=anon")
+	readType(t, anonymous, "init", &InitBoardState{})
+
+	reply := request(t, anonymous, map[string]any{"type": "update", "x": 0, "y": 0, "color": "#000000"})
+	if reply.Type != "nack" || reply.Code != "read_only" {
+		t.Fatalf("anonymous placement got %s %q, want nack read_only", reply.Type, reply.Code)
+	}
+	if p, _ := HubInstance.store.Get(0, 0); p != config.FillColor {
+		t.Fatalf("anonymous placement was applied: %v", p)
+	}
+
+	// Read-only clients still see everyone else's updates.
+	place(t, dial(t, srv, "token="+signToken(t, "user-1", "alice", time.Hour)), 2, 2, "#2450a4")
+	var update Update
+	readType(t, anonymous, "update", &update)
+	if update.X != 2 || update.Y != 2 {
+		t.Fatalf("anonymous client got %+v", update)
+	}
+
+	if status := postJSON(t, srv, "/pixel", map[string]any{"x": 0, "y": 0}, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("POST /pixel without a token: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	header := http.Header{"Authorization": {"Bearer " + signToken(t, "user-2", "", time.Hour)}}
+	if status := postJSON(t, srv, "/pixel", map[string]any{"x": 0, "y": 0}, header, nil); status != http.StatusOK {
+		t.Fatalf("POST /pixel with a valid token: status %d, want %d", status, http.StatusOK)
+	}
+}
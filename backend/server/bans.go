@@ -0,0 +1,279 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// banFile is where the ban list is kept, next to the board snapshot.
+const banFile = "bans.json"
+
+var (
+	errBanned       = errors.New("you are banned from this server")
+	errEmptyBan     = errors.New("one of id, username or ip is required")
+	errInvalidBanID = errors.New("id must be a uuid")
+	errInvalidBanIP = errors.New("ip must be an IP address")
+)
+
+// BanList is the banned identities. A client matching any of them may not
+// connect or place pixels.
+type BanList struct {
+	IDs       []uuid.UUID `json:"ids"`
+	Usernames []string    `json:"usernames"`
+	IPs       []string    `json:"ips"`
+}
+
+// BanRequest names the identities to ban or unban. Any of the fields may be
+// combined.
+type BanRequest struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
+
+type banSet struct {
+	ids   map[uuid.UUID]bool
+	names map[string]bool
+	ips   map[string]bool
+}
+
+func newBanSet() banSet {
+	return banSet{ids: map[uuid.UUID]bool{}, names: map[string]bool{}, ips: map[string]bool{}}
+}
+
+func (s banSet) clone() banSet {
+	next := newBanSet()
+	for id := range s.ids {
+		next.ids[id] = true
+	}
+	for name := range s.names {
+		next.names[name] = true
+	}
+	for ip := range s.ips {
+		next.ips[ip] = true
+	}
+	return next
+}
+
+func (s banSet) list() BanList {
+	list := BanList{IDs: []uuid.UUID{}, Usernames: []string{}, IPs: []string{}}
+	for id := range s.ids {
+		list.IDs = append(list.IDs, id)
+	}
+	for name := range s.names {
+		list.Usernames = append(list.Usernames, name)
+	}
+	for ip := range s.ips {
+		list.IPs = append(list.IPs, ip)
+	}
+	slices.SortFunc(list.IDs, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	slices.Sort(list.Usernames)
+	slices.Sort(list.IPs)
+	return list
+}
+
+// bans starts from the ban file loaded by RestoreSnapshot and is changed
+// by admins at runtime.
+var bans = struct {
+	mu  sync.RWMutex
+	set banSet
+}{set: newBanSet()}
+
+// isBanned reports whether any of a client's identities is banned. Empty
+// identities are not checked.
+func isBanned(id uuid.UUID, username, ip string) bool {
+	bans.mu.RLock()
+	defer bans.mu.RUnlock()
+	return (id != uuid.Nil && bans.set.ids[id]) ||
+		(username != "" && bans.set.names[username]) ||
+		(ip != "" && bans.set.ips[ip])
+}
+
+// banPath returns the ban file path, or "" if snapshots are disabled and
+// bans only last until the server stops.
+func banPath() string {
+	if config.SnapshotPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(config.SnapshotPath), banFile)
+}
+
+// loadBans replaces the ban list with the saved one. A missing file leaves
+// the list empty.
+func loadBans() error {
+	set := newBanSet()
+	if path := banPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			var list BanList
+			if err := json.Unmarshal(data, &list); err != nil {
+				return err
+			}
+			for _, id := range list.IDs {
+				set.ids[id] = true
+			}
+			for _, name := range list.Usernames {
+				set.names[name] = true
+			}
+			for _, ip := range list.IPs {
+				set.ips[ip] = true
+			}
+			logger.Info("Loaded ban list", "path", path, "ids", len(set.ids), "usernames", len(set.names), "ips", len(set.ips))
+		}
+	}
+	bans.mu.Lock()
+	bans.set = set
+	bans.mu.Unlock()
+	return nil
+}
+
+// updateBans bans or unbans the identities in req. The list is saved
+// before it takes effect, so a ban that was not persisted is not applied.
+func updateBans(req BanRequest, banned bool) (BanList, error) {
+	var id uuid.UUID
+	if req.ID != "" {
+		var err error
+		if id, err = uuid.Parse(req.ID); err != nil {
+			return BanList{}, errInvalidBanID
+		}
+	}
+	ip := req.IP
+	if ip != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return BanList{}, errInvalidBanIP
+		}
+		ip = parsed.String()
+	}
+	username := strings.TrimSpace(req.Username)
+	if id == uuid.Nil && username == "" && ip == "" {
+		return BanList{}, errEmptyBan
+	}
+
+	bans.mu.Lock()
+	defer bans.mu.Unlock()
+	next := bans.set.clone()
+	set := func(m map[string]bool, key string) {
+		if key == "" {
+			return
+		}
+		if banned {
+			m[key] = true
+		} else {
+			delete(m, key)
+		}
+	}
+	set(next.names, username)
+	set(next.ips, ip)
+	if id != uuid.Nil {
+		if banned {
+			next.ids[id] = true
+		} else {
+			delete(next.ids, id)
+		}
+	}
+	list := next.list()
+	if path := banPath(); path != "" {
+		data, err := json.Marshal(list)
+		if err != nil {
+			return BanList{}, err
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return BanList{}, err
+		}
+	}
+	bans.set = next
+	return list, nil
+}
+
+// kickBanned disconnects the clients that are now banned. It is only
+// called from the hub's Run loop.
+func (h *Hub) kickBanned() int {
+	var banned []*Client
+	h.mu.RLock()
+	for _, client := range h.clients {
+		if isBanned(client.uuid, client.Username, client.ip) {
+			banned = append(banned, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range banned {
+		logger.Info("Disconnecting banned client", "room", h.name, "username", client.Username, "uuid", client.uuid)
+		client.closeErr = errBanned
+		h.disconnect(client)
+	}
+	return len(banned)
+}
+
+func GetBans() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bans.mu.RLock()
+		list := bans.set.list()
+		bans.mu.RUnlock()
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// BanIdentity bans a uuid, username or IP address in every room and
+// disconnects the clients it matches.
+func BanIdentity() gin.HandlerFunc {
+	return banHandler(true)
+}
+
+func UnbanIdentity() gin.HandlerFunc {
+	return banHandler(false)
+}
+
+func banHandler(banned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		list, err := updateBans(req, banned)
+		switch {
+		case errors.Is(err, errInvalidBanID), errors.Is(err, errInvalidBanIP), errors.Is(err, errEmptyBan):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			logger.Error("Failed to save ban list", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("Ban list updated", "banned", banned, "id", req.ID, "username", req.Username, "target_ip", req.IP, "ip", c.ClientIP())
+		if !banned {
+			c.JSON(http.StatusOK, list)
+			return
+		}
+
+		disconnected := 0
+		for _, h := range rooms {
+			if !h.do(func() { disconnected += h.kickBanned() }) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"ids":          list.IDs,
+			"usernames":    list.Usernames,
+			"ips":          list.IPs,
+			"disconnected": disconnected,
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// getBans fetches /admin/bans from srv.
+func getBans(t *testing.T, srv *httptest.Server) BanList {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/admin/bans", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = adminHeader()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /admin/bans: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /admin/bans: %d", resp.StatusCode)
+	}
+	var list BanList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding ban list: %v", err)
+	}
+	return list
+}
+
+func TestBanOnlineUser(t *testing.T) {
+	srv := startServer(t, nil)
+	troll := dial(t, srv, "username=troll")
+	readType(t, troll, "init", &InitBoardState{})
+	bystander := dial(t, srv, "username=bystander")
+	readType(t, bystander, "init", &InitBoardState{})
+
+	var body struct{ Disconnected int }
+	if status := postJSON(t, srv, "/admin/ban", BanRequest{Username: "troll"}, adminHeader(), &body); status != http.StatusOK {
+		t.Fatalf("banning: %d", status)
+	}
+	if body.Disconnected != 1 {
+		t.Fatalf("ban disconnected %d clients, want 1", body.Disconnected)
+	}
+	if code := readClose(t, troll); code != closeBanned {
+		t.Fatalf("banned client closed with %d, want %d", code, closeBanned)
+	}
+	place(t, bystander, 0, 0, "#000000")
+
+	// Reconnecting and placing over HTTP are refused too.
+	if code := readClose(t, dial(t, srv, "username=troll")); code != closeBanned {
+		t.Fatalf("reconnecting closed with %d, want %d", code, closeBanned)
+	}
+	if status := postJSON(t, srv, "/pixel", map[string]any{"x": 1, "y": 1, "username": "troll"}, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("POST /pixel: status %d, want 403", status)
+	}
+
+	if status := postJSON(t, srv, "/admin/unban", BanRequest{Username: "troll"}, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("unbanning: %d", status)
+	}
+	readType(t, dial(t, srv, "username=troll"), "init", &InitBoardState{})
+}
+
+func TestBanRefusedOnConnect(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.TrustedProxies = []string{"127.0.0.1"} })
+	if status := postJSON(t, srv, "/admin/ban", BanRequest{IP: "203.0.113.7"}, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("banning: %d", status)
+	}
+	for _, tc := range []struct {
+		name string
+		ip   string
+		code int
+	}{
+		{"banned address", "203.0.113.7", closeBanned},
+		{"other address", "203.0.113.8", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=a"
+			conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Forwarded-For": {tc.ip}})
+			if err != nil {
+				t.Fatalf("dialing: %v", err)
+			}
+			defer conn.Close()
+			if code := admitted(t, conn); code != tc.code {
+				t.Fatalf("got close code %d, want %d", code, tc.code)
+			}
+		})
+	}
+}
+
+func TestBanValidation(t *testing.T) {
+	srv := startServer(t, nil)
+	for _, req := range []BanRequest{{}, {ID: "nope"}, {IP: "999.1.1.1"}, {Username: "  "}} {
+		if status := postJSON(t, srv, "/admin/ban", req, adminHeader(), nil); status != http.StatusBadRequest {
+			t.Errorf("banning %+v: status %d, want 400", req, status)
+		}
+	}
+	if status := postJSON(t, srv, "/admin/ban", BanRequest{Username: "a"}, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("banning without the admin token: status %d, want 401", status)
+	}
+}
+
+func TestBansPersist(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "board.json")
+	edit := func(cfg *Config) { cfg.SnapshotPath = snapshot }
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	// The first server is shut down when the subtest ends.
+	t.Run("ban", func(t *testing.T) {
+		srv := startServer(t, edit)
+		if status := postJSON(t, srv, "/admin/ban", BanRequest{ID: id, Username: "troll", IP: "203.0.113.7"}, adminHeader(), nil); status != http.StatusOK {
+			t.Fatalf("banning: %d", status)
+		}
+	})
+
+	// A restart loads the list back.
+	srv := startServer(t, edit)
+	list := getBans(t, srv)
+	if len(list.IDs) != 1 || list.IDs[0].String() != id ||
+		!slices.Equal(list.Usernames, []string{"troll"}) || !slices.Equal(list.IPs, []string{"203.0.113.7"}) {
+		t.Fatalf("restored %+v", list)
+	}
+	if code := readClose(t, dial(t, srv, "username=troll")); code != closeBanned {
+		t.Fatalf("banned user after a restart closed with %d, want %d", code, closeBanned)
+	}
+}
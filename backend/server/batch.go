@@ -0,0 +1,37 @@
+package server
+
+type BatchMessage struct {
+	Type    string   `json:"type"`
+	Updates []Update `json:"updates"`
+}
+
+// updateBatch collects updates during a batch window, keeping only the
+// latest update for each cell.
+type updateBatch struct {
+	updates []Update
+	index   map[cell]int
+}
+
+// add records u and reports whether it started a new batch.
+func (b *updateBatch) add(u Update) bool {
+	if b.index == nil {
+		b.index = make(map[cell]int)
+	}
+	started := len(b.updates) == 0
+	key := cell{X: u.X, Y: u.Y}
+	if i, ok := b.index[key]; ok {
+		b.updates[i] = u
+		return started
+	}
+	b.index[key] = len(b.updates)
+	b.updates = append(b.updates, u)
+	return started
+}
+
+// take returns the pending updates and resets the batch.
+func (b *updateBatch) take() []Update {
+	updates := b.updates
+	b.updates = nil
+	b.index = nil
+	return updates
+}
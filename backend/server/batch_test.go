@@ -0,0 +1,39 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBatchCoalescesRapidUpdates(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.BatchWindow = 500 * time.Millisecond })
+	sender := dial(t, srv, "username=sender")
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+
+	// Ten updates to three cells; only the last color of each is kept.
+	for i := range 10 {
+		send(t, sender, map[string]any{"type": "update", "x": i % 3, "y": 0, "color": fmt.Sprintf("#0000%02x", i)})
+	}
+
+	var batch BatchMessage
+	watcher.SetReadDeadline(time.Now().Add(readTimeout))
+	for batch.Type != "batch" {
+		if err := watcher.ReadJSON(&batch); err != nil {
+			t.Fatalf("waiting for batch: %v", err)
+		}
+		if batch.Type == "update" {
+			t.Fatal("an update was sent on its own during the batch window")
+		}
+	}
+	if len(batch.Updates) != 3 {
+		t.Fatalf("batch holds %d updates, want 3: %+v", len(batch.Updates), batch.Updates)
+	}
+	want := map[int]uint8{0: 9, 1: 7, 2: 8}
+	for _, u := range batch.Updates {
+		if u.Y != 0 || u.Pixel.B != want[u.X] {
+			t.Errorf("batch has %v at (%d, %d), want blue %d", u.Pixel, u.X, u.Y, want[u.X])
+		}
+	}
+}
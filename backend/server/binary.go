@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/binary"
+
+	"github.com/gorilla/websocket"
+)
+
+// binaryMessage is written as a websocket binary frame instead of JSON.
+type binaryMessage []byte
+
+// binaryBoardHeader is the size of the header in front of the pixel data of
+// a binary board: width and height as big-endian uint32, then the sequence
+// number as a big-endian uint64.
+const binaryBoardHeader = 16
+
+// encodeBinaryBoard packs pixels into a binary init frame. After the header
+// come width*height*3 bytes of RGB data, row by row from the top left.
+// Alpha is not included.
+func encodeBinaryBoard(seq uint64, pixels [][]Pixel) binaryMessage {
+	height := len(pixels)
+	width := 0
+	if height > 0 {
+		width = len(pixels[0])
+	}
+	data := make([]byte, binaryBoardHeader, binaryBoardHeader+width*height*3)
+	binary.BigEndian.PutUint32(data[0:4], uint32(width))
+	binary.BigEndian.PutUint32(data[4:8], uint32(height))
+	binary.BigEndian.PutUint64(data[8:16], seq)
+	for _, row := range pixels {
+		for _, p := range row {
+			data = append(data, p.R, p.G, p.B)
+		}
+	}
+	return data
+}
+
+// writeMessage writes message to conn as a binary frame if it is a
+// binaryMessage and as JSON otherwise.
+func writeMessage(conn *websocket.Conn, message interface{}) error {
+	if data, ok := message.(binaryMessage); ok {
+		return conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+	return conn.WriteJSON(message)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readBinary reads messages from conn until a binary one arrives.
+func readBinary(t *testing.T, conn *websocket.Conn) []byte {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("reading binary message: %v", err)
+		}
+		if kind == websocket.BinaryMessage {
+			return data
+		}
+	}
+}
+
+func TestBinaryInitMatchesJSON(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.BoardWidth, cfg.BoardHeight = 12, 7 })
+	first := dial(t, srv, "username=first")
+	place(t, first, 0, 0, "#ff4500")
+	place(t, first, 11, 0, "#2450a4")
+	place(t, first, 5, 6, "#00cc78")
+
+	var init InitBoardState
+	readType(t, dial(t, srv, "username=json"), "init", &init)
+	data := readBinary(t, dial(t, srv, "username=binary&format=binary"))
+
+	width := int(binary.BigEndian.Uint32(data[0:4]))
+	height := int(binary.BigEndian.Uint32(data[4:8]))
+	if width != 12 || height != 7 {
+		t.Fatalf("binary init is %dx%d, want 12x7", width, height)
+	}
+	if seq := binary.BigEndian.Uint64(data[8:16]); seq != init.Seq {
+		t.Fatalf("binary init is at seq %d, JSON at %d", seq, init.Seq)
+	}
+	rgb := data[binaryBoardHeader:]
+	if len(rgb) != width*height*3 {
+		t.Fatalf("binary init has %d bytes of pixels, want %d", len(rgb), width*height*3)
+	}
+	for y, row := range init.Pixels {
+		for x, p := range row {
+			i := (y*width + x) * 3
+			if got := (Pixel{R: rgb[i], G: rgb[i+1], B: rgb[i+2], A: p.A}); got != p {
+				t.Fatalf("binary init has %v at (%d, %d), JSON has %v", got, x, y, p)
+			}
+		}
+	}
+
+	var raw RawBoard
+	if status := getJSON(t, srv, "/board/raw", &raw); status != http.StatusOK {
+		t.Fatalf("GET /board/raw: status %d", status)
+	}
+	if raw.Width != width || raw.Height != height || string(raw.Data) != string(rgb) {
+		t.Fatalf("/board/raw differs from the binary init")
+	}
+}
+
+func TestInitRejectsUnknownFormat(t *testing.T) {
+	srv := startServer(t, nil)
+	resp, err := http.Get(srv.URL + "/ws?format=xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// botIdentity is a sanctioned bot authenticated with an API key instead of
+// a user JWT. Bots have ids of their own, so they never share a cooldown
+// with a user of the same name, and wait cooldown between placements.
+type botIdentity struct {
+	name     string
+	id       uuid.UUID
+	cooldown time.Duration
+}
+
+var (
+	// bots maps API keys to their bot, and botIDs bot ids to the same.
+	// Both are only written by Configure.
+	bots   = map[string]botIdentity{}
+	botIDs = map[uuid.UUID]botIdentity{}
+)
+
+// setBotKeys parses config.BotKeys entries of the form key=name, or
+// key=name@cooldown to give the bot a cooldown other than config.BotCooldown.
+func setBotKeys(entries []string, cooldown time.Duration) error {
+	byKey := make(map[string]botIdentity, len(entries))
+	byID := make(map[uuid.UUID]botIdentity, len(entries))
+	for _, entry := range entries {
+		key, name, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || name == "" {
+			return fmt.Errorf("bot key entry must be key=name or key=name@cooldown")
+		}
+		bot := botIdentity{cooldown: cooldown}
+		name, value, ok := strings.Cut(name, "@")
+		if ok {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid cooldown %q for bot %q", value, name)
+			}
+			bot.cooldown = d
+		}
+		bot.name = sanitizeUsername(name)
+		bot.id = uuid.NewSHA1(uuid.NameSpaceURL, []byte("rplace:bot:"+bot.name))
+		if _, ok := byKey[key]; ok {
+			return fmt.Errorf("duplicate bot key for %q", bot.name)
+		}
+		byKey[key] = bot
+		byID[bot.id] = bot
+	}
+	bots, botIDs = byKey, byID
+	return nil
+}
+
+// apiKey returns the bot API key of r from the X-API-Key header or, for
+// websocket upgrades, the apikey query parameter.
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("apikey")
+}
+
+// botFor returns the bot whose API key r carries. It reports false if r has
+// no key, and fails if the key is unknown.
+func botFor(r *http.Request) (botIdentity, bool, error) {
+	key := apiKey(r)
+	if key == "" {
+		return botIdentity{}, false, nil
+	}
+	bot, ok := bots[key]
+	if !ok {
+		return botIdentity{}, false, fmt.Errorf("invalid API key")
+	}
+	return bot, true, nil
+}
+
+func isBot(id uuid.UUID) bool {
+	_, ok := botIDs[id]
+	return ok
+}
+
+// cooldownFor returns how long id waits between placements with
+// PlacementLimit "cooldown".
+func cooldownFor(id uuid.UUID) time.Duration {
+	if bot, ok := botIDs[id]; ok {
+		return bot.cooldown
+	}
+	return config.Cooldown
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBotAPIKeys(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Cooldown = time.Minute
+		cfg.BotKeys = []string{"fast-key=painter@0s", "slow-key=slowbot"}
+		cfg.BotCooldown = time.Hour
+	})
+	post := func(key string, x int) int {
+		t.Helper()
+		y := 0
+		header := http.Header{}
+		if key != "" {
+			header.Set("X-API-Key", key)
+		}
+		return postJSON(t, srv, "/pixel", PlacePixelRequest{X: &x, Y: &y, Username: "painter"}, header, nil)
+	}
+
+	t.Run("elevated limit", func(t *testing.T) {
+		for x := range 3 {
+			if status := post("fast-key", x); status != http.StatusOK {
+				t.Fatalf("bot placement %d got %d", x, status)
+			}
+		}
+		if _, meta := HubInstance.store.Get(2, 0); meta.Username != "painter" || !meta.Bot {
+			t.Fatalf("bot placement is owned by %+v", meta)
+		}
+	})
+
+	t.Run("own tier", func(t *testing.T) {
+		if status := post("slow-key", 3); status != http.StatusOK {
+			t.Fatalf("first placement got %d", status)
+		}
+		if status := post("slow-key", 4); status != http.StatusTooManyRequests {
+			t.Fatalf("second placement within the bot cooldown got %d", status)
+		}
+	})
+
+	t.Run("users are not bots", func(t *testing.T) {
+		// The name alone does not make a bot.
+		if status := post("", 5); status != http.StatusOK {
+			t.Fatalf("user placement got %d", status)
+		}
+		if _, meta := HubInstance.store.Get(5, 0); meta.Bot {
+			t.Fatal("user placement was tagged as a bot's")
+		}
+		if status := post("", 6); status != http.StatusTooManyRequests {
+			t.Fatalf("second user placement got %d", status)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if status := post("wrong-key", 7); status != http.StatusUnauthorized {
+			t.Fatalf("got %d, want %d", status, http.StatusUnauthorized)
+		}
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?apikey=wrong-key"
+		conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			conn.Close()
+			t.Fatal("upgrade with an invalid key was accepted")
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("upgrade with an invalid key: %v", err)
+		}
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		conn := dial(t, srv, "apikey=fast-key&username=impostor")
+		place(t, conn, 0, 1, "#000000")
+		place(t, conn, 1, 1, "#000000")
+		if client := clientNamed(t, HubInstance, "painter"); !isBot(client.uuid) {
+			t.Fatal("websocket bot is not identified as one")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxChangeLogSize caps config.ChangeLogSize; the log is allocated up front.
+const maxChangeLogSize = 1_000_000
+
+type DeltaMessage struct {
+	Type    string   `json:"type"`
+	Seq     uint64   `json:"seq"`
+	Updates []Update `json:"updates"`
+}
+
+// changeLog numbers applied updates and keeps the most recent ones in a ring
+// buffer so reconnecting clients can catch up without a full board.
+type changeLog struct {
+	mu      sync.RWMutex
+	entries []Update
+	next    int
+	count   int
+	lastSeq uint64
+}
+
+func newChangeLog(capacity int) *changeLog {
+	return &changeLog{entries: make([]Update, capacity)}
+}
+
+// append assigns the next sequence number to u and records it.
+func (l *changeLog) append(u Update) Update {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastSeq++
+	u.Seq = l.lastSeq
+	if len(l.entries) == 0 {
+		return u
+	}
+	l.entries[l.next] = u
+	l.next = (l.next + 1) % len(l.entries)
+	if l.count < len(l.entries) {
+		l.count++
+	}
+	return u
+}
+
+// invalidate bumps the sequence number and forgets the logged updates, so
+// clients from before the bump get a full board. It returns the new sequence.
+func (l *changeLog) invalidate() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastSeq++
+	l.count = 0
+	return l.lastSeq
+}
+
+// resume continues numbering after seq, for example after replaying the
+// event log on startup.
+func (l *changeLog) resume(seq uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastSeq = seq
+	l.count = 0
+}
+
+func (l *changeLog) seq() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastSeq
+}
+
+// since returns the updates applied after seq, oldest first, along with the
+// sequence number of the newest one. It reports false if the log no longer
+// reaches back that far or seq is ahead of the log.
+func (l *changeLog) since(seq uint64) ([]Update, uint64, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if seq > l.lastSeq {
+		return nil, 0, false
+	}
+	missing := l.lastSeq - seq
+	if missing > uint64(l.count) {
+		return nil, 0, false
+	}
+	updates := make([]Update, 0, missing)
+	start := l.next - int(missing)
+	if start < 0 {
+		start += len(l.entries)
+	}
+	for i := 0; i < int(missing); i++ {
+		updates = append(updates, l.entries[(start+i)%len(l.entries)])
+	}
+	return updates, l.lastSeq, true
+}
+
+// resync answers a client that detected a gap after seq. It sends the
+// missing updates, or the full board if the change log no longer reaches
+// back that far. It is only called from the hub's Run loop.
+func (h *Hub) resync(client *Client, seq uint64) {
+	if updates, last, ok := h.changes.since(seq); ok {
+		logger.Debug("Resyncing client from change log", "uuid", client.uuid, "since", seq, "updates", len(updates))
+		h.sendTo(client.uuid, DeltaMessage{
+			Type:    "delta",
+			Seq:     last,
+			Updates: updates,
+		})
+		return
+	}
+	logger.Debug("Resync gap too large, sending full board", "uuid", client.uuid, "since", seq)
+	h.sendTo(client.uuid, InitBoardState{
+		Type:   "init",
+		Seq:    h.changes.seq(),
+		Pixels: h.store.Snapshot(),
+	})
+}
+
+// GetChanges lets clients without a websocket poll for updates applied after
+// the since sequence number. The returned seq is what to poll with next. If
+// the change log no longer reaches back to since, it answers 410 Gone and
+// the client should fetch the full board instead.
+func GetChanges() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		since, err := strconv.ParseUint(c.Query("since"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a sequence number"})
+			return
+		}
+		updates, seq, ok := hub.changes.since(since)
+		if !ok {
+			c.JSON(http.StatusGone, gin.H{
+				"error": "changes since that sequence are no longer available",
+				"seq":   hub.changes.seq(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, DeltaMessage{
+			Type:    "delta",
+			Seq:     seq,
+			Updates: updates,
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestReconnectDelta(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.ChangeLogSize = 4 })
+	conn := dial(t, srv, "username=a")
+
+	place(t, conn, 0, 0, "#111111")
+	since := HubInstance.changes.seq()
+	place(t, conn, 1, 0, "#222222")
+	place(t, conn, 2, 0, "#333333")
+
+	t.Run("delta", func(t *testing.T) {
+		var delta DeltaMessage
+		readType(t, dial(t, srv, "username=b&since="+strconv.FormatUint(since, 10)), "delta", &delta)
+		if delta.Seq != since+2 || len(delta.Updates) != 2 {
+			t.Fatalf("delta to seq %d holds %d updates, want 2 up to %d", delta.Seq, len(delta.Updates), since+2)
+		}
+		for i, u := range delta.Updates {
+			if u.X != i+1 || u.Seq != since+uint64(i)+1 {
+				t.Errorf("update %d is %+v", i, u)
+			}
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		for x := range 5 {
+			place(t, conn, x, 1, "#444444")
+		}
+		var init InitBoardState
+		readType(t, dial(t, srv, "username=c&since="+strconv.FormatUint(since, 10)), "init", &init)
+		if init.Seq != HubInstance.changes.seq() {
+			t.Fatalf("init is at seq %d, want %d", init.Seq, HubInstance.changes.seq())
+		}
+		if init.Pixels[0][2] != (Pixel{R: 0x33, G: 0x33, B: 0x33, A: 255}) {
+			t.Fatalf("init misses an earlier placement: %v", init.Pixels[0][2])
+		}
+	})
+}
+
+func TestResync(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.ChangeLogSize = 4 })
+	painter := dial(t, srv, "username=painter")
+	conn := dial(t, srv, "username=a")
+	var init InitBoardState
+	readType(t, conn, "init", &init)
+
+	place(t, painter, 1, 0, "#222222")
+	place(t, painter, 2, 0, "#333333")
+
+	t.Run("in range", func(t *testing.T) {
+		send(t, conn, map[string]any{"type": "resync", "seq": init.Seq})
+		var delta DeltaMessage
+		readType(t, conn, "delta", &delta)
+		if delta.Seq != init.Seq+2 || len(delta.Updates) != 2 {
+			t.Fatalf("delta to seq %d holds %d updates, want 2 up to %d", delta.Seq, len(delta.Updates), init.Seq+2)
+		}
+		if delta.Updates[0].X != 1 || delta.Updates[1].X != 2 {
+			t.Fatalf("delta holds %+v", delta.Updates)
+		}
+	})
+
+	t.Run("too old", func(t *testing.T) {
+		for x := range 5 {
+			place(t, painter, x, 1, "#444444")
+		}
+		send(t, conn, map[string]any{"type": "resync", "seq": init.Seq})
+		var full InitBoardState
+		readType(t, conn, "init", &full)
+		if full.Seq != HubInstance.changes.seq() {
+			t.Fatalf("init is at seq %d, want %d", full.Seq, HubInstance.changes.seq())
+		}
+		if full.Pixels[1][4] != (Pixel{R: 0x44, G: 0x44, B: 0x44, A: 255}) {
+			t.Fatalf("init misses the latest placement: %v", full.Pixels[1][4])
+		}
+	})
+}
+
+func TestChangeLogSince(t *testing.T) {
+	l := newChangeLog(3)
+	for x := range 5 {
+		l.append(Update{X: x})
+	}
+	for _, tc := range []struct {
+		seq     uint64
+		ok      bool
+		updates int
+	}{
+		{5, true, 0},
+		{2, true, 3},
+		{1, false, 0},
+		{6, false, 0},
+	} {
+		updates, last, ok := l.since(tc.seq)
+		if ok != tc.ok || len(updates) != tc.updates {
+			t.Errorf("since(%d) = %d updates, %v; want %d, %v", tc.seq, len(updates), ok, tc.updates, tc.ok)
+			continue
+		}
+		if ok && last != 5 {
+			t.Errorf("since(%d) ends at seq %d, want 5", tc.seq, last)
+		}
+	}
+}
+
+func TestPollChanges(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.ChangeLogSize = 4 })
+	conn := dial(t, srv, "username=a")
+
+	var delta DeltaMessage
+	if status := getJSON(t, srv, "/board/changes?since=0", &delta); status != http.StatusOK {
+		t.Fatalf("polling an empty log: %d", status)
+	}
+	if delta.Seq != 0 || len(delta.Updates) != 0 {
+		t.Fatalf("empty log gave %+v", delta)
+	}
+
+	place(t, conn, 1, 0, "#222222")
+	place(t, conn, 2, 0, "#333333")
+	if getJSON(t, srv, "/board/changes?since=0", &delta); delta.Seq != 2 || len(delta.Updates) != 2 {
+		t.Fatalf("first poll gave seq %d with %d updates, want 2 and 2", delta.Seq, len(delta.Updates))
+	}
+	if u := delta.Updates[1]; u.X != 2 || u.Seq != 2 || u.Pixel != (Pixel{R: 0x33, G: 0x33, B: 0x33, A: 255}) {
+		t.Fatalf("second update is %+v", u)
+	}
+
+	// Polling again from the returned seq only gets what came after it.
+	place(t, conn, 3, 0, "#444444")
+	if getJSON(t, srv, "/board/changes?since="+strconv.FormatUint(delta.Seq, 10), &delta); delta.Seq != 3 || len(delta.Updates) != 1 || delta.Updates[0].X != 3 {
+		t.Fatalf("second poll gave %+v", delta)
+	}
+
+	t.Run("rolled past", func(t *testing.T) {
+		for x := range 4 {
+			place(t, conn, x, 1, "#555555")
+		}
+		var gone struct {
+			Error string `json:"error"`
+			Seq   uint64 `json:"seq"`
+		}
+		if status := getJSON(t, srv, "/board/changes?since=1", &gone); status != http.StatusGone {
+			t.Fatalf("got status %d, want %d", status, http.StatusGone)
+		}
+		if gone.Seq != 7 || gone.Error == "" {
+			t.Fatalf("410 body is %+v, want seq 7 and an error", gone)
+		}
+	})
+
+	t.Run("bad since", func(t *testing.T) {
+		if status := getJSON(t, srv, "/board/changes?since=x", nil); status != http.StatusBadRequest {
+			t.Fatalf("got status %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+}
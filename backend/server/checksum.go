@@ -0,0 +1,41 @@
+package server
+
+import (
+	"hash/crc32"
+
+	"github.com/google/uuid"
+)
+
+// ChecksumMessage lets clients check their copy of the board. Checksum is
+// the CRC-32 (IEEE) of the board's R, G, B and A bytes, row by row, as of
+// sequence number Seq. A client whose board hashes differently should send
+// a resync.
+type ChecksumMessage struct {
+	Type     string `json:"type"`
+	Seq      uint64 `json:"seq"`
+	Checksum uint32 `json:"checksum"`
+}
+
+func boardChecksum(pixels [][]Pixel) uint32 {
+	hash := crc32.NewIEEE()
+	row := []byte{}
+	for _, pixels := range pixels {
+		row = row[:0]
+		for _, p := range pixels {
+			row = append(row, p.R, p.G, p.B, p.A)
+		}
+		hash.Write(row)
+	}
+	return hash.Sum32()
+}
+
+// broadcastChecksum sends the board checksum to every client. It is only
+// called from the hub's Run loop, after any pending batch has been flushed,
+// so clients have seen every update the checksum covers.
+func (h *Hub) broadcastChecksum() {
+	h.broadcastMessage(ChecksumMessage{
+		Type:     "checksum",
+		Seq:      h.changes.seq(),
+		Checksum: boardChecksum(h.store.Snapshot()),
+	}, uuid.Nil)
+}
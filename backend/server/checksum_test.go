@@ -0,0 +1,45 @@
+package server
+
+import (
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+// crcRGBA hashes pixels the way clients are told to: their R, G, B and A
+// bytes row by row.
+func crcRGBA(pixels [][]Pixel) uint32 {
+	var data []byte
+	for _, row := range pixels {
+		for _, p := range row {
+			data = append(data, p.R, p.G, p.B, p.A)
+		}
+	}
+	return crc32.ChecksumIEEE(data)
+}
+
+func TestChecksum(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.ChecksumInterval = 20 * time.Millisecond })
+	conn := dial(t, srv, "username=a")
+	var init InitBoardState
+	readType(t, conn, "init", &init)
+
+	var before ChecksumMessage
+	readType(t, conn, "checksum", &before)
+	if want := crcRGBA(init.Pixels); before.Checksum != want {
+		t.Fatalf("checksum of the initial board is %08x, want %08x", before.Checksum, want)
+	}
+
+	place(t, conn, 4, 5, "#ff4500")
+	init.Pixels[5][4] = Pixel{R: 0xff, G: 0x45, A: 255}
+	after := before
+	for after.Seq == before.Seq {
+		readType(t, conn, "checksum", &after)
+	}
+	if after.Checksum == before.Checksum {
+		t.Fatal("checksum did not change after a placement")
+	}
+	if want := crcRGBA(init.Pixels); after.Checksum != want {
+		t.Fatalf("checksum after placing is %08x, want %08x", after.Checksum, want)
+	}
+}
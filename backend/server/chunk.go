@@ -0,0 +1,44 @@
+package server
+
+// InitChunkMessage carries rows Y to Y+len(Pixels)-1 of the board when the
+// initial board is sent in chunks.
+type InitChunkMessage struct {
+	Type   string    `json:"type"`
+	Seq    uint64    `json:"seq"`
+	Y      int       `json:"y"`
+	Pixels [][]Pixel `json:"pixels"`
+}
+
+// InitCompleteMessage follows the last chunk of the initial board. Clients
+// apply the assembled board once they receive it.
+type InitCompleteMessage struct {
+	Type   string `json:"type"`
+	Seq    uint64 `json:"seq"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// initChunks splits pixels into init_chunk messages of at most rows rows
+// each, followed by an init_complete message.
+func initChunks(seq uint64, pixels [][]Pixel, rows int) []interface{} {
+	var messages []interface{}
+	for y := 0; y < len(pixels); y += rows {
+		end := min(y+rows, len(pixels))
+		messages = append(messages, InitChunkMessage{
+			Type:   "init_chunk",
+			Seq:    seq,
+			Y:      y,
+			Pixels: pixels[y:end],
+		})
+	}
+	width := 0
+	if len(pixels) > 0 {
+		width = len(pixels[0])
+	}
+	return append(messages, InitCompleteMessage{
+		Type:   "init_complete",
+		Seq:    seq,
+		Width:  width,
+		Height: len(pixels),
+	})
+}
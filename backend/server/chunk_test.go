@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChunkedInitReassembles(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.BoardWidth, cfg.BoardHeight = 9, 13
+		cfg.InitChunkRows = 5
+	})
+	painter := dial(t, srv, "username=painter")
+	place(t, painter, 0, 0, "#ff4500")
+	place(t, painter, 8, 12, "#2450a4")
+	place(t, painter, 4, 5, "#00cc78")
+
+	conn := dial(t, srv, "username=viewer")
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	var (
+		pixels [][]Pixel
+		chunks int
+		done   InitCompleteMessage
+	)
+	for done.Type == "" {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("reading init: %v", err)
+		}
+		var msg struct{ Type string }
+		json.Unmarshal(data, &msg)
+		switch msg.Type {
+		case "init":
+			t.Fatal("got a whole init with chunking enabled")
+		case "init_chunk":
+			var chunk InitChunkMessage
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				t.Fatal(err)
+			}
+			if chunk.Y != len(pixels) {
+				t.Fatalf("chunk starts at row %d, want %d", chunk.Y, len(pixels))
+			}
+			if len(chunk.Pixels) > 5 {
+				t.Fatalf("chunk has %d rows, want at most 5", len(chunk.Pixels))
+			}
+			pixels = append(pixels, chunk.Pixels...)
+			chunks++
+		case "init_complete":
+			if err := json.Unmarshal(data, &done); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if chunks != 3 {
+		t.Fatalf("got %d chunks, want 3", chunks)
+	}
+	if done.Width != 9 || done.Height != 13 {
+		t.Fatalf("init_complete reports %dx%d, want 9x13", done.Width, done.Height)
+	}
+	var board boardSnapshot
+	if status := getJSON(t, srv, "/board", &board); status != http.StatusOK {
+		t.Fatalf("GET /board: status %d", status)
+	}
+	if !reflect.DeepEqual(pixels, board.Pixels) {
+		t.Fatal("reassembled board differs from GET /board")
+	}
+}
+
+func TestInitChunksEmptyBoard(t *testing.T) {
+	messages := initChunks(7, nil, 4)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages for an empty board, want only init_complete", len(messages))
+	}
+	if done, ok := messages[0].(InitCompleteMessage); !ok || done.Seq != 7 || done.Width != 0 {
+		t.Fatalf("got %+v", messages[0])
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close codes for connections rejected after the websocket upgrade, in the
+// 4000-4999 range reserved for applications, so clients can tell users why.
+// Rejections before the upgrade use HTTP status codes instead.
+const (
+	closeBanned             = 4001
+	closeServerFull         = 4002
+	closeTooManyConnections = 4003
+	closeUsernameTaken      = 4004
+)
+
+// closeCode returns the close code for a connection rejected or closed by
+// the hub with err.
+func closeCode(err error) int {
+	switch {
+	case errors.Is(err, errDraining):
+		return websocket.CloseGoingAway
+	case errors.Is(err, errBanned):
+		return closeBanned
+	case errors.Is(err, errServerFull):
+		return closeServerFull
+	case errors.Is(err, errTooManyConns):
+		return closeTooManyConnections
+	case errors.Is(err, errUsernameTaken):
+		return closeUsernameTaken
+	}
+	return websocket.ClosePolicyViolation
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errInvalidColor = errors.New("invalid color")
+
+// parseHexColor parses a "#RRGGBB" or "#RGB" color, optionally followed by
+// an alpha component as in "#RRGGBBAA" or "#RGBA". Alpha defaults to opaque.
+func parseHexColor(s string) (Pixel, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok {
+		return Pixel{}, fmt.Errorf("%w: %q must start with #", errInvalidColor, s)
+	}
+	if len(hex) == 3 || len(hex) == 4 {
+		long := make([]byte, 0, 2*len(hex))
+		for i := 0; i < len(hex); i++ {
+			long = append(long, hex[i], hex[i])
+		}
+		hex = string(long)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return Pixel{}, fmt.Errorf("%w: %q must be #RRGGBB, #RGB, #RRGGBBAA or #RGBA", errInvalidColor, s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Pixel{}, fmt.Errorf("%w: %q is not valid hex", errInvalidColor, s)
+	}
+	return Pixel{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// hexColor formats p as "#rrggbb", or "#rrggbbaa" if it is translucent.
+func hexColor(p Pixel) string {
+	if p.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", p.R, p.G, p.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", p.R, p.G, p.B, p.A)
+}
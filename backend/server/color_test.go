@@ -0,0 +1,54 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Pixel
+	}{
+		{"#ff4500", Pixel{R: 0xff, G: 0x45, A: 255}},
+		{"#FF4500", Pixel{R: 0xff, G: 0x45, A: 255}},
+		{"#f40", Pixel{R: 0xff, G: 0x44, A: 255}},
+		{"#000000", Pixel{A: 255}},
+		{"#2450a480", Pixel{R: 0x24, G: 0x50, B: 0xa4, A: 0x80}},
+		{"#fff8", Pixel{R: 0xff, G: 0xff, B: 0xff, A: 0x88}},
+	} {
+		got, err := parseHexColor(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("parseHexColor(%q) = %v, %v; want %v", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{"", "#", "ff4500", "#ff450", "#ff45000", "#ggg", "#ff450g", "#+f4500", "red"} {
+		if p, err := parseHexColor(in); !errors.Is(err, errInvalidColor) {
+			t.Errorf("parseHexColor(%q) = %v, %v; want errInvalidColor", in, p, err)
+		}
+	}
+}
+
+func TestHexColorRoundTrip(t *testing.T) {
+	for _, p := range []Pixel{{A: 255}, {R: 1, G: 2, B: 3, A: 255}, {R: 0xff, G: 0x45, A: 0x80}} {
+		got, err := parseHexColor(hexColor(p))
+		if err != nil || got != p {
+			t.Errorf("%v formats as %q, which parses to %v, %v", p, hexColor(p), got, err)
+		}
+	}
+}
+
+func TestNumericPixelStillAccepted(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	reply := request(t, conn, map[string]any{
+		"type": "update", "x": 2, "y": 3,
+		"pixel": map[string]any{"r": 10, "g": 20, "b": 30, "a": 255},
+	})
+	if reply.Type != "ack" {
+		t.Fatalf("numeric pixel got %s %q", reply.Type, reply.Message)
+	}
+	if p, _ := HubInstance.store.Get(2, 3); p != (Pixel{R: 10, G: 20, B: 30, A: 255}) {
+		t.Fatalf("board has %v at (2, 3)", p)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"compress/flate"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCompressedInit(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Compression = true
+		cfg.BoardWidth, cfg.BoardHeight = 200, 150
+	})
+	place(t, dial(t, srv, "username=first"), 199, 149, "#00cc78")
+
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = true
+	conn, resp := dialWith(t, srv, &dialer, "username=second")
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("permessage-deflate was not negotiated, extensions %q", ext)
+	}
+
+	var init InitBoardState
+	readType(t, conn, "init", &init)
+	if len(init.Pixels) != 150 || len(init.Pixels[0]) != 200 {
+		t.Fatalf("init is %dx%d, want 200x150", len(init.Pixels[0]), len(init.Pixels))
+	}
+	if got, want := init.Pixels[149][199], (Pixel{G: 0xcc, B: 0x78, A: 255}); got != want {
+		t.Fatalf("init has %v at (199, 149), want %v", got, want)
+	}
+
+	// Control frames are never compressed and must still get through.
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data string) error {
+		pong <- data
+		return nil
+	})
+	if err := conn.WriteControl(websocket.PingMessage, []byte("hello"), time.Now().Add(readTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	place(t, conn, 0, 0, "#000000")
+	select {
+	case data := <-pong:
+		if data != "hello" {
+			t.Fatalf("pong carried %q, want hello", data)
+		}
+	case <-time.After(readTimeout):
+		t.Fatal("no pong arrived")
+	}
+
+	closing := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(readTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	if code := readClose(t, conn); code != websocket.CloseNormalClosure {
+		t.Fatalf("got close code %d, want %d", code, websocket.CloseNormalClosure)
+	}
+}
+
+func TestCompressionOffByDefault(t *testing.T) {
+	srv := startServer(t, nil)
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = true
+	_, resp := dialWith(t, srv, &dialer, "username=a")
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Fatalf("negotiated %q with compression disabled", ext)
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	for _, level := range []int{flate.HuffmanOnly, flate.NoCompression, flate.BestSpeed, flate.BestCompression} {
+		t.Run(strconv.Itoa(level), func(t *testing.T) {
+			logs := captureLogs(t)
+			srv := startServer(t, func(cfg *Config) {
+				cfg.LogLevel = slog.LevelWarn
+				cfg.Compression = true
+				cfg.CompressionLevel = level
+			})
+			dialer := *websocket.DefaultDialer
+			dialer.EnableCompression = true
+			conn, _ := dialWith(t, srv, &dialer, "username=a")
+			readType(t, conn, "init", &InitBoardState{})
+			place(t, conn, 0, 0, "#000000")
+			if strings.Contains(logs.String(), "Invalid compression level") {
+				t.Fatalf("setting level %d failed:\n%s", level, logs)
+			}
+		})
+	}
+
+	for value, want := range map[string]int{"-2": flate.HuffmanOnly, "0": flate.NoCompression, "9": flate.BestCompression, "10": defaultCompressionLevel, "fast": defaultCompressionLevel} {
+		t.Setenv("RPLACE_COMPRESSION_LEVEL", value)
+		if got := LoadConfig().CompressionLevel; got != want {
+			t.Errorf("RPLACE_COMPRESSION_LEVEL=%s read as %d, want %d", value, got, want)
+		}
+	}
+	useConfig(t, nil)
+	if err := Configure(testConfig(t, func(cfg *Config) { cfg.CompressionLevel = 10 })); err == nil {
+		t.Error("compression level 10 was accepted")
+	}
+}
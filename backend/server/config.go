@@ -0,0 +1,692 @@
+package server
+
+import (
+	"compress/flate"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Addr is the address the HTTP server listens on, as host:port or just
+	// a port.
+	Addr string
+	// TLSCert and TLSKey, when both set, serve HTTPS and WSS on Addr.
+	TLSCert string
+	TLSKey  string
+	// HTTPRedirectAddr, when TLS is enabled, listens for plain HTTP there
+	// and redirects it to HTTPS. Empty disables it.
+	HTTPRedirectAddr string
+
+	LogLevel slog.Level
+
+	// AllowedOrigins lists the origins allowed to make cross-origin
+	// requests and open websockets. "*" allows any origin.
+	AllowedOrigins []string
+	// AdminToken guards the /admin endpoints. They are disabled when empty.
+	AdminToken string
+	// JWTSecret, when set, requires a JWT signed with it (HS256) to place
+	// pixels. The token's user replaces the username parameter; clients
+	// connecting without a token can watch but not place.
+	JWTSecret string
+	// IdentitySecret signs the identity tokens that let clients reconnect
+	// as the same user, keeping their cooldown. If empty, a random key is
+	// used and tokens stop working on restart. IdentityTTL is how long a
+	// token is valid; it is renewed on every connect.
+	IdentitySecret string
+	IdentityTTL    time.Duration
+
+	// WriteWait bounds each websocket write. The server pings every
+	// PingPeriod and drops clients that send nothing, not even a pong,
+	// within PongWait, so PingPeriod must be shorter than PongWait.
+	WriteWait  time.Duration
+	PongWait   time.Duration
+	PingPeriod time.Duration
+	// Compression negotiates permessage-deflate with clients that support
+	// it, trading CPU for bandwidth on large init messages.
+	// CompressionLevel is the flate level of each connection, from -2
+	// (Huffman only) and 1 (fastest) to 9 (smallest).
+	Compression      bool
+	CompressionLevel int
+	// ReadBufferSize and WriteBufferSize are the I/O buffer sizes of each
+	// connection. A write buffer the size of a typical init frame saves
+	// splitting it. SharedWriteBuffers lends write buffers from a pool
+	// while a message is written instead of keeping one per connection.
+	ReadBufferSize     int
+	WriteBufferSize    int
+	SharedWriteBuffers bool
+	// MaxMessageSize caps the bytes of a single websocket message from a
+	// client. Larger messages close the connection with 1009 (message too
+	// big).
+	MaxMessageSize int
+	// StrictMessages rejects placements carrying fields the server does
+	// not know, rather than ignoring them.
+	StrictMessages bool
+	// MaxClients caps the websocket connections across all rooms. Further
+	// connections are refused with 503. Zero means no limit.
+	MaxClients int
+	// MaxConnectionsPerIP caps the websocket connections from one client
+	// IP across all rooms. Zero means no limit.
+	MaxConnectionsPerIP int
+	// TrustedProxies are the proxy addresses or CIDRs whose
+	// X-Forwarded-For header is believed when working out a client's IP.
+	// With none, the connecting address is used.
+	TrustedProxies []string
+	// IdleTimeout disconnects clients that have sent neither a message nor
+	// a pong for this long. Zero disables it.
+	IdleTimeout time.Duration
+	// DrainGrace is how long POST /admin/drain waits for clients to
+	// reconnect elsewhere before closing the rest.
+	DrainGrace time.Duration
+	// MessageRate caps the websocket messages a connection may send per
+	// second, allowing bursts of MessageBurst. Messages over the rate are
+	// ignored and clients that keep flooding are disconnected. Zero
+	// disables the limit.
+	MessageRate  int
+	MessageBurst int
+	// SendBuffer is how many outgoing messages are queued per client. A
+	// larger buffer rides out bursts and slow links without tripping
+	// SendOverflow, at the cost of memory per client and of messages
+	// arriving later; a smaller one notices stalled clients sooner.
+	SendBuffer int
+	// SendOverflow decides what happens when a client's send buffer is
+	// full: "disconnect" drops the message and disconnects the client after
+	// SendOverflowLimit full buffers in a row; "drop_oldest" discards the
+	// oldest queued message to make room and keeps the client connected.
+	SendOverflow      string
+	SendOverflowLimit int
+	// BroadcastBuffer is how many placements may wait for a room's hub
+	// before BroadcastOverflow applies: "block" makes the sender wait and
+	// "drop" rejects the placement as busy and counts it. Zero leaves it
+	// unbuffered.
+	BroadcastBuffer   int
+	BroadcastOverflow string
+
+	BoardWidth  int
+	BoardHeight int
+	// MaxBoardCells caps BoardWidth*BoardHeight, and MaxBoardMemory the
+	// estimated bytes of all rooms' boards, so a typo in the size fails at
+	// startup instead of exhausting memory. Resizes are held to them too.
+	MaxBoardCells  int
+	MaxBoardMemory int
+	Cooldown       time.Duration
+	// FillColor is the color of a fresh or reset board.
+	FillColor Pixel
+	// Milestones are the percentages of painted cells announced to
+	// clients when the board first reaches them.
+	Milestones []int
+	// PlacementLimit selects how placements are throttled: "cooldown"
+	// waits Cooldown between pixels, "bucket" allows bursts of up to
+	// BucketCapacity pixels refilling one every BucketRefill, and "cell"
+	// lets nobody repaint a cell until Cooldown after it last changed.
+	PlacementLimit string
+	BucketCapacity int
+	BucketRefill   time.Duration
+	// TrustedUsers are the JWT subjects that bypass the placement limit,
+	// for moderators and event operators. They are ignored without JWT
+	// auth, since identities are then not authenticated.
+	TrustedUsers []string
+	// BotKeys are API keys of sanctioned bots, as key=name or
+	// key=name@cooldown. Bots place pixels over REST or websocket without
+	// a user JWT and wait their own cooldown, BotCooldown unless given,
+	// with PlacementLimit "cooldown". Their pixels are tagged as a bot's.
+	BotKeys     []string
+	BotCooldown time.Duration
+	// MaxOperationCells caps how many cells one fill, rectangle or line may
+	// paint.
+	MaxOperationCells int
+	// PaintLimit caps how many pixels one user may place within each
+	// PaintLimitRegion by PaintLimitRegion square of the board per
+	// PaintLimitWindow. Trusted users are exempt. Zero disables it.
+	PaintLimit       int
+	PaintLimitRegion int
+	PaintLimitWindow time.Duration
+	// ConsensusVotes, if above 1, turns placements into votes: a cell only
+	// changes once this many distinct users vote for the same color within
+	// ConsensusWindow. Fills, shapes and undo are disabled.
+	ConsensusVotes  int
+	ConsensusWindow time.Duration
+	// Palette restricts placements to these colors. Empty allows any color.
+	Palette []Pixel
+	// AlphaMode decides how translucent pixels are applied: "replace"
+	// stores them as sent, "blend" composites them over the current color.
+	AlphaMode string
+
+	// OpensAt and ClosesAt bound when the canvas accepts placements. Zero
+	// leaves that end open. Viewing is always allowed.
+	OpensAt  time.Time
+	ClosesAt time.Time
+
+	// UsernameCollision decides what happens when a username is already
+	// connected: "suffix" renames the newcomer to name#2, name#3, ...;
+	// "reject" refuses the connection with a username_taken message.
+	UsernameCollision string
+
+	// InitChunkRows splits the initial JSON board into init_chunk messages
+	// of this many rows, followed by init_complete, so no single frame holds
+	// the whole board. Zero sends it as one init message.
+	InitChunkRows int
+
+	// BatchWindow groups updates into one batch message per window. Zero
+	// broadcasts every update on its own.
+	BatchWindow time.Duration
+	// CursorInterval is the shortest time between two cursor moves of a
+	// client that are passed on to the others. Faster moves are dropped.
+	CursorInterval time.Duration
+	// ChecksumInterval is how often clients are sent a checksum of the
+	// board to detect drift. Zero disables it.
+	ChecksumInterval time.Duration
+	// ChangeLogSize is how many recent updates are kept for clients
+	// reconnecting with ?since= or sending a resync message. Clients further
+	// behind get a full board instead. It is at most 1,000,000.
+	ChangeLogSize int
+
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	// EventLogPath is where every applied change is appended as JSON
+	// lines. It is replayed over the snapshot on startup. Empty disables it.
+	EventLogPath string
+	// AuditSink selects where the audit trail of connections, placements
+	// and admin requests goes: "stdout", "file" (AuditPath) or "webhook"
+	// (AuditURL). Empty disables it.
+	AuditSink string
+	AuditPath string
+	AuditURL  string
+
+	// Rooms names the boards served besides the default one. Clients pick
+	// a room with ?room=name; each room has its own clients and board.
+	Rooms []string
+
+	// Store selects the board backend: "memory" or "redis". The redis store
+	// shares the board and its updates between server instances.
+	Store       string
+	RedisAddr   string
+	RedisPrefix string
+	// StoreBufferSize caps the cells held in memory while a shared store
+	// is unreachable. They are written back every StoreRetryInterval until
+	// it recovers; once the buffer is full, placements fail.
+	StoreBufferSize    int
+	StoreRetryInterval time.Duration
+}
+
+var config = DefaultConfig()
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:     defaultAddr,
+		LogLevel: slog.LevelInfo,
+
+		AllowedOrigins: []string{"http://localhost:5173"},
+
+		IdentityTTL: defaultIdentityTTL,
+
+		CompressionLevel: defaultCompressionLevel,
+		ReadBufferSize:   defaultBufferSize,
+		WriteBufferSize:  defaultBufferSize,
+
+		WriteWait:  defaultWriteWait,
+		PongWait:   defaultPongWait,
+		PingPeriod: defaultPingPeriod,
+
+		MaxMessageSize: defaultMaxMessageSize,
+
+		MessageRate:  defaultMessageRate,
+		MessageBurst: defaultMessageBurst,
+
+		SendBuffer:        defaultSendBuffer,
+		SendOverflow:      "disconnect",
+		SendOverflowLimit: 1,
+
+		BroadcastBuffer:   defaultBroadcastBuffer,
+		BroadcastOverflow: "block",
+
+		BoardWidth:  defaultBoardWidth,
+		BoardHeight: defaultBoardHeight,
+		Cooldown:    defaultCooldown,
+		FillColor:   defaultFillColor,
+		Milestones:  []int{25, 50, 75, 100},
+
+		MaxBoardCells:  defaultMaxBoardCells,
+		MaxBoardMemory: defaultMaxBoardMemory,
+
+		PlacementLimit: "cooldown",
+		BucketCapacity: defaultBucketCapacity,
+		BucketRefill:   defaultBucketRefill,
+
+		BotCooldown: defaultBotCooldown,
+
+		MaxOperationCells: defaultMaxOperationCells,
+
+		PaintLimitRegion: defaultPaintLimitRegion,
+		PaintLimitWindow: defaultPaintLimitWindow,
+
+		ConsensusWindow: defaultConsensusWindow,
+
+		DrainGrace: defaultDrainGrace,
+
+		AlphaMode:         "replace",
+		UsernameCollision: "suffix",
+
+		CursorInterval: defaultCursorInterval,
+
+		ChangeLogSize: defaultChangeLogSize,
+
+		SnapshotPath:     defaultSnapshotPath,
+		SnapshotInterval: defaultSnapshotInterval,
+
+		Store:       "memory",
+		RedisAddr:   "localhost:6379",
+		RedisPrefix: defaultRedisPrefix,
+
+		StoreBufferSize:    defaultStoreBufferSize,
+		StoreRetryInterval: defaultStoreRetryInterval,
+
+		AuditPath: defaultAuditPath,
+	}
+}
+
+// LoadConfig reads the RPLACE_* environment variables on top of the defaults.
+func LoadConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Addr = envString("RPLACE_ADDR", cfg.Addr)
+	cfg.TLSCert = envString("RPLACE_TLS_CERT", cfg.TLSCert)
+	cfg.TLSKey = envString("RPLACE_TLS_KEY", cfg.TLSKey)
+	cfg.HTTPRedirectAddr = envString("RPLACE_HTTP_REDIRECT_ADDR", cfg.HTTPRedirectAddr)
+	cfg.LogLevel = envLevel("RPLACE_LOG_LEVEL", cfg.LogLevel)
+	cfg.AllowedOrigins = envList("RPLACE_ALLOWED_ORIGINS", cfg.AllowedOrigins)
+	cfg.AdminToken = envString("RPLACE_ADMIN_TOKEN", cfg.AdminToken)
+	cfg.JWTSecret = envString("RPLACE_JWT_SECRET", cfg.JWTSecret)
+	cfg.IdentitySecret = envString("RPLACE_IDENTITY_SECRET", cfg.IdentitySecret)
+	cfg.IdentityTTL = envDuration("RPLACE_IDENTITY_TTL", cfg.IdentityTTL)
+	cfg.WriteWait = envDuration("RPLACE_WRITE_WAIT", cfg.WriteWait)
+	cfg.PongWait = envDuration("RPLACE_PONG_WAIT", cfg.PongWait)
+	cfg.PingPeriod = envDuration("RPLACE_PING_PERIOD", cfg.PingPeriod)
+	cfg.Compression = envBool("RPLACE_COMPRESSION", cfg.Compression)
+	cfg.CompressionLevel = envCompressionLevel("RPLACE_COMPRESSION_LEVEL", cfg.CompressionLevel)
+	cfg.ReadBufferSize = envInt("RPLACE_READ_BUFFER_SIZE", cfg.ReadBufferSize)
+	cfg.WriteBufferSize = envInt("RPLACE_WRITE_BUFFER_SIZE", cfg.WriteBufferSize)
+	cfg.SharedWriteBuffers = envBool("RPLACE_SHARED_WRITE_BUFFERS", cfg.SharedWriteBuffers)
+	cfg.MaxMessageSize = envInt("RPLACE_MAX_MESSAGE_SIZE", cfg.MaxMessageSize)
+	cfg.StrictMessages = envBool("RPLACE_STRICT_MESSAGES", cfg.StrictMessages)
+	cfg.MaxClients = envNonNegativeInt("RPLACE_MAX_CLIENTS", cfg.MaxClients)
+	cfg.MaxConnectionsPerIP = envNonNegativeInt("RPLACE_MAX_CONNECTIONS_PER_IP", cfg.MaxConnectionsPerIP)
+	cfg.TrustedProxies = envList("RPLACE_TRUSTED_PROXIES", cfg.TrustedProxies)
+	cfg.IdleTimeout = envDuration("RPLACE_IDLE_TIMEOUT", cfg.IdleTimeout)
+	cfg.DrainGrace = envDuration("RPLACE_DRAIN_GRACE", cfg.DrainGrace)
+	cfg.MessageRate = envNonNegativeInt("RPLACE_MESSAGE_RATE", cfg.MessageRate)
+	cfg.MessageBurst = envInt("RPLACE_MESSAGE_BURST", cfg.MessageBurst)
+	cfg.SendBuffer = envInt("RPLACE_SEND_BUFFER", cfg.SendBuffer)
+	cfg.SendOverflow = envString("RPLACE_SEND_OVERFLOW", cfg.SendOverflow)
+	cfg.SendOverflowLimit = envInt("RPLACE_SEND_OVERFLOW_LIMIT", cfg.SendOverflowLimit)
+	cfg.BroadcastBuffer = envNonNegativeInt("RPLACE_BROADCAST_BUFFER", cfg.BroadcastBuffer)
+	cfg.BroadcastOverflow = envString("RPLACE_BROADCAST_OVERFLOW", cfg.BroadcastOverflow)
+	cfg.BoardWidth = envInt("RPLACE_BOARD_WIDTH", cfg.BoardWidth)
+	cfg.BoardHeight = envInt("RPLACE_BOARD_HEIGHT", cfg.BoardHeight)
+	cfg.MaxBoardCells = envInt("RPLACE_MAX_BOARD_CELLS", cfg.MaxBoardCells)
+	cfg.MaxBoardMemory = envInt("RPLACE_MAX_BOARD_MEMORY", cfg.MaxBoardMemory)
+	cfg.FillColor = envColor("RPLACE_FILL_COLOR", cfg.FillColor)
+	cfg.Milestones = envPercents("RPLACE_MILESTONES", cfg.Milestones)
+	cfg.Cooldown = envDuration("RPLACE_COOLDOWN", cfg.Cooldown)
+	cfg.PlacementLimit = envString("RPLACE_PLACEMENT_LIMIT", cfg.PlacementLimit)
+	cfg.BucketCapacity = envInt("RPLACE_BUCKET_CAPACITY", cfg.BucketCapacity)
+	cfg.BucketRefill = envDuration("RPLACE_BUCKET_REFILL", cfg.BucketRefill)
+	cfg.TrustedUsers = envList("RPLACE_TRUSTED_USERS", cfg.TrustedUsers)
+	cfg.BotKeys = envList("RPLACE_BOT_KEYS", cfg.BotKeys)
+	cfg.BotCooldown = envDuration("RPLACE_BOT_COOLDOWN", cfg.BotCooldown)
+	cfg.MaxOperationCells = envInt("RPLACE_MAX_OPERATION_CELLS", cfg.MaxOperationCells)
+	cfg.PaintLimit = envNonNegativeInt("RPLACE_PAINT_LIMIT", cfg.PaintLimit)
+	cfg.PaintLimitRegion = envInt("RPLACE_PAINT_LIMIT_REGION", cfg.PaintLimitRegion)
+	cfg.PaintLimitWindow = envDuration("RPLACE_PAINT_LIMIT_WINDOW", cfg.PaintLimitWindow)
+	cfg.ConsensusVotes = envNonNegativeInt("RPLACE_CONSENSUS_VOTES", cfg.ConsensusVotes)
+	cfg.ConsensusWindow = envDuration("RPLACE_CONSENSUS_WINDOW", cfg.ConsensusWindow)
+	cfg.Palette = envPalette("RPLACE_PALETTE", cfg.Palette)
+	cfg.AlphaMode = envString("RPLACE_ALPHA_MODE", cfg.AlphaMode)
+	cfg.OpensAt = envTime("RPLACE_OPENS_AT", cfg.OpensAt)
+	cfg.ClosesAt = envTime("RPLACE_CLOSES_AT", cfg.ClosesAt)
+	cfg.UsernameCollision = envString("RPLACE_USERNAME_COLLISION", cfg.UsernameCollision)
+	cfg.InitChunkRows = envNonNegativeInt("RPLACE_INIT_CHUNK_ROWS", cfg.InitChunkRows)
+	cfg.BatchWindow = envDuration("RPLACE_BATCH_WINDOW", cfg.BatchWindow)
+	cfg.CursorInterval = envDuration("RPLACE_CURSOR_INTERVAL", cfg.CursorInterval)
+	cfg.ChecksumInterval = envDuration("RPLACE_CHECKSUM_INTERVAL", cfg.ChecksumInterval)
+	cfg.ChangeLogSize = envInt("RPLACE_CHANGE_LOG_SIZE", cfg.ChangeLogSize)
+	cfg.SnapshotPath = envString("RPLACE_SNAPSHOT_PATH", cfg.SnapshotPath)
+	cfg.SnapshotInterval = envDuration("RPLACE_SNAPSHOT_INTERVAL", cfg.SnapshotInterval)
+	cfg.EventLogPath = envString("RPLACE_EVENT_LOG", cfg.EventLogPath)
+	cfg.AuditSink = envString("RPLACE_AUDIT_SINK", cfg.AuditSink)
+	cfg.AuditPath = envString("RPLACE_AUDIT_PATH", cfg.AuditPath)
+	cfg.AuditURL = envString("RPLACE_AUDIT_URL", cfg.AuditURL)
+	cfg.Rooms = envList("RPLACE_ROOMS", cfg.Rooms)
+	cfg.Store = envString("RPLACE_STORE", cfg.Store)
+	cfg.RedisAddr = envString("RPLACE_REDIS_ADDR", cfg.RedisAddr)
+	cfg.RedisPrefix = envString("RPLACE_REDIS_PREFIX", cfg.RedisPrefix)
+	cfg.StoreBufferSize = envInt("RPLACE_STORE_BUFFER_SIZE", cfg.StoreBufferSize)
+	cfg.StoreRetryInterval = envDuration("RPLACE_STORE_RETRY_INTERVAL", cfg.StoreRetryInterval)
+	return cfg
+}
+
+// Configure applies cfg to the server. It must be called before the hub is started.
+func Configure(cfg Config) error {
+	config = cfg
+	logLevel.Set(cfg.LogLevel)
+	upgrader.EnableCompression = cfg.Compression
+	upgrader.ReadBufferSize = cfg.ReadBufferSize
+	upgrader.WriteBufferSize = cfg.WriteBufferSize
+	upgrader.WriteBufferPool = nil
+	if cfg.SharedWriteBuffers {
+		upgrader.WriteBufferPool = writeBufferPool
+	}
+	setTrustedUsers(cfg.TrustedUsers)
+	if len(cfg.TrustedUsers) > 0 && cfg.JWTSecret == "" {
+		logger.Warn("Trusted users are ignored without JWT auth", "users", len(cfg.TrustedUsers))
+	}
+	if err := setBotKeys(cfg.BotKeys, cfg.BotCooldown); err != nil {
+		return err
+	}
+	if err := setIdentityKey(cfg.IdentitySecret); err != nil {
+		return err
+	}
+
+	addr, err := resolveAddr(cfg.Addr)
+	if err != nil {
+		return err
+	}
+	config.Addr = addr
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return fmt.Errorf("TLS needs both a certificate and a key")
+	}
+	if cfg.HTTPRedirectAddr != "" {
+		if cfg.TLSCert == "" {
+			return fmt.Errorf("HTTP redirect needs TLS to be enabled")
+		}
+		if config.HTTPRedirectAddr, err = resolveAddr(cfg.HTTPRedirectAddr); err != nil {
+			return err
+		}
+	}
+	if cfg.PingPeriod <= 0 || cfg.PingPeriod >= cfg.PongWait {
+		return fmt.Errorf("ping period %s must be positive and shorter than pong wait %s", cfg.PingPeriod, cfg.PongWait)
+	}
+	if err := checkBoardSize(cfg.BoardWidth, cfg.BoardHeight, 1+len(cfg.Rooms)); err != nil {
+		return err
+	}
+	if cfg.IdentityTTL <= 0 {
+		return fmt.Errorf("identity TTL must be positive")
+	}
+	if cfg.CompressionLevel < flate.HuffmanOnly || cfg.CompressionLevel > flate.BestCompression {
+		return fmt.Errorf("compression level %d must be between %d and %d", cfg.CompressionLevel, flate.HuffmanOnly, flate.BestCompression)
+	}
+	if cfg.MaxMessageSize < 1 {
+		return fmt.Errorf("max message size must be positive")
+	}
+	if cfg.MessageRate > 0 && cfg.MessageBurst < 1 {
+		return fmt.Errorf("message burst must be at least 1")
+	}
+	if cfg.SendOverflow != "disconnect" && cfg.SendOverflow != "drop_oldest" {
+		return fmt.Errorf("unknown send overflow policy %q", cfg.SendOverflow)
+	}
+	if cfg.SendOverflow == "disconnect" && cfg.SendOverflowLimit < 1 {
+		return fmt.Errorf("send overflow limit must be at least 1")
+	}
+	if cfg.BroadcastBuffer < 0 {
+		return fmt.Errorf("broadcast buffer must not be negative")
+	}
+	if cfg.BroadcastOverflow != "block" && cfg.BroadcastOverflow != "drop" {
+		return fmt.Errorf("unknown broadcast overflow policy %q", cfg.BroadcastOverflow)
+	}
+	if cfg.ChangeLogSize > maxChangeLogSize {
+		return fmt.Errorf("change log size %d exceeds the maximum of %d", cfg.ChangeLogSize, maxChangeLogSize)
+	}
+	if cfg.PlacementLimit != "cooldown" && cfg.PlacementLimit != "bucket" && cfg.PlacementLimit != "cell" {
+		return fmt.Errorf("unknown placement limit %q", cfg.PlacementLimit)
+	}
+	if cfg.PlacementLimit == "bucket" && cfg.BucketRefill <= 0 {
+		return fmt.Errorf("bucket refill must be positive")
+	}
+	for i, percent := range cfg.Milestones {
+		if percent < 1 || percent > 100 || (i > 0 && percent <= cfg.Milestones[i-1]) {
+			return fmt.Errorf("milestones must be increasing percentages from 1 to 100")
+		}
+	}
+	if cfg.PaintLimit > 0 && (cfg.PaintLimitRegion < 1 || cfg.PaintLimitWindow <= 0) {
+		return fmt.Errorf("paint limit region and window must be positive")
+	}
+	if cfg.ConsensusVotes > 1 && cfg.ConsensusWindow <= 0 {
+		return fmt.Errorf("consensus window must be positive")
+	}
+	if cfg.Store == "redis" && (cfg.StoreBufferSize < 1 || cfg.StoreRetryInterval <= 0) {
+		return fmt.Errorf("store buffer size and retry interval must be positive")
+	}
+	if cfg.AlphaMode != "replace" && cfg.AlphaMode != "blend" {
+		return fmt.Errorf("unknown alpha mode %q", cfg.AlphaMode)
+	}
+	if !cfg.OpensAt.IsZero() && !cfg.ClosesAt.IsZero() && !cfg.ClosesAt.After(cfg.OpensAt) {
+		return fmt.Errorf("canvas must close after it opens")
+	}
+	if cfg.UsernameCollision != "suffix" && cfg.UsernameCollision != "reject" {
+		return fmt.Errorf("unknown username collision policy %q", cfg.UsernameCollision)
+	}
+
+	if auditSink, err = newAuditSink(cfg); err != nil {
+		return err
+	}
+
+	hub, err := newRoom(defaultRoom, cfg)
+	if err != nil {
+		return err
+	}
+	HubInstance = hub
+	rooms = map[string]*Hub{defaultRoom: hub}
+	for _, name := range cfg.Rooms {
+		if !roomNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid room name %q", name)
+		}
+		if _, ok := rooms[name]; ok {
+			return fmt.Errorf("duplicate room %q", name)
+		}
+		if rooms[name], err = newRoom(name, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrustedProxies returns the proxies allowed to set X-Forwarded-For, for
+// gin's Engine.SetTrustedProxies.
+func TrustedProxies() []string {
+	return config.TrustedProxies
+}
+
+// ListenAddr returns the configured address for the HTTP server.
+func ListenAddr() string {
+	return config.Addr
+}
+
+// resolveAddr normalizes a listen address: a bare port such as "8080"
+// listens on all interfaces, and an empty address uses the default.
+func resolveAddr(addr string) (string, error) {
+	if addr == "" {
+		return defaultAddr, nil
+	}
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid port %q in listen address %q", port, addr)
+	}
+	return addr, nil
+}
+
+func envString(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func envBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return b
+}
+
+// envCompressionLevel reads a flate compression level. Unlike envInt it
+// accepts zero and the negative levels.
+func envCompressionLevel(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < flate.HuffmanOnly || n > flate.BestCompression {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+// envNonNegativeInt is envInt for settings where zero turns the feature
+// off or means no limit.
+func envNonNegativeInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+// envTime reads an RFC 3339 timestamp.
+func envTime(key string, fallback time.Time) time.Time {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return t
+}
+
+func envLevel(key string, fallback slog.Level) slog.Level {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return level
+}
+
+// envList reads a comma separated list.
+func envList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envPercents reads a comma separated list of whole percentages.
+func envPercents(key string, fallback []int) []int {
+	items := envList(key, nil)
+	if items == nil {
+		return fallback
+	}
+	percents := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 1 || n > 100 {
+			logger.Warn("Invalid config value, using default", "key", key, "value", item, "default", fallback)
+			return fallback
+		}
+		percents = append(percents, n)
+	}
+	return percents
+}
+
+// envColor reads a single hex color such as #RRGGBB.
+func envColor(key string, fallback Pixel) Pixel {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	p, err := parseHexColor(value)
+	if err != nil {
+		logger.Warn("Invalid config value, using default", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return p
+}
+
+// envPalette reads a comma separated list of #RRGGBB colors.
+func envPalette(key string, fallback []Pixel) []Pixel {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var palette []Pixel
+	for _, hex := range strings.Split(value, ",") {
+		p, err := parseHexColor(strings.TrimSpace(hex))
+		if err != nil {
+			logger.Warn("Invalid config value, using default", "key", key, "value", value, "error", err)
+			return fallback
+		}
+		palette = append(palette, p)
+	}
+	return palette
+}
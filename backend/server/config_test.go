@@ -0,0 +1,76 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveAddr(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"", ":8080"},
+		{"9000", ":9000"},
+		{":9000", ":9000"},
+		{"127.0.0.1:9000", "127.0.0.1:9000"},
+		{"[::1]:9000", "[::1]:9000"},
+		{"localhost:0", "localhost:0"},
+	} {
+		if got, err := resolveAddr(tc.in); err != nil || got != tc.want {
+			t.Errorf("resolveAddr(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{"80800", ":http", "host:-1", "a:b:c"} {
+		if got, err := resolveAddr(in); err == nil {
+			t.Errorf("resolveAddr(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	t.Setenv("RPLACE_ADDR", "9000")
+	if addr := LoadConfig().Addr; addr != "9000" {
+		t.Fatalf("RPLACE_ADDR read as %q", addr)
+	}
+	startServer(t, func(cfg *Config) { cfg.Addr = "9000" })
+	if addr := ListenAddr(); addr != ":9000" {
+		t.Fatalf("ListenAddr() = %q, want :9000", addr)
+	}
+}
+
+func TestOversizedBoardRejected(t *testing.T) {
+	useConfig(t, nil)
+	for _, tc := range []struct {
+		name string
+		edit func(*Config)
+		want string
+	}{
+		{"cells", func(cfg *Config) { cfg.BoardWidth, cfg.BoardHeight = 100000, 100000 }, "exceeds the maximum of"},
+		{"memory", func(cfg *Config) {
+			cfg.BoardWidth, cfg.BoardHeight = 1000, 1000
+			cfg.MaxBoardMemory = 1 << 20
+		}, "MiB, over the maximum"},
+		{"memory across rooms", func(cfg *Config) {
+			cfg.BoardWidth, cfg.BoardHeight = 1000, 1000
+			cfg.MaxBoardMemory = 3 * 1000 * 1000 * cellBytes
+			cfg.Rooms = []string{"a", "b", "c"}
+		}, "in 4 rooms"},
+		{"zero", func(cfg *Config) { cfg.BoardWidth = 0 }, errBadBoardDimensions.Error()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Configure(testConfig(t, tc.edit))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+
+	t.Run("raised limit", func(t *testing.T) {
+		if err := checkBoardSize(5000, 5000, 1); err == nil {
+			t.Fatal("25M cells passed the default limit")
+		}
+		config.MaxBoardCells = 25_000_000
+		config.MaxBoardMemory = 25_000_000 * cellBytes
+		if err := checkBoardSize(5000, 5000, 1); err != nil {
+			t.Fatalf("raised limits: %v", err)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errConsensusOnly = errors.New("only single pixels can be placed in consensus mode")
+
+// VoteMessage tells a client its vote for a color was counted but the
+// cell has not changed yet.
+type VoteMessage struct {
+	Type   string `json:"type"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Pixel  Pixel  `json:"pixel"`
+	Votes  int    `json:"votes"`
+	Needed int    `json:"needed"`
+	ReqID  string `json:"reqId,omitempty"`
+}
+
+// voteError reports a placement that was counted as a vote instead of
+// applied. It is not sent to clients as an error.
+type voteError struct {
+	votes int
+}
+
+func (e *voteError) Error() string {
+	return "vote counted"
+}
+
+func consensusEnabled() bool {
+	return config.ConsensusVotes > 1
+}
+
+// vote counts message as its sender's vote for its color on its cell at
+// now, and returns how many distinct users voted for that color within
+// config.ConsensusWindow. It is only called from the hub's Run loop.
+func (h *Hub) vote(message Update, now time.Time) int {
+	c := cell{message.X, message.Y}
+	colors, ok := h.votes[c]
+	if !ok {
+		colors = make(map[Pixel]map[uuid.UUID]time.Time)
+		h.votes[c] = colors
+	}
+	voters, ok := colors[message.Pixel]
+	if !ok {
+		voters = make(map[uuid.UUID]time.Time)
+		colors[message.Pixel] = voters
+	}
+	voters[message.SenderUUID] = now
+	expireVotes(voters, now)
+	return len(voters)
+}
+
+// clearVotes forgets the votes on c once it has changed.
+func (h *Hub) clearVotes(c cell) {
+	delete(h.votes, c)
+}
+
+// pruneVotes forgets votes older than config.ConsensusWindow.
+func (h *Hub) pruneVotes(now time.Time) {
+	for c, colors := range h.votes {
+		for color, voters := range colors {
+			if expireVotes(voters, now); len(voters) == 0 {
+				delete(colors, color)
+			}
+		}
+		if len(colors) == 0 {
+			delete(h.votes, c)
+		}
+	}
+}
+
+func expireVotes(voters map[uuid.UUID]time.Time, now time.Time) {
+	for id, at := range voters {
+		if now.Sub(at) >= config.ConsensusWindow {
+			delete(voters, id)
+		}
+	}
+}
+
+// sendVote tells the sender of message that its vote was counted.
+func (h *Hub) sendVote(message Update, votes int) {
+	h.sendTo(message.SenderUUID, VoteMessage{
+		Type:   "vote",
+		X:      message.X,
+		Y:      message.Y,
+		Pixel:  message.Pixel,
+		Votes:  votes,
+		Needed: config.ConsensusVotes,
+		ReqID:  message.ReqID,
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestConsensusVotes(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.ConsensusVotes = 3
+		cfg.ConsensusWindow = time.Minute
+	})
+	h := newTestHub(8, 8)
+	clock := useFakeClock(h)
+	voters := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	var pending *voteError
+	if err := applyAs(h, voters[0], 1, 1); !errors.As(err, &pending) || pending.votes != 1 {
+		t.Fatalf("first vote: got %v, want 1 vote counted", err)
+	}
+	if p, _ := h.store.Get(1, 1); p != config.FillColor {
+		t.Fatalf("a single vote changed the cell to %v", p)
+	}
+	// Voting again counts once, and other colors are counted apart.
+	if err := applyAs(h, voters[0], 1, 1); !errors.As(err, &pending) || pending.votes != 1 {
+		t.Fatalf("repeated vote: got %v, want still 1 vote", err)
+	}
+	red := Update{Type: "update", X: 1, Y: 1, Pixel: Pixel{R: 255, A: 255}, SenderUUID: voters[1]}
+	if _, err := h.applyUpdate(red); !errors.As(err, &pending) || pending.votes != 1 {
+		t.Fatalf("vote for another color: got %v, want 1 vote", err)
+	}
+
+	if err := applyAs(h, voters[1], 1, 1); !errors.As(err, &pending) || pending.votes != 2 {
+		t.Fatalf("second vote: got %v, want 2 votes", err)
+	}
+	if err := applyAs(h, voters[2], 1, 1); err != nil {
+		t.Fatalf("vote reaching the threshold: %v", err)
+	}
+	if p, _ := h.store.Get(1, 1); p != (Pixel{A: 255}) {
+		t.Fatalf("cell is %v after reaching the threshold, want black", p)
+	}
+	if len(h.votes) != 0 {
+		t.Fatalf("votes on the changed cell were kept: %v", h.votes)
+	}
+
+	// Votes older than the window no longer count.
+	applyAs(h, voters[0], 2, 2)
+	clock.advance(30 * time.Second)
+	applyAs(h, voters[1], 2, 2)
+	clock.advance(30 * time.Second)
+	if err := applyAs(h, voters[2], 2, 2); !errors.As(err, &pending) || pending.votes != 2 {
+		t.Fatalf("vote after the first expired: got %v, want 2 votes", err)
+	}
+	clock.advance(time.Minute)
+	h.pruneLimits(clock.Now())
+	if len(h.votes) != 0 {
+		t.Fatalf("stale votes were kept: %v", h.votes)
+	}
+}
+
+func TestConsensusRejectsOperations(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.ConsensusVotes = 2 })
+	h := newTestHub(8, 8)
+	if _, err := h.paint(Update{Type: "fill", Pixel: Pixel{A: 255}, SenderUUID: uuid.New()}, []cell{{0, 0}}); !errors.Is(err, errConsensusOnly) {
+		t.Fatalf("fill in consensus mode: got %v, want %v", err, errConsensusOnly)
+	}
+}
+
+func TestConsensusOverWebsocket(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.ConsensusVotes = 2 })
+	alice, bob := dial(t, srv, "username=alice"), dial(t, srv, "username=bob")
+	readType(t, alice, "init", &InitBoardState{})
+	readType(t, bob, "init", &InitBoardState{})
+
+	send(t, alice, map[string]any{"type": "update", "x": 4, "y": 4, "color": "#000000", "reqId": "1"})
+	var vote VoteMessage
+	readType(t, alice, "vote", &vote)
+	if vote.Votes != 1 || vote.Needed != 2 || vote.ReqID != "1" {
+		t.Fatalf("got %+v, want 1 of 2 votes for request 1", vote)
+	}
+
+	place(t, bob, 4, 4, "#000000")
+	var update Update
+	readType(t, alice, "update", &update)
+	if update.X != 4 || update.Y != 4 {
+		t.Fatalf("alice got an update for (%d, %d), want (4, 4)", update.X, update.Y)
+	}
+}
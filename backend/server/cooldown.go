@@ -0,0 +1,110 @@
+package server
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tokenBucket lets a client place a burst of pixels after being idle. It
+// holds up to config.BucketCapacity tokens and gains one every
+// config.BucketRefill.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill returns the tokens available at now without modifying the bucket.
+func (b *tokenBucket) refill(now time.Time) float64 {
+	tokens := b.tokens + float64(now.Sub(b.updated))/float64(config.BucketRefill)
+	return min(tokens, float64(config.BucketCapacity))
+}
+
+// cooldownRemaining reports how long id has to wait at now before it may
+// place another pixel. It is only called from the hub's Run loop.
+func (h *Hub) cooldownRemaining(id uuid.UUID, now time.Time) time.Duration {
+	if config.PlacementLimit == "bucket" {
+		bucket, ok := h.buckets[id]
+		if !ok {
+			return 0
+		}
+		tokens := bucket.refill(now)
+		if tokens >= 1 {
+			return 0
+		}
+		return time.Duration((1 - tokens) * float64(config.BucketRefill))
+	}
+
+	last, ok := h.cooldowns[id]
+	if !ok {
+		return 0
+	}
+	remaining := last.Add(cooldownFor(id)).Sub(now)
+	if remaining <= 0 {
+		// Identities placing over REST never unregister, so expired
+		// entries are dropped here instead.
+		delete(h.cooldowns, id)
+	}
+	return remaining
+}
+
+// recordPlacement charges id for painting cells at now. With
+// PlacementLimit "cell" the cells cool down instead of the user.
+func (h *Hub) recordPlacement(id uuid.UUID, cells []cell, now time.Time) {
+	switch config.PlacementLimit {
+	case "cell":
+		for _, c := range cells {
+			h.hotCells[c] = now
+		}
+	case "bucket":
+		bucket, ok := h.buckets[id]
+		if !ok {
+			bucket = &tokenBucket{tokens: float64(config.BucketCapacity), updated: now}
+			h.buckets[id] = bucket
+		}
+		bucket.tokens = bucket.refill(now) - 1
+		bucket.updated = now
+	default:
+		h.cooldowns[id] = now
+	}
+}
+
+// cellCooldownRemaining reports how long c has to wait at now before it may
+// be painted again, by anyone. Cells only cool down with PlacementLimit
+// "cell", when h.hotCells holds when each was last painted. It is only
+// called from the hub's Run loop.
+func (h *Hub) cellCooldownRemaining(c cell, now time.Time) time.Duration {
+	last, ok := h.hotCells[c]
+	if !ok {
+		return 0
+	}
+	remaining := last.Add(config.Cooldown).Sub(now)
+	if remaining <= 0 {
+		delete(h.hotCells, c)
+	}
+	return remaining
+}
+
+// pruneLimits forgets cooldowns that have run out and buckets that have
+// refilled, which behave the same as no entry. Identities keep their
+// entries across reconnects, so they are not dropped on disconnect. It is
+// only called from the hub's Run loop.
+func (h *Hub) pruneLimits(now time.Time) {
+	for id, last := range h.cooldowns {
+		if !now.Before(last.Add(cooldownFor(id))) {
+			delete(h.cooldowns, id)
+		}
+	}
+	for c, last := range h.hotCells {
+		if !now.Before(last.Add(config.Cooldown)) {
+			delete(h.hotCells, c)
+		}
+	}
+	for id, bucket := range h.buckets {
+		if bucket.refill(now) >= float64(config.BucketCapacity) {
+			delete(h.buckets, id)
+		}
+	}
+	h.prunePaints(now)
+	h.pruneVotes(now)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCooldownRejectsSecondPlacement(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Cooldown = 5 * time.Minute })
+	conn := dial(t, srv, "username=a")
+
+	place(t, conn, 0, 0, "#000000")
+	reply := request(t, conn, map[string]any{"type": "update", "x": 1, "y": 0, "color": "#000000"})
+	if reply.Type != "nack" || reply.Code != "cooldown" {
+		t.Fatalf("got %s %q, want nack cooldown", reply.Type, reply.Code)
+	}
+	remaining := time.Duration(reply.RemainingMs) * time.Millisecond
+	if remaining <= 4*time.Minute || remaining > 5*time.Minute {
+		t.Fatalf("remaining cooldown is %v, want just under 5m", remaining)
+	}
+	if p, _ := HubInstance.store.Get(1, 0); p != config.FillColor {
+		t.Fatalf("rejected pixel was applied: %v", p)
+	}
+}
+
+func TestTokenBucketBurstThenThrottle(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.PlacementLimit = "bucket"
+		cfg.BucketCapacity = 3
+		cfg.BucketRefill = 10 * time.Second
+	})
+	h := newTestHub(8, 8)
+	clock := useFakeClock(h)
+	id := uuid.New()
+
+	for x := range 3 {
+		if err := applyAs(h, id, x, 0); err != nil {
+			t.Fatalf("placement %d of the burst: %v", x+1, err)
+		}
+	}
+	var cooldown *cooldownError
+	if err := applyAs(h, id, 3, 0); !errors.As(err, &cooldown) || cooldown.remaining != 10*time.Second {
+		t.Fatalf("placement past the burst: got %v, want a 10s cooldown", err)
+	}
+
+	// One token comes back per refill interval.
+	clock.advance(6 * time.Second)
+	if err := applyAs(h, id, 3, 0); !errors.As(err, &cooldown) || cooldown.remaining != 4*time.Second {
+		t.Fatalf("placement before a token is back: got %v, want a 4s cooldown", err)
+	}
+	clock.advance(4 * time.Second)
+	if err := applyAs(h, id, 3, 0); err != nil {
+		t.Fatalf("placement once a token is back: %v", err)
+	}
+	if err := applyAs(h, id, 4, 0); !errors.As(err, &cooldown) {
+		t.Fatalf("second placement on one token: got %v, want a cooldown", err)
+	}
+
+	// An idle bucket refills to its capacity and no further.
+	clock.advance(time.Hour)
+	for x := range 3 {
+		if err := applyAs(h, id, x, 1); err != nil {
+			t.Fatalf("placement %d of the second burst: %v", x+1, err)
+		}
+	}
+	if err := applyAs(h, id, 3, 1); !errors.As(err, &cooldown) {
+		t.Fatalf("placement past the second burst: got %v, want a cooldown", err)
+	}
+}
+
+func TestGetCooldown(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Cooldown = time.Minute })
+	// Swapped on the Run loop, which reads the clock.
+	var clock *fakeClock
+	HubInstance.do(func() { clock = useFakeClock(HubInstance) })
+
+	remaining := func() int64 {
+		t.Helper()
+		var body struct{ RemainingMs int64 }
+		if status := getJSON(t, srv, "/cooldown", &body); status != http.StatusOK {
+			t.Fatalf("GET /cooldown: status %d", status)
+		}
+		return body.RemainingMs
+	}
+	if ms := remaining(); ms != 0 {
+		t.Fatalf("cooldown is %dms before placing, want 0", ms)
+	}
+
+	if status := postJSON(t, srv, "/pixel", map[string]any{"x": 0, "y": 0}, nil, nil); status != http.StatusOK {
+		t.Fatalf("POST /pixel: status %d", status)
+	}
+	if ms := remaining(); ms != time.Minute.Milliseconds() {
+		t.Fatalf("cooldown is %dms right after placing, want %d", ms, time.Minute.Milliseconds())
+	}
+	clock.advance(20 * time.Second)
+	if ms := remaining(); ms != (40 * time.Second).Milliseconds() {
+		t.Fatalf("cooldown is %dms 20s after placing, want 40000", ms)
+	}
+	clock.advance(time.Minute)
+	if ms := remaining(); ms != 0 {
+		t.Fatalf("cooldown is %dms after it ran out, want 0", ms)
+	}
+}
+
+func TestCellCooldown(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.PlacementLimit = "cell"
+		cfg.Cooldown = time.Minute
+	})
+	h := newTestHub(8, 8)
+	clock := useFakeClock(h)
+	alice, bob := uuid.New(), uuid.New()
+
+	if err := applyAs(h, alice, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	var cooldown *cooldownError
+	if err := applyAs(h, bob, 1, 1); !errors.As(err, &cooldown) || cooldown.remaining != time.Minute {
+		t.Fatalf("repainting a hot cell: got %v, want a cooldown of 1m", err)
+	}
+	// The cell cools down, not the user.
+	if err := applyAs(h, alice, 2, 1); err != nil {
+		t.Fatalf("painting another cell: %v", err)
+	}
+
+	// Operations skip hot cells.
+	painted, err := h.paint(Update{Type: "fill", Pixel: Pixel{R: 255, A: 255}, SenderUUID: bob}, []cell{{1, 1}, {3, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(painted) != 1 || painted[0].X != 3 {
+		t.Fatalf("painted %+v, want only (3, 1)", painted)
+	}
+
+	clock.advance(time.Minute)
+	if err := applyAs(h, bob, 1, 1); err != nil {
+		t.Fatalf("repainting once the cell cooled down: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCORS(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		allowed []string
+		origin  string
+		ok      bool
+	}{
+		{"listed", []string{"https://place.example"}, "https://place.example", true},
+		{"not listed", []string{"https://place.example"}, "https://evil.example", false},
+		{"wildcard", []string{"*"}, "https://evil.example", true},
+		{"none configured", nil, "https://place.example", false},
+		{"no origin", nil, "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := startServer(t, func(cfg *Config) { cfg.AllowedOrigins = tc.allowed })
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/board", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if ok := resp.StatusCode == http.StatusOK; ok != tc.ok {
+				t.Fatalf("GET /board from %q: %d", tc.origin, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); tc.ok && got != tc.origin {
+				t.Fatalf("Access-Control-Allow-Origin is %q, want %q", got, tc.origin)
+			}
+
+			ws := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=a"
+			header := http.Header{}
+			if tc.origin != "" {
+				header.Set("Origin", tc.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(ws, header)
+			if ok := err == nil; ok != tc.ok {
+				t.Fatalf("websocket from %q: %v", tc.origin, err)
+			}
+			if conn != nil {
+				conn.Close()
+			} else if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("refused websocket got status %d, want 403", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import "time"
+
+// CursorMessage tells clients where another user is pointing. ID matches
+// the user's presence messages. Cursors never touch the board and are not
+// stored.
+type CursorMessage struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+}
+
+// cursorAllowed reports whether a cursor move at now should be passed on,
+// allowing one per config.CursorInterval.
+func (c *Client) cursorAllowed(now time.Time) bool {
+	if now.Sub(c.lastCursor) < config.CursorInterval {
+		return false
+	}
+	c.lastCursor = now
+	return true
+}
+
+// moveCursor shows client's cursor at (x, y) to the other clients. Cursor
+// moves are best effort: clients whose send channel is full miss them. It
+// is only called from the hub's Run loop.
+func (h *Hub) moveCursor(client *Client, x, y int) {
+	if !h.store.InBounds(x, y) {
+		return
+	}
+	message := CursorMessage{
+		Type:     "cursor",
+		ID:       client.publicID,
+		Username: client.Username,
+		X:        x,
+		Y:        y,
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for id, other := range h.clients {
+		if id == client.uuid {
+			continue
+		}
+		if _, ok := other.visible(message); !ok {
+			continue
+		}
+		select {
+		case other.Send <- message:
+		default:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCursorRelayedNotApplied(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.CursorInterval = time.Hour })
+	mover := dial(t, srv, "username=mover")
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+
+	send(t, mover, map[string]any{"type": "cursor", "x": 3, "y": 4})
+	var cursor CursorMessage
+	readType(t, watcher, "cursor", &cursor)
+	if cursor.X != 3 || cursor.Y != 4 || cursor.Username != "mover" || cursor.ID == "" {
+		t.Fatalf("watcher got %+v", cursor)
+	}
+
+	if p, _ := HubInstance.store.Get(3, 4); p != config.FillColor {
+		t.Fatalf("cursor painted (3, 4) %v", p)
+	}
+	if seq := HubInstance.changes.seq(); seq != 0 {
+		t.Fatalf("cursor was logged as change %d", seq)
+	}
+
+	t.Run("throttled", func(t *testing.T) {
+		// A second move within the interval is dropped. The placement after
+		// it passes through the same Run loop, so it arrives after any cursor.
+		send(t, mover, map[string]any{"type": "cursor", "x": 5, "y": 5})
+		place(t, mover, 0, 0, "#000000")
+		watcher.SetReadDeadline(time.Now().Add(readTimeout))
+		for {
+			_, data, err := watcher.ReadMessage()
+			if err != nil {
+				t.Fatalf("waiting for the placement: %v", err)
+			}
+			var header struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(data, &header)
+			if header.Type == "cursor" {
+				t.Fatalf("throttled cursor was relayed: %s", data)
+			}
+			if header.Type == "update" {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package server
+
+// markDirty records that (x, y) changed since the last saved snapshot. The
+// caller must hold b.mu.
+func (b *Board) markDirty(x, y int) {
+	b.version++
+	changed := Region{X: x, Y: y, Width: 1, Height: 1}
+	if b.dirty.Width == 0 {
+		b.dirty = changed
+		return
+	}
+	b.dirty = b.dirty.union(changed)
+}
+
+// markAllDirty records that the whole board changed. The caller must hold
+// b.mu.
+func (b *Board) markAllDirty() {
+	b.version++
+	b.dirty = Region{Width: b.Width, Height: b.Height}
+}
+
+// Dirty returns the bounding box of the cells changed since the last saved
+// snapshot, and false if nothing changed.
+func (b *Board) Dirty() (Region, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.dirty, b.version != b.savedVersion
+}
+
+// versionedSnapshot returns a copy of the board and the version it belongs
+// to. The size is read under the same lock as the pixels, so a concurrent
+// resize cannot leave them disagreeing.
+func (b *Board) versionedSnapshot() (boardSnapshot, uint64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pixels := make([][]Pixel, b.Height)
+	for y := range pixels {
+		pixels[y] = make([]Pixel, b.Width)
+		copy(pixels[y], b.Pixels[y])
+	}
+	snap := boardSnapshot{Width: b.Width, Height: b.Height, Pixels: pixels}
+	return snap, b.version, b.version != b.savedVersion
+}
+
+// markSaved records that the board as of version has been saved. Changes
+// made since then stay dirty.
+func (b *Board) markSaved(version uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.savedVersion = version
+	if b.version == version {
+		b.dirty = Region{}
+	}
+}
+
+// union returns the smallest region containing r and o.
+func (r Region) union(o Region) Region {
+	x0, y0 := min(r.X, o.X), min(r.Y, o.Y)
+	x1, y1 := max(r.X+r.Width, o.X+o.Width), max(r.Y+r.Height, o.Y+o.Height)
+	return Region{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
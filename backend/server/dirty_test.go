@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirtyTracking(t *testing.T) {
+	useConfig(t, nil)
+	path := filepath.Join(t.TempDir(), "board.json")
+	b := NewBoard(8, 8)
+	if _, dirty := b.Dirty(); dirty {
+		t.Fatal("new board is dirty")
+	}
+
+	b.Set(2, 3, Pixel{A: 255}, PixelMeta{})
+	if r, dirty := b.Dirty(); !dirty || r != (Region{X: 2, Y: 3, Width: 1, Height: 1}) {
+		t.Fatalf("dirty region is %+v, %v after one change", r, dirty)
+	}
+	b.Set(5, 1, Pixel{A: 255}, PixelMeta{})
+	if r, _ := b.Dirty(); r != (Region{X: 2, Y: 1, Width: 4, Height: 3}) {
+		t.Fatalf("dirty region is %+v after two changes, want their bounding box", r)
+	}
+
+	if err := b.SaveSnapshot(path); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	if _, dirty := b.Dirty(); dirty {
+		t.Fatal("board is still dirty after saving")
+	}
+
+	// An unchanged board is not written again.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SaveSnapshot(path); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("unchanged board was written again: %v", err)
+	}
+
+	b.Set(0, 0, Pixel{R: 1, A: 255}, PixelMeta{})
+	if err := b.SaveSnapshot(path); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("changed board was not written: %v", err)
+	}
+}
+
+func TestChangeDuringSaveStaysDirty(t *testing.T) {
+	b := NewBoard(8, 8)
+	b.Set(1, 1, Pixel{A: 255}, PixelMeta{})
+	_, version, _ := b.versionedSnapshot()
+	b.Set(6, 6, Pixel{A: 255}, PixelMeta{})
+	b.markSaved(version)
+	if _, dirty := b.Dirty(); !dirty {
+		t.Fatal("change made while saving was marked saved")
+	}
+}
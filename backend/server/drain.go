@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var errDraining = errors.New("server is draining, reconnect elsewhere")
+
+// maxDrainGrace caps the grace period a drain request may ask for.
+const maxDrainGrace = time.Hour
+
+// draining is set once a drain starts. New websocket connections are
+// refused from then on, until the server restarts.
+var draining atomic.Bool
+
+// ReconnectMessage asks clients to reconnect, to URL if given, before the
+// server closes their connection in GraceMs.
+type ReconnectMessage struct {
+	Type    string `json:"type"`
+	URL     string `json:"url,omitempty"`
+	GraceMs int64  `json:"graceMs"`
+}
+
+// DrainRequest optionally names where clients should reconnect and
+// overrides config.DrainGrace.
+type DrainRequest struct {
+	URL     string `json:"url"`
+	GraceMs *int64 `json:"graceMs"`
+}
+
+// drain disconnects every client left once the grace period is over. It is
+// only called from the hub's Run loop.
+func (h *Hub) drain() int {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.closeErr = errDraining
+		h.disconnect(client)
+	}
+	return len(clients)
+}
+
+// DrainServer prepares the server to be replaced in a rolling deploy. It
+// refuses new connections, asks clients to reconnect, waits the grace
+// period for them to leave, then closes the rest and writes a final
+// snapshot. It responds once the server is drained.
+func DrainServer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req DrainRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		grace := config.DrainGrace
+		if req.GraceMs != nil {
+			// Compared in milliseconds, so huge values cannot overflow into a
+			// negative grace.
+			if *req.GraceMs < 0 || *req.GraceMs > maxDrainGrace.Milliseconds() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "graceMs must be between 0 and " + strconv.FormatInt(maxDrainGrace.Milliseconds(), 10)})
+				return
+			}
+			grace = time.Duration(*req.GraceMs) * time.Millisecond
+		}
+		if !draining.CompareAndSwap(false, true) {
+			c.JSON(http.StatusConflict, gin.H{"error": "server is already draining"})
+			return
+		}
+		logger.Info("Draining server", "url", req.URL, "grace", grace, "ip", c.ClientIP())
+
+		hint := ReconnectMessage{Type: "reconnect", URL: req.URL, GraceMs: grace.Milliseconds()}
+		for _, h := range rooms {
+			if !h.do(func() {
+				h.flushBatch()
+				h.broadcastMessage(hint, uuid.Nil)
+			}) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+				return
+			}
+		}
+
+		time.Sleep(grace)
+
+		closed := 0
+		for _, h := range rooms {
+			if !h.do(func() { closed += h.drain() }) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+				return
+			}
+		}
+		if err := SaveBoard(); err != nil {
+			logger.Error("Failed to save board snapshot after draining", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "closed": closed})
+			return
+		}
+		logger.Info("Server drained", "closed", closed)
+		c.JSON(http.StatusOK, gin.H{"drained": true, "closed": closed})
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDrain(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "board.json")
+	srv := startServer(t, func(cfg *Config) { cfg.SnapshotPath = snapshot })
+	t.Cleanup(func() { draining.Store(false) })
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+	place(t, conn, 0, 0, "#000000")
+
+	// The drain only responds once the grace period is over.
+	type drained struct {
+		status int
+		Closed int
+		err    error
+	}
+	done := make(chan drained, 1)
+	go func() {
+		body := `{"url":"wss://next.example/ws","graceMs":300}`
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/drain", strings.NewReader(body))
+		req.Header = adminHeader()
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			done <- drained{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		result := drained{status: resp.StatusCode}
+		result.err = json.NewDecoder(resp.Body).Decode(&result)
+		done <- result
+	}()
+
+	var hint ReconnectMessage
+	readType(t, conn, "reconnect", &hint)
+	if hint.URL != "wss://next.example/ws" || hint.GraceMs != 300 {
+		t.Fatalf("got %+v, want a reconnect to next.example within 300ms", hint)
+	}
+
+	// New connections are refused during the grace period.
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=b"
+	if late, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		late.Close()
+		t.Fatal("connection while draining was accepted")
+	} else if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("connection while draining got %v, want status 503", resp)
+	}
+	if status := getJSON(t, srv, "/readyz", nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("GET /readyz while draining: %d, want 503", status)
+	}
+
+	// Clients still there after the grace period are closed.
+	if code := readClose(t, conn); code != websocket.CloseGoingAway {
+		t.Fatalf("closed with %d, want %d", code, websocket.CloseGoingAway)
+	}
+	result := <-done
+	if result.err != nil || result.status != http.StatusOK || result.Closed != 1 {
+		t.Fatalf("drain returned %d closing %d clients (%v), want 200 closing 1", result.status, result.Closed, result.err)
+	}
+	if _, err := os.Stat(snapshot); err != nil {
+		t.Fatalf("no final snapshot: %v", err)
+	}
+
+	if status := postJSON(t, srv, "/admin/drain", DrainRequest{}, adminHeader(), nil); status != http.StatusConflict {
+		t.Fatalf("draining twice: %d, want 409", status)
+	}
+}
+
+func TestDrainRejectsBadGrace(t *testing.T) {
+	srv := startServer(t, nil)
+	t.Cleanup(func() { draining.Store(false) })
+	for _, grace := range []int64{-1, maxDrainGrace.Milliseconds() + 1, math.MaxInt64} {
+		if status := postJSON(t, srv, "/admin/drain", DrainRequest{GraceMs: &grace}, adminHeader(), nil); status != http.StatusBadRequest {
+			t.Errorf("grace of %dms: %d, want 400", grace, status)
+		}
+	}
+	if draining.Load() {
+		t.Fatal("a rejected drain started draining")
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is one line of the event log. Type is empty for placements,
+// "reset" when the board was cleared and "resize" when it changed size to
+// Width by Height. A new log starts with a resize to the board's size then,
+// so replays know the size to start from.
+type Event struct {
+	Type     string    `json:"type,omitempty"`
+	Seq      uint64    `json:"seq"`
+	X        int       `json:"x"`
+	Y        int       `json:"y"`
+	Width    int       `json:"width,omitempty"`
+	Height   int       `json:"height,omitempty"`
+	Pixel    Pixel     `json:"pixel"`
+	Username string    `json:"username"`
+	Ts       time.Time `json:"ts"`
+}
+
+// EventLog appends every applied change to a writer as JSON lines, giving a
+// complete history of the board that can be replayed.
+type EventLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w, enc: json.NewEncoder(w)}
+}
+
+func (l *EventLog) Append(e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(e)
+}
+
+// Close closes the underlying writer if it is an io.Closer.
+func (l *EventLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadEvents calls fn for each event in r, in order.
+func ReadEvents(r io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("event log line %d: %w", line, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Replay applies the events in r to the board in order and returns the
+// sequence number of the last one.
+func (b *Board) Replay(r io.Reader) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var seq uint64
+	err := ReadEvents(r, func(e Event) error {
+		seq = e.Seq
+		return b.applyEvent(e)
+	})
+	return seq, err
+}
+
+// applyEvent applies a single event. The caller must hold b.mu.
+func (b *Board) applyEvent(e Event) error {
+	switch e.Type {
+	case "reset":
+		b.InitBoard()
+		b.markAllDirty()
+		return nil
+	case "resize":
+		if e.Width < 1 || e.Height < 1 {
+			return fmt.Errorf("event %d resizes to %dx%d", e.Seq, e.Width, e.Height)
+		}
+		b.resize(e.Width, e.Height)
+		b.markAllDirty()
+		return nil
+	}
+	if !b.inBounds(e.X, e.Y) {
+		return fmt.Errorf("event %d at (%d, %d) is out of bounds", e.Seq, e.X, e.Y)
+	}
+	b.setPixel(e.X, e.Y, e.Pixel)
+	b.Owners[e.Y][e.X] = PixelMeta{Username: e.Username, PlacedAt: e.Ts}
+	b.markDirty(e.X, e.Y)
+	return nil
+}
+
+// RestoreEventLog replays the configured event log over the board of every
+// room, so changes made after the last snapshot survive a crash, and opens
+// it for appending.
+func RestoreEventLog() error {
+	for _, h := range rooms {
+		if err := h.restoreEventLog(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) restoreEventLog() error {
+	path := roomPath(config.EventLogPath, h.name)
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		seq, err := h.board.Replay(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		h.changes.resume(seq)
+		logger.Info("Replayed event log", "path", path, "seq", seq)
+	}
+
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	h.events = NewEventLog(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		width, height := h.board.Size()
+		h.record(Event{Type: "resize", Seq: h.changes.seq(), Width: width, Height: height, Ts: h.clock()})
+	}
+	return nil
+}
+
+// newReplayBoard returns the empty board a replay of the event log starts
+// from. Its size is corrected by the resize event a log starts with; logs
+// written before that was recorded started at the configured size.
+func newReplayBoard() *Board {
+	return NewBoard(config.BoardWidth, config.BoardHeight)
+}
+
+// record appends an applied update to the room's event log, if there is one.
+func (h *Hub) record(e Event) {
+	if h.events == nil {
+		return
+	}
+	if err := h.events.Append(e); err != nil {
+		logger.Error("Failed to write event log", "seq", e.Seq, "error", err)
+	}
+}
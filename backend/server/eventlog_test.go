@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestReplayEventLog(t *testing.T) {
+	useConfig(t, nil)
+	h := newTestHub(8, 8)
+	clock := useFakeClock(h)
+	var log bytes.Buffer
+	h.events = NewEventLog(&log)
+
+	alice, bob := uuid.New(), uuid.New()
+	place := func(id uuid.UUID, x, y int, p Pixel) {
+		t.Helper()
+		clock.advance(time.Second)
+		if _, err := h.applyUpdate(Update{Type: "update", X: x, Y: y, Pixel: p, SenderUUID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	place(alice, 0, 0, Pixel{R: 255, A: 255})
+	place(bob, 7, 7, Pixel{G: 255, A: 255})
+	if err := h.reset(); err != nil {
+		t.Fatal(err)
+	}
+	place(alice, 1, 2, Pixel{B: 255, A: 255})
+	place(bob, 1, 2, Pixel{R: 9, G: 9, B: 9, A: 255})
+	place(alice, 6, 3, Pixel{R: 1, A: 255})
+
+	replayed := NewBoard(8, 8)
+	seq, err := replayed.Replay(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if seq != h.changes.seq() {
+		t.Fatalf("replay ended at seq %d, want %d", seq, h.changes.seq())
+	}
+	if got, want := replayed.Snapshot(), h.store.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed board differs:\ngot  %v\nwant %v", got, want)
+	}
+}
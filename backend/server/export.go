@@ -0,0 +1,231 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errExportUnsupported = errors.New("the board store cannot be exported or imported")
+	errImportDimensions  = errors.New("imported board does not match the board's dimensions")
+)
+
+// BoardExport is a full backup of a room's board: every pixel, who placed
+// it and the leaderboard counts.
+type BoardExport struct {
+	Room       string         `json:"room"`
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Seq        uint64         `json:"seq"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Pixels     [][]Pixel      `json:"pixels"`
+	Owners     [][]PixelMeta  `json:"owners,omitempty"`
+	Placements map[string]int `json:"placements,omitempty"`
+}
+
+// Export returns copies of the board's pixels and owners.
+func (b *Board) Export() ([][]Pixel, [][]PixelMeta) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pixels := make([][]Pixel, b.Height)
+	owners := make([][]PixelMeta, b.Height)
+	for y := range pixels {
+		pixels[y] = slices.Clone(b.Pixels[y])
+		owners[y] = slices.Clone(b.Owners[y])
+	}
+	return pixels, owners
+}
+
+// Replace swaps in pixels and owners, which must match the board's size.
+// Nil owners leave every cell unowned.
+func (b *Board) Replace(pixels [][]Pixel, owners [][]PixelMeta) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := checkGrid(pixels, owners, b.Width, b.Height); err != nil {
+		return err
+	}
+	if owners == nil {
+		owners = make([][]PixelMeta, b.Height)
+		for y := range owners {
+			owners[y] = make([]PixelMeta, b.Width)
+		}
+	}
+	b.Pixels, b.Owners = pixels, owners
+	b.recount()
+	b.markAllDirty()
+	return nil
+}
+
+// checkGrid reports whether pixels, and owners if given, are width by
+// height.
+func checkGrid(pixels [][]Pixel, owners [][]PixelMeta, width, height int) error {
+	if len(pixels) != height || (owners != nil && len(owners) != height) {
+		return fmt.Errorf("%w: want %d rows", errImportDimensions, height)
+	}
+	for y := range pixels {
+		if len(pixels[y]) != width || (owners != nil && len(owners[y]) != width) {
+			return fmt.Errorf("%w: row %d is not %d cells wide", errImportDimensions, y, width)
+		}
+	}
+	return nil
+}
+
+// export backs up the board. It is only called from the hub's Run loop, so
+// the pixels match the sequence number.
+func (h *Hub) export() (BoardExport, error) {
+	store, ok := h.store.(portableStore)
+	if !ok {
+		return BoardExport{}, errExportUnsupported
+	}
+	h.flushBatch()
+	pixels, owners := store.Export()
+	width, height := h.store.Size()
+	return BoardExport{
+		Room:       h.name,
+		Width:      width,
+		Height:     height,
+		Seq:        h.changes.seq(),
+		ExportedAt: h.clock(),
+		Pixels:     pixels,
+		Owners:     owners,
+		Placements: h.leaderboard.snapshot(),
+	}, nil
+}
+
+// restore replaces the board with a backup and sends every client the new
+// board. Pending batched updates predate the import and are dropped, as on
+// a reset. Cells that change are written to the event log so the history
+// still replays to the current board. With quantize, colors outside the
+// palette are mapped onto it instead of refused.
+func (h *Hub) restore(backup BoardExport, quantize, dither bool) (uint64, error) {
+	store, ok := h.store.(portableStore)
+	if !ok {
+		return 0, errExportUnsupported
+	}
+	width, height := h.store.Size()
+	if backup.Width != width || backup.Height != height {
+		return 0, fmt.Errorf("%w: import is %dx%d, board is %dx%d",
+			errImportDimensions, backup.Width, backup.Height, width, height)
+	}
+	if err := checkGrid(backup.Pixels, backup.Owners, width, height); err != nil {
+		return 0, err
+	}
+	if quantize && len(config.Palette) > 0 {
+		quantized := quantizeGrid(backup.Pixels, config.Palette, dither)
+		// Blank cells stay blank rather than taking a palette color.
+		for y, row := range backup.Pixels {
+			for x, p := range row {
+				if p == config.FillColor {
+					quantized[y][x] = p
+				}
+			}
+		}
+		backup.Pixels = quantized
+	}
+	for y, row := range backup.Pixels {
+		for x, p := range row {
+			if p != config.FillColor && !inPalette(p) {
+				return 0, fmt.Errorf("%w: pixel at (%d, %d)", errNotInPalette, x, y)
+			}
+		}
+	}
+
+	before, _ := store.Export()
+	if err := store.Replace(backup.Pixels, backup.Owners); err != nil {
+		return 0, err
+	}
+	if backup.Placements != nil {
+		h.leaderboard.restore(backup.Placements)
+	}
+	h.batch.take()
+	seq := h.changes.invalidate()
+	now := h.clock()
+	for y, row := range backup.Pixels {
+		for x, p := range row {
+			if p == before[y][x] {
+				continue
+			}
+			e := Event{Seq: seq, X: x, Y: y, Pixel: p, Username: "admin", Ts: now}
+			if backup.Owners != nil && backup.Owners[y][x].Username != "" {
+				e.Username = backup.Owners[y][x].Username
+			}
+			h.record(e)
+		}
+	}
+	h.broadcastMessage(InitBoardState{
+		Type:   "init",
+		Seq:    seq,
+		Pixels: h.store.Snapshot(),
+	}, uuid.Nil)
+	return seq, nil
+}
+
+// ExportBoard returns a room's full board as JSON for backups.
+func ExportBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		var backup BoardExport
+		var err error
+		if !hub.do(func() { backup, err = hub.export() }) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, backup)
+	}
+}
+
+// ImportBoard replaces a room's board with a backup made by ExportBoard.
+// The backup must have the board's current dimensions and only use colors
+// from the palette, unless the quantize query parameter is true, which maps
+// other colors to the nearest palette color, with dithering if dither is
+// also true.
+func ImportBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var backup BoardExport
+		if err := c.ShouldBindJSON(&backup); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		quantize, errQ := strconv.ParseBool(c.DefaultQuery("quantize", "false"))
+		dither, errD := strconv.ParseBool(c.DefaultQuery("dither", "false"))
+		if errQ != nil || errD != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantize and dither must be true or false"})
+			return
+		}
+
+		var seq uint64
+		var err error
+		if !hub.do(func() { seq, err = hub.restore(backup, quantize, dither) }) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		switch {
+		case errors.Is(err, errExportUnsupported):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("Board imported", "room", hub.name, "exportedAt", backup.ExportedAt, "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"seq": seq})
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// exportBoard fetches /admin/export from srv.
+func exportBoard(t *testing.T, srv string) BoardExport {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv+"/admin/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = adminHeader()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /admin/export: %d", resp.StatusCode)
+	}
+	var backup BoardExport
+	if err := json.NewDecoder(resp.Body).Decode(&backup); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	return backup
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	place(t, conn, 1, 2, "#ff4500")
+	place(t, conn, 15, 15, "#00a368")
+
+	if status := getJSON(t, srv, "/admin/export", nil); status != http.StatusUnauthorized {
+		t.Fatalf("export without a token got %d", status)
+	}
+	backup := exportBoard(t, srv.URL)
+	if backup.Width != 16 || backup.Height != 16 || backup.Seq != 2 || backup.Placements["a"] != 2 {
+		t.Fatalf("export is %dx%d at seq %d with placements %v", backup.Width, backup.Height, backup.Seq, backup.Placements)
+	}
+	if backup.Owners[2][1].Username != "a" {
+		t.Fatalf("export lost the owner of (1, 2): %+v", backup.Owners[2][1])
+	}
+	want := HubInstance.store.Snapshot()
+
+	if status := postJSON(t, srv, "/admin/reset", nil, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("resetting: %d", status)
+	}
+	readType(t, conn, "init", &InitBoardState{})
+	if status := postJSON(t, srv, "/admin/import", backup, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("importing: %d", status)
+	}
+	var init InitBoardState
+	readType(t, conn, "init", &init)
+	if !reflect.DeepEqual(init.Pixels, want) {
+		t.Fatal("clients were sent a different board than was exported")
+	}
+	if got := HubInstance.store.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatal("imported board differs from the export")
+	}
+	if _, meta := HubInstance.store.Get(1, 2); meta.Username != "a" {
+		t.Fatalf("import lost the owner of (1, 2): %+v", meta)
+	}
+}
+
+func TestImportRejectsInvalidBoards(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Palette = []Pixel{{A: 255}} })
+	backup := exportBoard(t, srv.URL)
+
+	offPalette := backup
+	offPalette.Pixels = append([][]Pixel(nil), backup.Pixels...)
+	offPalette.Pixels[0] = append([]Pixel(nil), backup.Pixels[0]...)
+	offPalette.Pixels[0][0] = Pixel{R: 255, A: 255}
+	small := backup
+	small.Width, small.Height = 8, 8
+	small.Pixels = small.Pixels[:8]
+	ragged := backup
+	ragged.Owners = nil
+	ragged.Pixels = append([][]Pixel(nil), backup.Pixels...)
+	ragged.Pixels[3] = ragged.Pixels[3][:15]
+
+	for name, b := range map[string]BoardExport{"smaller": small, "ragged": ragged, "off-palette": offPalette} {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if status := postJSON(t, srv, "/admin/import", b, adminHeader(), &resp); status != http.StatusBadRequest || resp.Error == "" {
+			t.Errorf("%s import got %d %q, want 400 with an error", name, status, resp.Error)
+		}
+	}
+}
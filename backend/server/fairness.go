@@ -0,0 +1,38 @@
+package server
+
+import "slices"
+
+// roster keeps the hub's clients in the order they joined so broadcasts can
+// take turns over them. Each broadcast starts one client further along than
+// the last, so when the hub has to drop or evict clients whose queues are
+// full, no client is always served first or last.
+type roster struct {
+	clients []*Client
+	turn    int
+}
+
+// add appends client. The caller must hold the hub's lock.
+func (r *roster) add(client *Client) {
+	r.clients = append(r.clients, client)
+}
+
+// remove drops client if it is listed. The caller must hold the hub's lock.
+func (r *roster) remove(client *Client) {
+	if i := slices.Index(r.clients, client); i >= 0 {
+		r.clients = slices.Delete(r.clients, i, i+1)
+	}
+}
+
+// rotation returns the clients starting from the one whose turn it is and
+// moves the turn on. It is only called from the hub's Run loop.
+func (r *roster) rotation() []*Client {
+	n := len(r.clients)
+	if n == 0 {
+		return nil
+	}
+	start := r.turn % n
+	r.turn = start + 1
+	out := make([]*Client, 0, n)
+	out = append(out, r.clients[start:]...)
+	return append(out, r.clients[:start]...)
+}
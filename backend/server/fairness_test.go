@@ -0,0 +1,84 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRosterRotation(t *testing.T) {
+	a, b, c := &Client{}, &Client{}, &Client{}
+	var r roster
+	r.add(a)
+	r.add(b)
+	r.add(c)
+
+	firsts := map[*Client]bool{}
+	for range 3 {
+		firsts[r.rotation()[0]] = true
+	}
+	if len(firsts) != 3 {
+		t.Fatalf("%d clients went first in three broadcasts, want 3", len(firsts))
+	}
+
+	r.remove(b)
+	if got := r.rotation(); len(got) != 2 || got[0] == b || got[1] == b {
+		t.Fatalf("removed client is still served")
+	}
+}
+
+func TestBroadcastSlowClient(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.SendOverflow = "drop_oldest" })
+	h := newTestHub(8, 8)
+
+	// The slow client never reads, so its queue stays full.
+	slow := addTestClient(t, h, "slow", 1)
+	var fast []*Client
+	for _, name := range []string{"a", "b", "c"} {
+		fast = append(fast, addTestClient(t, h, name, 1))
+	}
+
+	const messages = 200
+	received := make(chan time.Duration, len(fast)*messages)
+	for _, client := range fast {
+		go func() {
+			for range messages {
+				update := (<-client.Send).(Update)
+				received <- time.Since(update.Ts)
+			}
+		}()
+	}
+
+	for i := range messages {
+		// Wait for every fast client to take the last update, as a
+		// connection keeping up would, so only the slow client overflows.
+		for _, client := range fast {
+			for len(client.Send) > 0 {
+				time.Sleep(time.Microsecond)
+			}
+		}
+		start := time.Now()
+		h.broadcastMessage(Update{X: i % 8, Ts: start}, uuid.Nil)
+		if d := time.Since(start); d > 100*time.Millisecond {
+			t.Fatalf("broadcast %d took %v", i, d)
+		}
+	}
+
+	for range len(fast) * messages {
+		select {
+		case latency := <-received:
+			if latency > time.Second {
+				t.Fatalf("update reached a fast client after %v", latency)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("fast clients did not receive every update")
+		}
+	}
+	if len(slow.Send) != 1 {
+		t.Fatalf("slow client holds %d messages, want its latest one", len(slow.Send))
+	}
+	if _, ok := h.clients[slow.uuid]; !ok {
+		t.Fatal("slow client was disconnected under drop_oldest")
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var errFrozen = errors.New("the board is frozen for maintenance")
+
+// frozen stops all placements in every room while clients stay connected.
+var frozen atomic.Bool
+
+// FrozenMessage tells clients whether the board is frozen. It is sent when
+// the state changes and on connect while frozen.
+type FrozenMessage struct {
+	Type  string `json:"type"`
+	Value bool   `json:"value"`
+}
+
+// setFrozen freezes or unfreezes every room and tells their clients. It
+// reports false if a room's hub has stopped.
+func setFrozen(value bool) bool {
+	frozen.Store(value)
+	ok := true
+	for _, h := range rooms {
+		if !h.do(func() {
+			h.broadcastMessage(FrozenMessage{Type: "frozen", Value: value}, uuid.Nil)
+		}) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// FreezeBoard rejects all placements until UnfreezeBoard is called.
+func FreezeBoard() gin.HandlerFunc {
+	return setFrozenHandler(true)
+}
+
+func UnfreezeBoard() gin.HandlerFunc {
+	return setFrozenHandler(false)
+}
+
+func setFrozenHandler(value bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !setFrozen(value) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		logger.Info("Board freeze changed", "frozen", value, "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"frozen": value})
+	}
+}
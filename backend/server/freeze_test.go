@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	srv := startServer(t, nil)
+	t.Cleanup(func() { setFrozen(false) })
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+
+	if status := postJSON(t, srv, "/admin/freeze", nil, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("freezing: %d", status)
+	}
+	var frozen FrozenMessage
+	if readType(t, conn, "frozen", &frozen); !frozen.Value {
+		t.Fatal("freezing was announced as unfrozen")
+	}
+	if reply := request(t, conn, map[string]any{"type": "update", "x": 1, "y": 1, "color": "#000000"}); reply.Code != "frozen" {
+		t.Fatalf("placement while frozen got %s %q, want nack frozen", reply.Type, reply.Code)
+	}
+	x, y := 2, 2
+	if status := postJSON(t, srv, "/pixel", PlacePixelRequest{X: &x, Y: &y}, nil, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("POST /pixel while frozen got %d", status)
+	}
+
+	// Clients connecting while frozen are told so, and can still view.
+	late := dial(t, srv, "username=late")
+	readType(t, late, "init", &InitBoardState{})
+	if readType(t, late, "frozen", &frozen); !frozen.Value {
+		t.Fatal("late client was told the board is unfrozen")
+	}
+
+	if status := postJSON(t, srv, "/admin/unfreeze", nil, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("unfreezing: %d", status)
+	}
+	if readType(t, conn, "frozen", &frozen); frozen.Value {
+		t.Fatal("unfreezing was announced as frozen")
+	}
+	place(t, conn, 1, 1, "#000000")
+	if p, _ := HubInstance.store.Get(2, 2); p != config.FillColor {
+		t.Fatalf("POST /pixel while frozen painted %v", p)
+	}
+}
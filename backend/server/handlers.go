@@ -0,0 +1,300 @@
+package server
+
+import (
+	"errors"
+	"image/png"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func GetBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		width, height := hub.store.Size()
+		c.JSON(http.StatusOK, boardSnapshot{
+			Width:  width,
+			Height: height,
+			Pixels: hub.store.Snapshot(),
+		})
+	}
+}
+
+// RawBoard is the board as packed RGB bytes, in the layout of the binary
+// init frame without its header. Data is base64 encoded in JSON.
+type RawBoard struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Seq    uint64 `json:"seq"`
+	Data   []byte `json:"data"`
+}
+
+func GetBoardRaw() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		width, height := hub.store.Size()
+		seq := hub.changes.seq()
+		c.JSON(http.StatusOK, RawBoard{
+			Width:  width,
+			Height: height,
+			Seq:    seq,
+			Data:   encodeBinaryBoard(seq, hub.store.Snapshot())[binaryBoardHeader:],
+		})
+	}
+}
+
+func GetBoardPNG() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		scale, ok := imageScale(c)
+		if !ok {
+			return
+		}
+		width, height := hub.store.Size()
+		if !checkImageSize(c, width, height, scale) {
+			return
+		}
+
+		img := renderImage(hub.store.Snapshot(), scale)
+		c.Header("Content-Type", "image/png")
+		c.Status(http.StatusOK)
+		if err := png.Encode(c.Writer, img); err != nil {
+			logger.Error("PNG encode error", "error", err)
+		}
+	}
+}
+
+// imageScale reads the scale query parameter of an image endpoint. It
+// responds with 400 and reports false if the scale is out of range.
+func imageScale(c *gin.Context) (int, bool) {
+	value := c.Query("scale")
+	if value == "" {
+		return 1, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxImageScale {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scale must be between 1 and " + strconv.Itoa(maxImageScale)})
+		return 0, false
+	}
+	return n, true
+}
+
+func GetPixel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		x, errX := strconv.Atoi(c.Query("x"))
+		y, errY := strconv.Atoi(c.Query("y"))
+		if errX != nil || errY != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "x and y must be integers"})
+			return
+		}
+		if !hub.store.InBounds(x, y) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pixel out of bounds"})
+			return
+		}
+
+		pixel, meta := hub.store.Get(x, y)
+		info := PixelInfo{X: x, Y: y, Pixel: pixel}
+		if !meta.PlacedAt.IsZero() {
+			info.Owner = &meta
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+func GetUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		hub.mu.RLock()
+		users := make([]UserInfo, 0, len(hub.clients))
+		for _, client := range hub.clients {
+			if client.spectator {
+				continue
+			}
+			users = append(users, UserInfo{
+				ID:          client.publicID,
+				Username:    client.Username,
+				ConnectedAt: client.connectedAt,
+				LastActive:  time.Unix(0, client.lastActivity.Load()),
+			})
+		}
+		hub.mu.RUnlock()
+
+		sort.Slice(users, func(i, j int) bool {
+			return users[i].ConnectedAt.Before(users[j].ConnectedAt)
+		})
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+type PlacePixelRequest struct {
+	X        *int   `json:"x" binding:"required"`
+	Y        *int   `json:"y" binding:"required"`
+	R        uint8  `json:"r"`
+	G        uint8  `json:"g"`
+	B        uint8  `json:"b"`
+	A        *uint8 `json:"a"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+}
+
+// restIdentity identifies a REST caller for the cooldown by the id in
+// token, an identity token issued by the server, or else by IP address.
+// Callers choose tokens freely, so anything else is not trusted as an
+// identity.
+func restIdentity(c *gin.Context, token string) uuid.UUID {
+	if token != "" {
+		if id, _, err := parseIdentity(token); err == nil {
+			return id
+		}
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("rplace:ip:"+c.ClientIP()))
+}
+
+// GetCooldown reports how long a REST caller, identified as by PostPixel
+// with the token query parameter, has to wait before placing again.
+func GetCooldown() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		id := restIdentity(c, c.Query("token"))
+		if bot, ok, err := botFor(c.Request); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		} else if ok {
+			id = bot.id
+		} else if authEnabled() {
+			claims, err := parseToken(bearerToken(c.Request))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+				return
+			}
+			id = claims.id()
+		}
+		var remaining time.Duration
+		if !hub.do(func() { remaining = max(hub.cooldownRemaining(id, hub.clock()), 0) }) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"remainingMs": remaining.Milliseconds()})
+	}
+}
+
+// PostPixel places a pixel without a websocket. Callers are identified for
+// the cooldown by their identity token, or by IP address without one. When
+// JWT auth is enabled, a valid bearer token is required and identifies them
+// instead. Bots identify with their API key in X-API-Key.
+func PostPixel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		var req PlacePixelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		id, name := restIdentity(c, req.Token), sanitizeUsername(req.Username)
+		if bot, ok, err := botFor(c.Request); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		} else if ok {
+			id, name = bot.id, bot.name
+		} else if authEnabled() {
+			claims, err := parseToken(bearerToken(c.Request))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing token"})
+				return
+			}
+			id, name = claims.id(), claims.username()
+		}
+
+		if isBanned(id, name, c.ClientIP()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": errBanned.Error()})
+			return
+		}
+
+		pixel := Pixel{R: req.R, G: req.G, B: req.B, A: 255}
+		if req.A != nil {
+			pixel.A = *req.A
+		}
+		result := make(chan placeResult, 1)
+		update := Update{
+			Type:       "update",
+			Pixel:      pixel,
+			X:          *req.X,
+			Y:          *req.Y,
+			SenderUUID: id,
+			SenderName: name,
+			result:     result,
+		}
+		if err := hub.enqueue(c.Request.Context(), update); err != nil {
+			if errors.Is(err, errServerBusy) {
+				c.Header("Retry-After", "1")
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The hub may stop before it gets to an update still in its buffer.
+		var res placeResult
+		select {
+		case res = <-result:
+		case <-hub.done:
+			select {
+			case res = <-result:
+			default:
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+				return
+			}
+		}
+		var cooldown *cooldownError
+		var pending *voteError
+		switch {
+		case res.Err == nil:
+			c.JSON(http.StatusOK, res.Update)
+		case errors.As(res.Err, &pending):
+			c.JSON(http.StatusAccepted, gin.H{"votes": pending.votes, "needed": config.ConsensusVotes})
+		case errors.As(res.Err, &cooldown):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(cooldown.remaining.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       res.Err.Error(),
+				"remainingMs": cooldown.remaining.Milliseconds(),
+			})
+		case errors.Is(res.Err, errFrozen):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": res.Err.Error()})
+		case errors.Is(res.Err, errRegionRateLimited):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": res.Err.Error()})
+		case errors.Is(res.Err, errRegionLocked), errors.Is(res.Err, errBanned), errors.Is(res.Err, errCanvasClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": res.Err.Error()})
+		case errors.Is(res.Err, errStoreFailed):
+			c.JSON(http.StatusInternalServerError, gin.H{"error": res.Err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": res.Err.Error()})
+		}
+	}
+}
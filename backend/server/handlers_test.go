@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetBoard(t *testing.T) {
+	srv := startServer(t, nil)
+	place(t, dial(t, srv, "username=a"), 7, 9, "#ffd635")
+
+	var board boardSnapshot
+	if status := getJSON(t, srv, "/board", &board); status != http.StatusOK {
+		t.Fatalf("GET /board: %d", status)
+	}
+	if board.Width != 16 || board.Height != 16 {
+		t.Fatalf("board is %dx%d, want 16x16", board.Width, board.Height)
+	}
+	if got, want := board.Pixels[9][7], (Pixel{R: 0xff, G: 0xd6, B: 0x35, A: 255}); got != want {
+		t.Fatalf("GET /board has %v at (7, 9), want %v", got, want)
+	}
+}
+
+func TestGetBoardRaw(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.BoardWidth, cfg.BoardHeight = 5, 3 })
+	conn := dial(t, srv, "username=a")
+	place(t, conn, 4, 2, "#ffd635")
+	place(t, conn, 0, 1, "#2450a4")
+
+	var raw struct {
+		Width, Height int
+		Seq           uint64
+		Data          string
+	}
+	if status := getJSON(t, srv, "/board/raw", &raw); status != http.StatusOK {
+		t.Fatalf("GET /board/raw: %d", status)
+	}
+	data, err := base64.StdEncoding.DecodeString(raw.Data)
+	if err != nil {
+		t.Fatalf("decoding data: %v", err)
+	}
+	if raw.Width != 5 || raw.Height != 3 || len(data) != 5*3*3 {
+		t.Fatalf("raw board is %dx%d with %d bytes, want 5x3 with 45", raw.Width, raw.Height, len(data))
+	}
+	if raw.Seq != HubInstance.changes.seq() {
+		t.Fatalf("raw board is at seq %d, want %d", raw.Seq, HubInstance.changes.seq())
+	}
+	for y, row := range HubInstance.store.Snapshot() {
+		for x, p := range row {
+			i := (y*raw.Width + x) * 3
+			if got := [3]byte(data[i : i+3]); got != [3]byte{p.R, p.G, p.B} {
+				t.Errorf("raw board has %v at (%d, %d), want %v", got, x, y, p)
+			}
+		}
+	}
+}
+
+func TestGetPixelReportsLatestOwner(t *testing.T) {
+	srv := startServer(t, nil)
+	place(t, dial(t, srv, "username=alice"), 2, 2, "#ff0000")
+	place(t, dial(t, srv, "username=bob"), 2, 2, "#0000ff")
+
+	var info PixelInfo
+	if status := getJSON(t, srv, "/pixel?x=2&y=2", &info); status != http.StatusOK {
+		t.Fatalf("GET /pixel: %d", status)
+	}
+	if info.Pixel != (Pixel{B: 255, A: 255}) {
+		t.Fatalf("pixel is %v, want bob's blue", info.Pixel)
+	}
+	if info.Owner == nil || info.Owner.Username != "bob" || info.Owner.PlacedAt.IsZero() {
+		t.Fatalf("owner is %+v, want bob", info.Owner)
+	}
+}
+
+func TestGetUsersListsConnectedClients(t *testing.T) {
+	srv := startServer(t, nil)
+	readType(t, dial(t, srv, "username=alice"), "init", &InitBoardState{})
+	readType(t, dial(t, srv, "username=bob"), "init", &InitBoardState{})
+
+	var users []UserInfo
+	if status := getJSON(t, srv, "/users", &users); status != http.StatusOK {
+		t.Fatalf("GET /users: %d", status)
+	}
+	if len(users) != 2 || users[0].Username != "alice" || users[1].Username != "bob" {
+		t.Fatalf("users are %+v, want alice and bob", users)
+	}
+	if users[0].ID == "" || users[0].ID == users[1].ID {
+		t.Fatalf("users have ids %q and %q", users[0].ID, users[1].ID)
+	}
+}
+
+func TestPostPixel(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Cooldown = time.Minute })
+
+	var placed Update
+	status := postJSON(t, srv, "/pixel", map[string]any{"x": 1, "y": 1, "r": 255, "username": "rest"}, nil, &placed)
+	if status != http.StatusOK || placed.Pixel != (Pixel{R: 255, A: 255}) || placed.Seq == 0 {
+		t.Fatalf("valid placement: %d %+v", status, placed)
+	}
+	if p, meta := HubInstance.store.Get(1, 1); p != placed.Pixel || meta.Username != "rest" {
+		t.Fatalf("board has %v by %q", p, meta.Username)
+	}
+
+	if status := postJSON(t, srv, "/pixel", map[string]any{"x": 16, "y": 0}, nil, nil); status != http.StatusBadRequest {
+		t.Fatalf("out of bounds placement: %d, want 400", status)
+	}
+
+	// A made-up token does not get around the cooldown of the address.
+	var cooldown struct {
+		RemainingMs int64 `json:"remainingMs"`
+	}
+	status = postJSON(t, srv, "/pixel", map[string]any{"x": 2, "y": 2, "token": "made-up"}, nil, &cooldown)
+	if status != http.StatusTooManyRequests || cooldown.RemainingMs <= 0 {
+		t.Fatalf("placement during the cooldown: %d %+v, want 429", status, cooldown)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds how long Readyz waits for a store to answer.
+const readyTimeout = 2 * time.Second
+
+// Healthz reports that the process is alive.
+func Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz reports whether the server can take traffic: it is not draining,
+// every room's hub has entered its Run loop and is not shutting down, and
+// its store responds. A store that is down but buffering writes leaves the
+// server degraded but still ready, until its buffer fills up.
+func Readyz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+		defer cancel()
+		if draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+			return
+		}
+		degraded := gin.H{}
+		for _, h := range rooms {
+			if !h.running.Load() || h.closing.Load() {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "room": h.name, "error": "hub is not running"})
+				return
+			}
+			err := h.store.Ping(ctx)
+			if err == nil {
+				continue
+			}
+			logger.Warn("Store is not reachable", "room", h.name, "error", err)
+			if buffered, ok := h.store.(bufferedStore); ok && buffered.Buffered() < config.StoreBufferSize {
+				degraded[h.name] = buffered.Buffered()
+				continue
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "room": h.name, "error": "store is not reachable"})
+			return
+		}
+		if len(degraded) > 0 {
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "buffered": degraded})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
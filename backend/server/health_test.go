@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	srv := startServer(t, nil)
+	if status := getJSON(t, srv, "/healthz", nil); status != http.StatusOK {
+		t.Fatalf("GET /healthz: status %d", status)
+	}
+}
+
+func TestReadyzWaitsForHubs(t *testing.T) {
+	srv := startServer(t, nil)
+	if status := getJSON(t, srv, "/readyz", nil); status != http.StatusOK {
+		t.Fatalf("GET /readyz: status %d with the hub running", status)
+	}
+
+	// A room whose hub has not started yet, as at startup. No requests are
+	// in flight while rooms changes.
+	late, err := newRoom("late", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rooms["late"] = late
+	var body map[string]string
+	if status := getJSON(t, srv, "/readyz", &body); status != http.StatusServiceUnavailable || body["room"] != "late" {
+		t.Fatalf("GET /readyz: status %d, %v before the hub started", status, body)
+	}
+
+	go late.Run()
+	deadline := time.Now().Add(readTimeout)
+	for getJSON(t, srv, "/readyz", nil) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("GET /readyz never became ready after the hub started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHeatWindow = time.Minute
+	maxHeatWindow     = 24 * time.Hour
+)
+
+// defaultHeatTint is half-transparent red.
+var defaultHeatTint = Pixel{R: 255, A: 128}
+
+// Heat returns the board's pixels with tint blended over every cell placed
+// at or after since.
+func (b *Board) Heat(since time.Time, tint Pixel) [][]Pixel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pixels := make([][]Pixel, b.Height)
+	for y := range pixels {
+		pixels[y] = make([]Pixel, b.Width)
+		for x, p := range b.Pixels[y] {
+			if placed := b.Owners[y][x].PlacedAt; !placed.IsZero() && !placed.Before(since) {
+				p = blendOver(tint, p)
+			}
+			pixels[y][x] = p
+		}
+	}
+	return pixels
+}
+
+// GetHeatPNG renders the board with the cells changed in the last seconds
+// seconds, one minute by default, tinted so moderators can spot activity.
+// The tint is a hex color such as FF000080; its alpha sets how strongly it
+// shows.
+func GetHeatPNG() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		scale, ok := imageScale(c)
+		if !ok {
+			return
+		}
+		width, height := hub.store.Size()
+		if !checkImageSize(c, width, height, scale) {
+			return
+		}
+		window := defaultHeatWindow
+		if value := c.Query("seconds"); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 1 || seconds > int(maxHeatWindow.Seconds()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "seconds must be between 1 and " + strconv.Itoa(int(maxHeatWindow.Seconds()))})
+				return
+			}
+			window = time.Duration(seconds) * time.Second
+		}
+		tint := defaultHeatTint
+		if value := c.Query("tint"); value != "" {
+			var err error
+			if tint, err = parseHexColor("#" + strings.TrimPrefix(value, "#")); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		img := renderImage(hub.store.Heat(hub.clock().Add(-window), tint), scale)
+		c.Header("Content-Type", "image/png")
+		c.Status(http.StatusOK)
+		if err := png.Encode(c.Writer, img); err != nil {
+			logger.Error("PNG encode error", "error", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// getPNG fetches and decodes the PNG at path on srvURL.
+func getPNG(t *testing.T, srvURL, path string) image.Image {
+	t.Helper()
+	resp, err := http.Get(srvURL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %d", path, resp.StatusCode)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return img
+}
+
+func TestHeatPNG(t *testing.T) {
+	srv := startServer(t, nil)
+	var clock *fakeClock
+	HubInstance.do(func() { clock = useFakeClock(HubInstance) })
+	conn := dial(t, srv, "username=a")
+
+	place(t, conn, 1, 1, "#000000")
+	clock.advance(2 * time.Minute)
+	place(t, conn, 2, 2, "#000000")
+
+	img := getPNG(t, srv.URL, "/board/heat.png?seconds=60&tint=FF0000")
+	for _, tc := range []struct {
+		name string
+		x, y int
+		want color.NRGBA
+	}{
+		{"recent", 2, 2, color.NRGBA{R: 255, A: 255}},
+		{"old", 1, 1, color.NRGBA{A: 255}},
+		{"untouched", 0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	} {
+		if got := color.NRGBAModel.Convert(img.At(tc.x, tc.y)); got != tc.want {
+			t.Errorf("%s cell is %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// A longer window takes in the older placement too.
+	img = getPNG(t, srv.URL, "/board/heat.png?seconds=300&tint=FF0000")
+	if got := color.NRGBAModel.Convert(img.At(1, 1)); got != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("old cell in a 5 minute window is %v, want it tinted", got)
+	}
+
+	for _, query := range []string{"seconds=0", "seconds=86401", "seconds=9223372037", "seconds=x", "tint=zz"} {
+		if status := getJSON(t, srv, "/board/heat.png?"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("?%s got %d, want %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
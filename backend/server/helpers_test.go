@@ -0,0 +1,396 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// readTimeout bounds how long a test waits for a websocket message.
+const readTimeout = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	// Set once, before anything logs; captureLogs redirects it.
+	logger = slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: logLevel}))
+	os.Exit(m.Run())
+}
+
+// useConfig sets testConfig with edit applied for the length of the test,
+// without starting anything.
+func useConfig(t *testing.T, edit func(*Config)) {
+	t.Helper()
+	saved := config
+	config = testConfig(t, edit)
+	t.Cleanup(func() { config = saved })
+}
+
+// testConfig is the default config with nothing written to disk, quiet
+// logs and no cooldown, with edit applied on top.
+func testConfig(t *testing.T, edit func(*Config)) Config {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.LogLevel = slog.LevelError
+	cfg.SnapshotPath = ""
+	cfg.AuditSink = ""
+	cfg.Cooldown = 0
+	cfg.BoardWidth, cfg.BoardHeight = 16, 16
+	cfg.AdminToken = testAdminToken
+	if edit != nil {
+		edit(&cfg)
+	}
+	return cfg
+}
+
+// startServer configures, restores and starts the server as main.go does
+// and serves its routes. Everything is shut down and the previous config
+// restored when the test ends.
+func startServer(t *testing.T, edit func(*Config)) *httptest.Server {
+	t.Helper()
+	savedConfig, savedRooms, savedHub := config, rooms, HubInstance
+	if err := Configure(testConfig(t, edit)); err != nil {
+		t.Fatalf("configuring server: %v", err)
+	}
+	if err := RestoreSnapshot(); err != nil {
+		t.Fatalf("restoring snapshot: %v", err)
+	}
+	if err := RestoreEventLog(); err != nil {
+		t.Fatalf("restoring event log: %v", err)
+	}
+	StartHub()
+	srv := httptest.NewServer(newTestRouter())
+	t.Cleanup(func() {
+		srv.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+		defer cancel()
+		if err := Shutdown(ctx); err != nil {
+			t.Errorf("shutting down: %v", err)
+		}
+		config, rooms, HubInstance = savedConfig, savedRooms, savedHub
+		logLevel.Set(config.LogLevel)
+	})
+	return srv
+}
+
+// logOutput is where the server logs during tests: stderr, unless a test
+// captures them.
+var logOutput = &switchWriter{w: os.Stderr}
+
+// switchWriter writes to w, which can be swapped while others write.
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *switchWriter) set(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+}
+
+// logBuffer collects log output. It is only written through logOutput,
+// which serializes writes.
+type logBuffer struct {
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	logOutput.mu.Lock()
+	defer logOutput.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs sends the server's logs to the returned buffer until the test
+// ends.
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+	buf := &logBuffer{}
+	logOutput.set(buf)
+	t.Cleanup(func() { logOutput.set(os.Stderr) })
+	return buf
+}
+
+// newTestRouter serves the same routes as main.go.
+func newTestRouter() *gin.Engine {
+	r := gin.New()
+	if err := r.SetTrustedProxies(TrustedProxies()); err != nil {
+		panic(err)
+	}
+	r.Use(CORS())
+	r.GET("/ws", InitWebSocket())
+	r.GET("/board", GetBoard())
+	r.GET("/board.png", GetBoardPNG())
+	r.GET("/board/heat.png", GetHeatPNG())
+	r.GET("/board/raw", GetBoardRaw())
+	r.GET("/board/colors", GetColors())
+	r.GET("/board/changes", GetChanges())
+	r.GET("/board/at", GetBoardAt())
+	r.GET("/timelapse.gif", GetTimelapse())
+	r.GET("/pixel", GetPixel())
+	r.POST("/pixel", PostPixel())
+	r.GET("/cooldown", GetCooldown())
+	r.GET("/users", GetUsers())
+	r.GET("/metrics", Metrics())
+	r.GET("/stats", GetStats())
+	r.GET("/leaderboard", GetLeaderboard())
+	r.GET("/healthz", Healthz())
+	r.GET("/readyz", Readyz())
+
+	admin := r.Group("/admin", AdminAuth())
+	admin.POST("/reset", ResetBoard())
+	admin.POST("/resize", ResizeBoard())
+	admin.GET("/export", ExportBoard())
+	admin.POST("/import", ImportBoard())
+	admin.POST("/lock", LockRegion())
+	admin.POST("/unlock", UnlockRegion())
+	admin.POST("/freeze", FreezeBoard())
+	admin.POST("/unfreeze", UnfreezeBoard())
+	admin.POST("/region/fill", StampImage())
+	admin.POST("/message", SendNotice())
+	admin.GET("/bans", GetBans())
+	admin.POST("/ban", BanIdentity())
+	admin.POST("/unban", UnbanIdentity())
+	admin.POST("/drain", DrainServer())
+	admin.GET("/trusted", GetTrustedUsers())
+	admin.PUT("/trusted", SetTrustedUsers())
+	return r
+}
+
+// testAdminToken is the admin token of servers started by startServer.
+const testAdminToken = "test-admin-token"
+
+// adminHeader authorizes admin requests to servers started by startServer.
+func adminHeader() http.Header {
+	return http.Header{"Authorization": {"Bearer " + testAdminToken}}
+}
+
+// dial opens a websocket to srv with the given query string. The
+// connection is closed when the test ends.
+func dial(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	conn, _ := dialWith(t, srv, websocket.DefaultDialer, query)
+	return conn
+}
+
+// dialWith is dial with a chosen dialer, also returning the handshake
+// response.
+func dialWith(t *testing.T, srv *httptest.Server, dialer *websocket.Dialer, query string) (*websocket.Conn, *http.Response) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?" + query
+	conn, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("dialing %s: %v (status %d)", url, err, status)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp
+}
+
+// readType reads messages from conn until one of type typ arrives, and
+// decodes it into v.
+func readType(t *testing.T, conn *websocket.Conn, typ string, v any) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s message: %v", typ, err)
+		}
+		var header struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &header) != nil || header.Type != typ {
+			continue
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("decoding %s message: %v", typ, err)
+		}
+		return
+	}
+}
+
+// readClose reads from conn until the server closes it and returns the
+// close code, or fails the test if the connection ends without one.
+func readClose(t *testing.T, conn *websocket.Conn) int {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("connection ended without a close frame: %v", err)
+		}
+		return closeErr.Code
+	}
+}
+
+// send writes message to conn as JSON.
+func send(t *testing.T, conn *websocket.Conn, message any) {
+	t.Helper()
+	if err := conn.WriteJSON(message); err != nil {
+		t.Fatalf("sending %v: %v", message, err)
+	}
+}
+
+// request sends message with a request id and returns the ack or nack it
+// gets back. An ack comes back as an ErrorMessage of type "ack".
+func request(t *testing.T, conn *websocket.Conn, message map[string]any) ErrorMessage {
+	t.Helper()
+	message["reqId"] = uuid.NewString()
+	send(t, conn, message)
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for ack: %v", err)
+		}
+		var reply ErrorMessage
+		if json.Unmarshal(data, &reply) == nil && (reply.Type == "ack" || reply.Type == "nack") && reply.ReqID == message["reqId"] {
+			return reply
+		}
+	}
+}
+
+// place places a pixel and fails the test unless it is acknowledged.
+func place(t *testing.T, conn *websocket.Conn, x, y int, color string) {
+	t.Helper()
+	reply := request(t, conn, map[string]any{"type": "update", "x": x, "y": y, "color": color})
+	if reply.Type != "ack" {
+		t.Fatalf("placing at (%d, %d): %s", x, y, reply.Message)
+	}
+}
+
+// getJSON requests path from srv, decodes the response into v if it is
+// not nil and returns the status code.
+func getJSON(t *testing.T, srv *httptest.Server, path string, v any) int {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("decoding GET %s: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// postJSON posts body as JSON to path on srv with the given headers,
+// decodes the response into v if it is not nil and returns the status code.
+func postJSON(t *testing.T, srv *httptest.Server, path string, body any, header http.Header, v any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("decoding POST %s: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// newTestHub returns a hub with an in-memory board of the given size. Its
+// Run loop is not started.
+func newTestHub(width, height int) *Hub {
+	b := NewBoard(width, height)
+	return newHub(defaultRoom, b, b)
+}
+
+// fakeClock is a clock for hubs that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock makes h read the time from a fakeClock starting at a fixed
+// time.
+func useFakeClock(h *Hub) *fakeClock {
+	clock := &fakeClock{now: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)}
+	h.clock = clock.Now
+	return clock
+}
+
+// applyAs applies a black pixel at (x, y) from id on h, as its Run loop
+// would.
+func applyAs(h *Hub, id uuid.UUID, x, y int) error {
+	_, err := h.applyUpdate(Update{Type: "update", X: x, Y: y, Pixel: Pixel{A: 255}, SenderUUID: id})
+	return err
+}
+
+// addTestClient registers a client without a socket whose Send channel
+// holds buffer messages.
+func addTestClient(t *testing.T, h *Hub, username string, buffer int) *Client {
+	t.Helper()
+	client := &Client{
+		hub:      h,
+		uuid:     uuid.New(),
+		Send:     make(chan interface{}, buffer),
+		Username: username,
+		cancel:   func() {},
+	}
+	if err := h.addClient(client); err != nil {
+		t.Fatalf("adding %s: %v", username, err)
+	}
+	// Counted as the Run loop would, since closeAll uncounts it.
+	clientsConnected.Inc()
+	return client
+}
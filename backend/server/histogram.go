@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ColorCount struct {
+	Color string `json:"color"`
+	Pixel Pixel  `json:"pixel"`
+	Count int    `json:"count"`
+}
+
+// colorHistogram counts the colors inside region, most frequent first.
+func colorHistogram(pixels [][]Pixel, region Region) []ColorCount {
+	counts := make(map[Pixel]int)
+	for y := region.Y; y < region.Y+region.Height; y++ {
+		for x := region.X; x < region.X+region.Width; x++ {
+			counts[pixels[y][x]]++
+		}
+	}
+	histogram := make([]ColorCount, 0, len(counts))
+	for p, n := range counts {
+		histogram = append(histogram, ColorCount{Color: hexColor(p), Pixel: p, Count: n})
+	}
+	sort.Slice(histogram, func(i, j int) bool {
+		if histogram[i].Count != histogram[j].Count {
+			return histogram[i].Count > histogram[j].Count
+		}
+		return histogram[i].Color < histogram[j].Color
+	})
+	return histogram
+}
+
+// GetColors returns how many cells have each color, most frequent first.
+// The x, y, width and height query parameters restrict it to a rectangle.
+func GetColors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		// The region is checked against the snapshot itself, which a resize
+		// cannot change under it.
+		pixels := hub.store.Snapshot()
+		height := len(pixels)
+		width := 0
+		if height > 0 {
+			width = len(pixels[0])
+		}
+		region := Region{Width: width, Height: height}
+		if c.Query("x") != "" || c.Query("y") != "" || c.Query("width") != "" || c.Query("height") != "" {
+			var errs [4]error
+			region.X, errs[0] = strconv.Atoi(c.Query("x"))
+			region.Y, errs[1] = strconv.Atoi(c.Query("y"))
+			region.Width, errs[2] = strconv.Atoi(c.Query("width"))
+			region.Height, errs[3] = strconv.Atoi(c.Query("height"))
+			for _, err := range errs {
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "x, y, width and height must all be integers"})
+					return
+				}
+			}
+			if region.Width < 1 || region.Height < 1 || region.X < 0 || region.Y < 0 ||
+				region.Width > width-region.X || region.Height > height-region.Y {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "region must lie inside the board"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, colorHistogram(pixels, region))
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGetColors(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.BoardWidth, cfg.BoardHeight = 4, 4 })
+	conn := dial(t, srv, "username=a")
+	for _, x := range []int{0, 1, 2} {
+		place(t, conn, x, 0, "#ff4500")
+	}
+	place(t, conn, 3, 3, "#2450a4")
+
+	fill := hexColor(config.FillColor)
+	for _, tc := range []struct {
+		query string
+		want  map[string]int
+		order []string
+	}{
+		{"", map[string]int{fill: 12, "#ff4500": 3, "#2450a4": 1}, []string{fill, "#ff4500", "#2450a4"}},
+		{"?x=0&y=0&width=2&height=1", map[string]int{"#ff4500": 2}, []string{"#ff4500"}},
+		{"?x=2&y=2&width=2&height=2", map[string]int{fill: 3, "#2450a4": 1}, []string{fill, "#2450a4"}},
+	} {
+		var histogram []ColorCount
+		if status := getJSON(t, srv, "/board/colors"+tc.query, &histogram); status != http.StatusOK {
+			t.Fatalf("GET /board/colors%s: status %d", tc.query, status)
+		}
+		got := map[string]int{}
+		var order []string
+		for _, c := range histogram {
+			got[c.Color] = c.Count
+			order = append(order, c.Color)
+		}
+		if !reflect.DeepEqual(got, tc.want) || !reflect.DeepEqual(order, tc.order) {
+			t.Errorf("GET /board/colors%s = %+v, want %v in order %v", tc.query, histogram, tc.want, tc.order)
+		}
+	}
+
+	for _, query := range []string{
+		"?x=0",
+		"?x=3&y=0&width=2&height=1",
+		"?x=0&y=0&width=0&height=1",
+		"?x=1&y=0&width=9223372036854775807&height=1",
+		"?x=0&y=1&width=1&height=9223372036854775807",
+	} {
+		if status := getJSON(t, srv, "/board/colors"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /board/colors%s: status %d, want %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyCacheSize is how many reconstructed boards each room keeps.
+const historyCacheSize = 8
+
+// HistoricalBoard is the board as it was right after sequence number Seq.
+type HistoricalBoard struct {
+	Seq    uint64    `json:"seq"`
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Pixels [][]Pixel `json:"pixels"`
+}
+
+// historyCache keeps the most recently requested reconstructions, since a
+// dispute tends to bring several requests for the same sequence number.
+type historyCache struct {
+	mu     sync.Mutex
+	boards []HistoricalBoard
+}
+
+func (c *historyCache) get(seq uint64) (HistoricalBoard, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, board := range c.boards {
+		if board.Seq == seq {
+			return board, true
+		}
+	}
+	return HistoricalBoard{}, false
+}
+
+func (c *historyCache) add(board HistoricalBoard) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.boards) == historyCacheSize {
+		c.boards = c.boards[1:]
+	}
+	c.boards = append(c.boards, board)
+}
+
+// replayTo replays the event log in r onto an empty board, stopping after
+// the events of sequence number seq.
+func replayTo(r io.Reader, seq uint64) (HistoricalBoard, error) {
+	b := newReplayBoard()
+	err := ReadEvents(r, func(e Event) error {
+		if e.Seq > seq {
+			return errStopReplay
+		}
+		return b.applyEvent(e)
+	})
+	if err != nil && !errors.Is(err, errStopReplay) {
+		return HistoricalBoard{}, err
+	}
+	return HistoricalBoard{Seq: seq, Width: b.Width, Height: b.Height, Pixels: b.Pixels}, nil
+}
+
+// GetBoardAt reconstructs the board as it was after sequence number seq by
+// replaying the event log.
+func GetBoardAt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		path := roomPath(config.EventLogPath, hub.name)
+		if path == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event log is not enabled"})
+			return
+		}
+		seq, err := strconv.ParseUint(c.Query("seq"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "seq must be a sequence number"})
+			return
+		}
+		if current := hub.changes.seq(); seq > current {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "seq is ahead of the board", "seq": current})
+			return
+		}
+		if board, ok := hub.history.get(seq); ok {
+			c.JSON(http.StatusOK, board)
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		board, err := replayTo(f, seq)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		hub.history.add(board)
+		c.JSON(http.StatusOK, board)
+	}
+}
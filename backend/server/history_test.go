@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReplayToSequence(t *testing.T) {
+	useConfig(t, nil)
+	h := newTestHub(8, 8)
+	useFakeClock(h)
+	var log bytes.Buffer
+	h.events = NewEventLog(&log)
+	h.record(Event{Type: "resize", Width: 8, Height: 8, Ts: h.clock()})
+
+	id := uuid.New()
+	for x := range 3 {
+		if err := applyAs(h, id, x, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := h.store.Snapshot()
+	if err := h.reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyAs(h, id, 5, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	board, err := replayTo(bytes.NewReader(log.Bytes()), 3)
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if board.Seq != 3 || board.Width != 8 || board.Height != 8 {
+		t.Fatalf("replayed to seq %d at %dx%d, want seq 3 at 8x8", board.Seq, board.Width, board.Height)
+	}
+	if !reflect.DeepEqual(board.Pixels, want) {
+		t.Fatalf("board at seq 3 differs:\ngot  %v\nwant %v", board.Pixels, want)
+	}
+}
+
+func TestGetBoardAt(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.EventLogPath = filepath.Join(t.TempDir(), "events.jsonl") })
+	conn := dial(t, srv, "username=a")
+	place(t, conn, 0, 0, "#ff0000")
+	place(t, conn, 1, 0, "#00ff00")
+	place(t, conn, 0, 0, "#0000ff")
+
+	for _, tc := range []struct {
+		seq        string
+		at00, at10 Pixel
+	}{
+		{"0", config.FillColor, config.FillColor},
+		{"1", Pixel{R: 255, A: 255}, config.FillColor},
+		{"2", Pixel{R: 255, A: 255}, Pixel{G: 255, A: 255}},
+		{"3", Pixel{B: 255, A: 255}, Pixel{G: 255, A: 255}},
+		// Served from the cache the second time.
+		{"1", Pixel{R: 255, A: 255}, config.FillColor},
+	} {
+		var board HistoricalBoard
+		if status := getJSON(t, srv, "/board/at?seq="+tc.seq, &board); status != http.StatusOK {
+			t.Fatalf("seq %s: got %d", tc.seq, status)
+		}
+		if board.Pixels[0][0] != tc.at00 || board.Pixels[0][1] != tc.at10 {
+			t.Errorf("seq %s: (0, 0) is %v and (1, 0) %v, want %v and %v", tc.seq, board.Pixels[0][0], board.Pixels[0][1], tc.at00, tc.at10)
+		}
+	}
+
+	for _, seq := range []string{"4", "x"} {
+		if status := getJSON(t, srv, "/board/at?seq="+seq, nil); status != http.StatusBadRequest {
+			t.Errorf("seq %s: got %d, want %d", seq, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetBoardAtWithoutEventLog(t *testing.T) {
+	srv := startServer(t, nil)
+	if status := getJSON(t, srv, "/board/at?seq=0", nil); status != http.StatusNotFound {
+		t.Fatalf("got %d, want %d", status, http.StatusNotFound)
+	}
+}
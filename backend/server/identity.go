@@ -0,0 +1,105 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// identityCookie holds the identity token for browsers, which send it with
+// the websocket upgrade.
+const identityCookie = "rplace_identity"
+
+// identityKey signs identity tokens. It is config.IdentitySecret, or a
+// random key if none is set, in which case tokens only last until restart.
+var identityKey []byte
+
+// IdentityMessage gives a client the token to reconnect as the same user.
+// It is sent on every connect with a renewed expiry.
+type IdentityMessage struct {
+	Type      string    `json:"type"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// identityClaims are signed into identity tokens. The subject is the
+// client's id; Username is the name it asked for.
+type identityClaims struct {
+	Username string `json:"username,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func setIdentityKey(secret string) error {
+	if secret != "" {
+		identityKey = []byte(secret)
+		return nil
+	}
+	identityKey = make([]byte, 32)
+	if _, err := rand.Read(identityKey); err != nil {
+		return fmt.Errorf("generating identity key: %w", err)
+	}
+	return nil
+}
+
+// issueIdentity returns a token identifying id, valid for
+// config.IdentityTTL from now.
+func issueIdentity(id uuid.UUID, username string, now time.Time) (string, time.Time, error) {
+	expires := now.Add(config.IdentityTTL)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, identityClaims{
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   id.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}).SignedString(identityKey)
+	return token, expires, err
+}
+
+// parseIdentity verifies an identity token and returns its id and username.
+func parseIdentity(raw string) (uuid.UUID, string, error) {
+	claims := &identityClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return identityKey, nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil || id == uuid.Nil {
+		return uuid.Nil, "", errors.New("token has no valid subject")
+	}
+	return id, claims.Username, nil
+}
+
+// identityToken returns the identity token r carries, from the identity
+// query parameter or else the cookie.
+func identityToken(r *http.Request) string {
+	if token := r.URL.Query().Get("identity"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(identityCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// identityCookieHeader sets token as the identity cookie on the upgrade
+// response.
+func identityCookieHeader(r *http.Request, token string, expires time.Time) http.Header {
+	cookie := &http.Cookie{
+		Name:     identityCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return http.Header{"Set-Cookie": {cookie.String()}}
+}
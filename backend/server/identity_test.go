@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestIdentityKeepsCooldown(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Cooldown = time.Minute })
+	first, resp := dialWith(t, srv, websocket.DefaultDialer, "username=a")
+	var identity IdentityMessage
+	readType(t, first, "identity", &identity)
+	if identity.Token == "" || !identity.ExpiresAt.After(time.Now()) {
+		t.Fatalf("got identity %+v", identity)
+	}
+	var cookie bool
+	for _, c := range resp.Cookies() {
+		cookie = cookie || (c.Name == identityCookie && c.Value == identity.Token)
+	}
+	if !cookie {
+		t.Fatalf("upgrade did not set the identity cookie: %v", resp.Header["Set-Cookie"])
+	}
+	place(t, first, 0, 0, "#000000")
+	id := clientNamed(t, HubInstance, "a").uuid
+
+	// The token alone brings back the id, name and cooldown.
+	again := dial(t, srv, "identity="+url.QueryEscape(identity.Token))
+	reply := request(t, again, map[string]any{"type": "update", "x": 1, "y": 1, "color": "#000000"})
+	if reply.Code != "cooldown" {
+		t.Fatalf("reconnected placement got %s %q, want nack cooldown", reply.Type, reply.Code)
+	}
+	if client := clientNamed(t, HubInstance, "a"); client.uuid != id {
+		t.Fatalf("reconnected as %v, want %v", client.uuid, id)
+	}
+
+	t.Run("invalid token", func(t *testing.T) {
+		place(t, dial(t, srv, "username=b&identity=garbage"), 1, 1, "#000000")
+		if client := clientNamed(t, HubInstance, "b"); client.uuid == id {
+			t.Fatal("an invalid token reclaimed an identity")
+		}
+	})
+}
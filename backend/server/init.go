@@ -1,15 +1,50 @@
 package server
 
-func (b *Board) InitBoard() {
+func NewBoard(width, height int) *Board {
+	b := &Board{
+		Width:  width,
+		Height: height,
+		Pixels: make([][]Pixel, height),
+		Owners: make([][]PixelMeta, height),
+	}
+	for y := range b.Pixels {
+		b.Pixels[y] = make([]Pixel, width)
+		b.Owners[y] = make([]PixelMeta, width)
+	}
+	b.InitBoard()
+	return b
+}
 
-	for y := 0; y < boardHeight; y++ {
-		for x := 0; x < boardWidth; x++ {
-			b.Pixels[y][x] = Pixel{
-				R: 0,
-				G: 0,
-				B: 0,
-			}
+// InitBoard fills every cell with config.FillColor and clears its owner.
+func (b *Board) InitBoard() {
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			b.Pixels[y][x] = config.FillColor
+			b.Owners[y][x] = PixelMeta{}
 		}
 	}
+	b.painted = 0
+}
 
+func (b *Board) InBounds(x, y int) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.inBounds(x, y)
+}
+
+// inBounds is InBounds for callers that hold b.mu.
+func (b *Board) inBounds(x, y int) bool {
+	return x >= 0 && x < b.Width && y >= 0 && y < b.Height
+}
+
+// Snapshot returns a copy of the pixels that is safe to use after the lock is released.
+func (b *Board) Snapshot() [][]Pixel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pixels := make([][]Pixel, b.Height)
+	for y := range pixels {
+		pixels[y] = make([]Pixel, b.Width)
+		copy(pixels[y], b.Pixels[y])
+	}
+	return pixels
 }
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"image/color"
+	"image/png"
+	"net/http"
+	"testing"
+)
+
+func TestFreshBoardHasFillColor(t *testing.T) {
+	useConfig(t, nil)
+	white := Pixel{R: 255, G: 255, B: 255, A: 255}
+	for _, row := range NewBoard(3, 2).Snapshot() {
+		for _, p := range row {
+			if p != white {
+				t.Fatalf("default fill is %v, want white", p)
+			}
+		}
+	}
+
+	t.Setenv("RPLACE_FILL_COLOR", "#102030")
+	fill := Pixel{R: 0x10, G: 0x20, B: 0x30, A: 255}
+	if got := LoadConfig().FillColor; got != fill {
+		t.Fatalf("RPLACE_FILL_COLOR read as %v", got)
+	}
+	srv := startServer(t, func(cfg *Config) { cfg.FillColor = fill })
+
+	var init InitBoardState
+	readType(t, dial(t, srv, "username=a"), "init", &init)
+	for y, row := range init.Pixels {
+		for x, p := range row {
+			if p != fill {
+				t.Fatalf("init has %v at (%d, %d), want %v", p, x, y, fill)
+			}
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/board.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(7, 7)); got != (color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 255}) {
+		t.Fatalf("PNG has %v, want the fill color", got)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialFrom opens a websocket to srv claiming to come from ip through a
+// proxy. It returns the handshake status, and the connection if it
+// succeeded.
+func dialFrom(t *testing.T, srv *httptest.Server, ip string) (*websocket.Conn, int) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Forwarded-For": {ip}})
+	if err != nil {
+		if resp == nil {
+			t.Fatalf("dialing from %s: %v", ip, err)
+		}
+		return nil, resp.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	readType(t, conn, "init", &InitBoardState{})
+	return conn, resp.StatusCode
+}
+
+func TestConnectionsPerIP(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.MaxConnectionsPerIP = 2
+		cfg.TrustedProxies = []string{"127.0.0.1"}
+	})
+
+	first, _ := dialFrom(t, srv, "203.0.113.1")
+	dialFrom(t, srv, "203.0.113.1")
+	if _, status := dialFrom(t, srv, "203.0.113.1"); status != http.StatusTooManyRequests {
+		t.Fatalf("third connection from one IP got %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if conn, _ := dialFrom(t, srv, "203.0.113.2"); conn == nil {
+		t.Fatal("another IP was refused")
+	}
+
+	// Closing a connection frees its slot.
+	first.Close()
+	deadline := time.Now().Add(readTimeout)
+	for ipConnections("203.0.113.1") > 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the closed connection was never uncounted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if conn, _ := dialFrom(t, srv, "203.0.113.1"); conn == nil {
+		t.Fatal("reconnecting after closing was refused")
+	}
+}
+
+func TestForwardedForIgnoredFromUntrustedProxies(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.MaxConnectionsPerIP = 2
+		cfg.TrustedProxies = nil
+	})
+	dialFrom(t, srv, "203.0.113.1")
+	dialFrom(t, srv, "203.0.113.2")
+	if _, status := dialFrom(t, srv, "203.0.113.3"); status != http.StatusTooManyRequests {
+		t.Fatalf("a third connection from the same host got %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
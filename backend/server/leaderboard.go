@@ -0,0 +1,86 @@
+package server
+
+import (
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+// leaderboard counts placements per username. Users are counted by name,
+// so reconnecting under the same name keeps adding to the same count. It is
+// saved with the board snapshot.
+type leaderboard struct {
+	mu     sync.RWMutex
+	counts map[string]int
+}
+
+func (l *leaderboard) add(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = make(map[string]int)
+	}
+	l.counts[username]++
+}
+
+// snapshot returns a copy of the counts.
+func (l *leaderboard) snapshot() map[string]int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return maps.Clone(l.counts)
+}
+
+func (l *leaderboard) restore(counts map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts = counts
+}
+
+// top returns the limit users with the most placements, ties broken by
+// name.
+func (l *leaderboard) top(limit int) []UserCount {
+	l.mu.RLock()
+	users := make([]UserCount, 0, len(l.counts))
+	for name, n := range l.counts {
+		users = append(users, UserCount{Username: name, Pixels: n})
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Pixels != users[j].Pixels {
+			return users[i].Pixels > users[j].Pixels
+		}
+		return users[i].Username < users[j].Username
+	})
+	return users[:min(limit, len(users))]
+}
+
+// GetLeaderboard returns the users with the most placements. The limit
+// query parameter sets how many, up to 100.
+func GetLeaderboard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		limit := defaultLeaderboardLimit
+		if value := c.Query("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxLeaderboardLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxLeaderboardLimit)})
+				return
+			}
+			limit = n
+		}
+		c.JSON(http.StatusOK, hub.leaderboard.top(limit))
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLeaderboard(t *testing.T) {
+	srv := startServer(t, nil)
+	a := dial(t, srv, "username=a")
+	b := dial(t, srv, "username=b")
+	for x := range 2 {
+		place(t, a, x, 0, "#000000")
+	}
+	for x := range 3 {
+		place(t, b, x, 1, "#000000")
+	}
+	place(t, dial(t, srv, "username=c"), 0, 2, "#000000")
+
+	// A new connection under a name counts towards that name, once the old
+	// one has let go of it.
+	a.Close()
+	deadline := time.Now().Add(readTimeout)
+	for {
+		var users []UserInfo
+		getJSON(t, srv, "/users", &users)
+		if !slices.ContainsFunc(users, func(u UserInfo) bool { return u.Username == "a" }) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("a never disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	a = dial(t, srv, "username=a")
+	place(t, a, 2, 0, "#000000")
+	place(t, a, 3, 0, "#000000")
+
+	var top []UserCount
+	if status := getJSON(t, srv, "/leaderboard", &top); status != http.StatusOK {
+		t.Fatalf("GET /leaderboard: status %d", status)
+	}
+	if want := []UserCount{{"a", 4}, {"b", 3}, {"c", 1}}; !reflect.DeepEqual(top, want) {
+		t.Fatalf("leaderboard is %v, want %v", top, want)
+	}
+	getJSON(t, srv, "/leaderboard?limit=2", &top)
+	if len(top) != 2 || top[1].Username != "b" {
+		t.Fatalf("leaderboard with limit 2 is %v", top)
+	}
+	for _, limit := range []string{"0", "101", "x"} {
+		if status := getJSON(t, srv, "/leaderboard?limit="+limit, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /leaderboard?limit=%s: status %d, want %d", limit, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestLeaderboardSavedWithSnapshot(t *testing.T) {
+	useConfig(t, nil)
+	path := filepath.Join(t.TempDir(), "board.json")
+	b := NewBoard(4, 4)
+	b.Set(0, 0, Pixel{A: 255}, PixelMeta{Username: "a"})
+	if err := b.saveSnapshot(path, map[string]int{"a": 3, "b": 1}); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	counts, err := NewBoard(4, 4).loadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	var l leaderboard
+	l.restore(counts)
+	if got, want := l.top(10), []UserCount{{"a", 3}, {"b", 1}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("restored leaderboard is %v, want %v", got, want)
+	}
+}
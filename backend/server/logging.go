@@ -0,0 +1,15 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+)
+
+var (
+	logLevel = new(slog.LevelVar)
+	logger   = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+)
+
+func init() {
+	slog.SetDefault(logger)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMaxClients(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.MaxClients = 2
+		cfg.Rooms = []string{"other"}
+	})
+	first := dial(t, srv, "username=a")
+	readType(t, first, "init", &InitBoardState{})
+	// The cap counts every room.
+	readType(t, dial(t, srv, "username=b&room=other"), "init", &InitBoardState{})
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=c"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("connection over the cap was accepted")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("connection over the cap got %v, want status %d", resp, http.StatusServiceUnavailable)
+	}
+
+	// A place frees up once a client leaves.
+	first.Close()
+	deadline := time.Now().Add(readTimeout)
+	for totalClients() >= 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the first client never disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	readType(t, dial(t, srv, "username=c"), "init", &InitBoardState{})
+}
+
+func TestCloseCodes(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{errDraining, websocket.CloseGoingAway},
+		{errBanned, closeBanned},
+		{errServerFull, closeServerFull},
+		{errTooManyConns, closeTooManyConnections},
+		{errUsernameTaken, closeUsernameTaken},
+		{errNameLocked, websocket.ClosePolicyViolation},
+	} {
+		if got := closeCode(tc.err); got != tc.code {
+			t.Errorf("closeCode(%q) = %d, want %d", tc.err, got, tc.code)
+		}
+	}
+}
+
+func TestRejectionCloseCodes(t *testing.T) {
+	t.Run("banned", func(t *testing.T) {
+		srv := startServer(t, nil)
+		if status := postJSON(t, srv, "/admin/ban", BanRequest{Username: "troll"}, adminHeader(), nil); status != http.StatusOK {
+			t.Fatalf("banning: %d", status)
+		}
+		if code := readClose(t, dial(t, srv, "username=troll")); code != closeBanned {
+			t.Fatalf("closed with %d, want %d", code, closeBanned)
+		}
+	})
+
+	// Both connections pass the checks before the upgrade while the Run
+	// loop is held, so the second is turned away when it registers.
+	for _, tc := range []struct {
+		name string
+		edit func(*Config)
+		code int
+	}{
+		{"full", func(cfg *Config) { cfg.MaxClients = 1 }, closeServerFull},
+		{"per address", func(cfg *Config) { cfg.MaxConnectionsPerIP = 1 }, closeTooManyConnections},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := startServer(t, tc.edit)
+			entered, release := make(chan struct{}), make(chan struct{})
+			go HubInstance.do(func() {
+				close(entered)
+				<-release
+			})
+			<-entered
+			var once sync.Once
+			unblock := func() { once.Do(func() { close(release) }) }
+			t.Cleanup(unblock)
+
+			conns := []*websocket.Conn{dial(t, srv, "username=a"), dial(t, srv, "username=b")}
+			unblock()
+			var codes []int
+			for _, conn := range conns {
+				if code := admitted(t, conn); code != 0 {
+					codes = append(codes, code)
+				}
+			}
+			if len(codes) != 1 || codes[0] != tc.code {
+				t.Fatalf("closed with %v, want one connection closed with %d", codes, tc.code)
+			}
+		})
+	}
+
+	t.Run("bad origin", func(t *testing.T) {
+		srv := startServer(t, nil)
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=a"
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example"}})
+		if err == nil {
+			conn.Close()
+			t.Fatal("connection from a denied origin was upgraded")
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("denied origin got %v, want status %d", resp, http.StatusForbidden)
+		}
+	})
+}
+
+// admitted reads from conn until it gets the board, returning 0, or is
+// closed, returning the close code.
+func admitted(t *testing.T, conn *websocket.Conn) int {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	for {
+		var message struct{ Type string }
+		err := conn.ReadJSON(&message)
+		var closeErr *websocket.CloseError
+		switch {
+		case errors.As(err, &closeErr):
+			return closeErr.Code
+		case err != nil:
+			t.Fatalf("reading: %v", err)
+		case message.Type == "init":
+			return 0
+		}
+	}
+}
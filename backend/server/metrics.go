@@ -0,0 +1,86 @@
+package server
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	clientsConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "rplace_clients_connected",
+		Help: "Number of currently connected websocket clients.",
+	})
+	pixelsPlaced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_pixels_placed_total",
+		Help: "Total number of pixels applied to the board.",
+	})
+	broadcastDrops = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_broadcast_drops_total",
+		Help: "Clients unregistered because their send channel was full.",
+	})
+	updatesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_updates_dropped_total",
+		Help: "Placements rejected because a hub's broadcast buffer was full.",
+	})
+	messagesThrottled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_messages_throttled_total",
+		Help: "Websocket messages ignored because the client exceeded its message rate.",
+	})
+	messagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rplace_messages_total",
+		Help: "Websocket messages received from (in) and sent to (out) clients, by type.",
+	}, []string{"direction", "type"})
+	messagesTooLarge = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_messages_too_large_total",
+		Help: "Websocket connections closed because a message exceeded the size limit.",
+	})
+	sendOverflows = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_send_overflows_total",
+		Help: "Messages dropped because a client's send channel was full.",
+	})
+	storeBuffered = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "rplace_store_buffered_writes",
+		Help: "Cells waiting to be written to an unreachable board store.",
+	})
+	upgradeFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rplace_websocket_upgrade_failures_total",
+		Help: "Websocket upgrades that failed.",
+	})
+)
+
+// clientMessageTypes are the message types clients send. Any other type is
+// placed as an update, and counted as one so clients cannot add labels.
+var clientMessageTypes = map[string]bool{
+	"update": true, "undo": true, "fill": true, "draw_rect": true, "draw_line": true,
+	"cursor": true, "subscribe": true, "resync": true, "set_name": true,
+}
+
+func countReceived(typ string) {
+	if !clientMessageTypes[typ] {
+		typ = "update"
+	}
+	messagesTotal.WithLabelValues("in", typ).Inc()
+}
+
+// messageType returns the Type field of an outgoing message. Binary frames
+// only carry the board, so they are counted as init.
+func messageType(message interface{}) string {
+	if _, ok := message.(binaryMessage); ok {
+		return "init"
+	}
+	v := reflect.Indirect(reflect.ValueOf(message))
+	if v.Kind() == reflect.Struct {
+		if field := v.FieldByName("Type"); field.Kind() == reflect.String {
+			return field.String()
+		}
+	}
+	return "other"
+}
+
+func Metrics() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrape returns the value of the series named series, labels included as
+// they appear in the exposition format, from srv's /metrics. Missing
+// series read as zero.
+func scrape(t *testing.T, srv *httptest.Server, series string) float64 {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), series+" ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", series, err)
+		}
+		return n
+	}
+	return 0
+}
+
+func TestMetricsCountPlacements(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+
+	before := scrape(t, srv, "rplace_pixels_placed_total")
+	if connected := scrape(t, srv, "rplace_clients_connected"); connected < 1 {
+		t.Fatalf("rplace_clients_connected is %v with a client connected", connected)
+	}
+	place(t, conn, 0, 0, "#000000")
+	if after := scrape(t, srv, "rplace_pixels_placed_total"); after != before+1 {
+		t.Fatalf("rplace_pixels_placed_total went from %v to %v, want one more", before, after)
+	}
+}
+
+func TestMetricsCountMessagesByType(t *testing.T) {
+	srv := startServer(t, nil)
+	series := func(direction, typ string) string {
+		return `rplace_messages_total{direction="` + direction + `",type="` + typ + `"}`
+	}
+	count := func(direction, typ string) float64 { return scrape(t, srv, series(direction, typ)) }
+	before := map[string]float64{}
+	for _, s := range [][2]string{{"in", "update"}, {"in", "cursor"}, {"in", "subscribe"}, {"out", "init"}, {"out", "ack"}} {
+		before[s[0]+" "+s[1]] = count(s[0], s[1])
+	}
+
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+	send(t, conn, map[string]any{"type": "cursor", "x": 1, "y": 1})
+	send(t, conn, map[string]any{"type": "subscribe", "region": Region{Width: 4, Height: 4}})
+	place(t, conn, 0, 0, "#000000")
+	// Unknown types are placed, and counted, as updates.
+	place(t, conn, 1, 0, "#000000")
+	send(t, conn, map[string]any{"type": "made_up", "x": 2, "y": 0, "color": "#000000"})
+	// Messages are counted in order, so an ack means everything before it
+	// was counted.
+	place(t, conn, 3, 0, "#000000")
+
+	for _, tc := range []struct {
+		direction, typ string
+		want           float64
+	}{
+		{"in", "update", 4},
+		{"in", "cursor", 1},
+		{"in", "subscribe", 1},
+		{"out", "init", 1},
+	} {
+		if got := count(tc.direction, tc.typ) - before[tc.direction+" "+tc.typ]; got != tc.want {
+			t.Errorf("%s went up by %v, want %v", series(tc.direction, tc.typ), got, tc.want)
+		}
+	}
+	if got := count("out", "ack") - before["out ack"]; got < 2 {
+		t.Errorf("%s went up by %v, want at least 2", series("out", "ack"), got)
+	}
+	if count("in", "made_up") != 0 {
+		t.Error("an unknown message type got its own series")
+	}
+}
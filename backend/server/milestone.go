@@ -0,0 +1,80 @@
+package server
+
+import (
+	"github.com/google/uuid"
+)
+
+// MilestoneMessage announces that Percent of the board has been painted.
+type MilestoneMessage struct {
+	Type    string `json:"type"`
+	Percent int    `json:"percent"`
+}
+
+// isPainted reports whether p differs from the fill color. Alpha is
+// ignored, as in the coverage stats.
+func isPainted(p Pixel) bool {
+	return p.R != config.FillColor.R || p.G != config.FillColor.G || p.B != config.FillColor.B
+}
+
+// setPixel sets the cell at (x, y), keeping the painted count. The caller
+// must hold b.mu.
+func (b *Board) setPixel(x, y int, p Pixel) {
+	if was, is := isPainted(b.Pixels[y][x]), isPainted(p); was != is {
+		if is {
+			b.painted++
+		} else {
+			b.painted--
+		}
+	}
+	b.Pixels[y][x] = p
+}
+
+// recount counts the painted cells from scratch after the pixels were
+// replaced wholesale. The caller must hold b.mu.
+func (b *Board) recount() {
+	b.painted = 0
+	for _, row := range b.Pixels {
+		for _, p := range row {
+			if isPainted(p) {
+				b.painted++
+			}
+		}
+	}
+}
+
+// Painted returns how many cells are painted and how many there are.
+func (b *Board) Painted() (painted, total int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.painted, b.Width * b.Height
+}
+
+// reached returns how many of config.Milestones the board has reached.
+func (h *Hub) reached() int {
+	painted, total := h.board.Painted()
+	n := 0
+	for n < len(config.Milestones) && painted*100 >= config.Milestones[n]*total {
+		n++
+	}
+	return n
+}
+
+// checkMilestones announces each milestone the board has newly reached;
+// h.milestone counts those already announced. A milestone is announced
+// once: falling back below it and crossing it again does not repeat it,
+// until the board is reset. It is only called from the hub's Run loop.
+func (h *Hub) checkMilestones() {
+	if h.milestone >= len(config.Milestones) {
+		return
+	}
+	reached := h.reached()
+	if reached <= h.milestone {
+		return
+	}
+	h.flushBatch()
+	for ; h.milestone < reached; h.milestone++ {
+		percent := config.Milestones[h.milestone]
+		logger.Info("Board reached milestone", "room", h.name, "percent", percent)
+		h.broadcastMessage(MilestoneMessage{Type: "milestone", Percent: percent}, uuid.Nil)
+	}
+}
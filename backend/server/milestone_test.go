@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// milestones returns the percentages announced to client so far.
+func milestones(client *Client) []int {
+	var percents []int
+	for {
+		select {
+		case message := <-client.Send:
+			if m, ok := message.(MilestoneMessage); ok {
+				percents = append(percents, m.Percent)
+			}
+		default:
+			return percents
+		}
+	}
+}
+
+func TestMilestonesFireOnce(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.Milestones = []int{25, 50} })
+	h := newTestHub(4, 4)
+	client := addTestClient(t, h, "watcher", 64)
+	id := uuid.New()
+	paint := func(x, y int, p Pixel) {
+		t.Helper()
+		if _, err := h.applyUpdate(Update{Type: "update", X: x, Y: y, Pixel: p, SenderUUID: id}); err != nil {
+			t.Fatal(err)
+		}
+		h.checkMilestones()
+	}
+	black := Pixel{A: 255}
+
+	for x := range 3 {
+		paint(x, 0, black)
+	}
+	if got := milestones(client); len(got) != 0 {
+		t.Fatalf("3 of 16 cells announced %v", got)
+	}
+	paint(3, 0, black)
+	if got := milestones(client); len(got) != 1 || got[0] != 25 {
+		t.Fatalf("4 of 16 cells announced %v, want [25]", got)
+	}
+
+	// Dropping back below 25% and crossing it again stays quiet.
+	paint(3, 0, config.FillColor)
+	paint(3, 0, black)
+	paint(3, 0, Pixel{R: 1, A: 255})
+	if got := milestones(client); len(got) != 0 {
+		t.Fatalf("recrossing 25%% announced %v", got)
+	}
+
+	for x := range 4 {
+		paint(x, 1, black)
+	}
+	if got := milestones(client); len(got) != 1 || got[0] != 50 {
+		t.Fatalf("8 of 16 cells announced %v, want [50]", got)
+	}
+}
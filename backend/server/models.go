@@ -1,8 +1,11 @@
 package server
 
 import (
-	"net/http"
+	"compress/flate"
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,69 +13,275 @@ import (
 )
 
 const (
-	writeWait      = 10 * time.Second
-	pongWait       = 180 * time.Second
-	pingPeriod     = (pongWait * 15) / 10
-	maxMessageSize = 512
-	boardWidth     = 10
-	boardHeight    = 10
+	defaultAddr = ":8080"
+
+	defaultIdentityTTL = 30 * 24 * time.Hour
+
+	defaultWriteWait  = 10 * time.Second
+	defaultPongWait   = 180 * time.Second
+	defaultPingPeriod = (defaultPongWait * 9) / 10
+
+	defaultMaxMessageSize = 512
+
+	defaultCompressionLevel = flate.BestSpeed
+	defaultBufferSize       = 4096
+
+	defaultMessageRate  = 10
+	defaultMessageBurst = 20
+	defaultSendBuffer   = 256
+
+	defaultBroadcastBuffer = 256
+
+	defaultBoardWidth  = 10
+	defaultBoardHeight = 10
+	defaultCooldown    = 5 * time.Second
+
+	defaultMaxBoardCells  = 4_000_000
+	defaultMaxBoardMemory = 1 << 30
+
+	defaultBucketCapacity = 5
+	defaultBucketRefill   = 5 * time.Second
+
+	defaultBotCooldown = time.Second
+
+	defaultChangeLogSize = 10000
+
+	defaultCursorInterval = 50 * time.Millisecond
+
+	defaultMaxOperationCells = 1000
+
+	defaultPaintLimitRegion = 16
+	defaultPaintLimitWindow = time.Minute
+
+	defaultConsensusWindow = time.Minute
+
+	defaultDrainGrace = 30 * time.Second
+
+	defaultSnapshotPath     = "board.json"
+	defaultSnapshotInterval = time.Minute
+
+	defaultRedisPrefix = "rplace"
+
+	defaultStoreBufferSize    = 10000
+	defaultStoreRetryInterval = time.Second
+
+	defaultAuditPath = "audit.log"
 )
 
 type Pixel struct {
 	R uint8 `json:"r"`
 	G uint8 `json:"g"`
 	B uint8 `json:"b"`
+	A uint8 `json:"a"`
+}
+
+type cell struct {
+	X int
+	Y int
+}
+
+// PixelMeta records who last placed a pixel and when.
+type PixelMeta struct {
+	Username string    `json:"username"`
+	UUID     uuid.UUID `json:"uuid"`
+	PlacedAt time.Time `json:"placedAt"`
+	// Bot is set for pixels placed by a bot with an API key.
+	Bot bool `json:"bot,omitempty"`
 }
 
 type Board struct {
 	Width  int
 	Height int
-	Pixels [boardHeight][boardWidth]Pixel
+	Pixels [][]Pixel
+	Owners [][]PixelMeta
 	mu     sync.RWMutex
+
+	// version counts changes to the board and savedVersion is the version
+	// last written to a snapshot. dirty bounds the cells changed since.
+	version      uint64
+	savedVersion uint64
+	dirty        Region
+
+	// painted counts the cells that differ from the fill color.
+	painted int
 }
 
 type Client struct {
+	hub      *Hub
 	uuid     uuid.UUID
 	Socket   *websocket.Conn
-	Send     chan Update
+	Send     chan interface{}
 	Username string
+	// protocol is the negotiated message schema version.
+	protocol int
+	// readOnly clients receive updates but may not place pixels.
+	readOnly bool
+	// spectator clients, such as stream overlays, only watch. Their
+	// placements are ignored and they are not listed as users.
+	spectator bool
+	// queue clients have placements made during their cooldown applied
+	// when it ends instead of rejected.
+	queue bool
+
+	// ip is the client's address, for the per-IP connection limit.
+	ip string
+
+	// publicID identifies the client to other users without exposing uuid.
+	publicID    string
+	connectedAt time.Time
+	// lastActivity is when the client last sent a message or a pong, in
+	// Unix nanoseconds. Read updates it and the hub's reaper checks it.
+	lastActivity atomic.Int64
+	// lastCursor is when the client's last cursor move was passed on. It
+	// is only accessed from the client's Read loop.
+	lastCursor time.Time
+
+	// registered receives the hub's verdict once the client is registered.
+	registered chan error
+	// ctx lasts as long as the client is registered. cancel is only called
+	// through close, when the hub removes the client.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closeErr is why the hub closed the connection, sent to the client in
+	// the close frame. It is set before close and nil for normal closures.
+	closeErr error
+	// viewport limits the updates sent to the client. Nil means the whole
+	// board. It is only accessed from the hub's Run loop.
+	viewport *Region
+	// overflows counts the broadcasts in a row that found Send full. It is
+	// only accessed from the hub's Run loop.
+	overflows int
 }
 
 type InitBoardState struct {
-	Type   string                         `json:"type"`
-	Pixels [boardHeight][boardWidth]Pixel `json:"pixels"`
+	Type   string    `json:"type"`
+	Seq    uint64    `json:"seq"`
+	Pixels [][]Pixel `json:"pixels"`
 }
 type Update struct {
-	Type       string    `json:"type"`
-	Pixel      Pixel     `json:"pixel"`
-	X          int       `json:"x"`
-	Y          int       `json:"y"`
+	Type  string `json:"type"`
+	Pixel Pixel  `json:"pixel"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Seq   uint64 `json:"seq"`
+	// Ts is when the server applied the update.
+	Ts         time.Time `json:"ts"`
 	SenderUUID uuid.UUID `json:"-"`
+	SenderName string    `json:"username,omitempty"`
+	// ReqID is an optional id the client gives a placement to have it
+	// acknowledged. It is not passed on to other clients.
+	ReqID string `json:"reqId,omitempty"`
+
+	// result, when set, receives the outcome once the hub has handled the update.
+	result chan placeResult
+	// cells lists the cells of a draw_rect or draw_line operation.
+	cells []cell
+}
+
+type PixelInfo struct {
+	X     int        `json:"x"`
+	Y     int        `json:"y"`
+	Pixel Pixel      `json:"pixel"`
+	Owner *PixelMeta `json:"owner"`
+}
+
+type UserInfo struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	// LastActive is when the user last sent a message or answered a ping.
+	LastActive time.Time `json:"lastActive"`
+}
+
+type PresenceMessage struct {
+	Type     string `json:"type"`
+	Event    string `json:"event"`
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// ErrorMessage tells a client why its message was rejected. Code is stable
+// and machine-readable; Message is for people. X and Y are set when the
+// rejection concerns a cell, RemainingMs when the code is "cooldown".
+type ErrorMessage struct {
+	Type        string `json:"type"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	X           *int   `json:"x,omitempty"`
+	Y           *int   `json:"y,omitempty"`
+	RemainingMs int64  `json:"remainingMs,omitempty"`
+	// ReqID echoes the request id of a rejected placement, whose Type is
+	// then "nack".
+	ReqID string `json:"reqId,omitempty"`
 }
 
 type Hub struct {
-	clients    map[uuid.UUID]*Client
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan Update
-	mu         sync.RWMutex
+	// name is the room the hub serves. Each room has its own board.
+	name   string
+	board  *Board
+	store  BoardStore
+	events *EventLog
+
+	clients     map[uuid.UUID]*Client
+	roster      roster
+	register    chan *Client
+	unregister  chan *Client
+	broadcast   chan Update
+	commands    chan func()
+	names       map[string]uuid.UUID
+	cooldowns   map[uuid.UUID]time.Time
+	hotCells    map[cell]time.Time
+	buckets     map[uuid.UUID]*tokenBucket
+	placements  map[uuid.UUID]placement
+	paints      map[paintKey][]time.Time
+	intents     map[uuid.UUID]*intent
+	votes       map[cell]map[Pixel]map[uuid.UUID]time.Time
+	ips         map[string]int
+	locks       []Region
+	batch       updateBatch
+	changes     *changeLog
+	stats       hubStats
+	leaderboard leaderboard
+	coverage    coverageCache
+	milestone   int
+	history     historyCache
+	mu          sync.RWMutex
+	clock       func() time.Time
+
+	// running is set while the Run loop is active.
+	running      atomic.Bool
+	quit         chan struct{}
+	done         chan struct{}
+	closing      atomic.Bool
+	shutdownOnce sync.Once
+	// writers counts the clients' Write loops. Only the Run loop adds to
+	// it, as it registers each client.
+	writers sync.WaitGroup
 }
 
+// defaultFillColor is white, like r/place.
+var defaultFillColor = Pixel{R: 255, G: 255, B: 255, A: 255}
+
 var (
-	HubInstance = &Hub{
-		clients:    make(map[uuid.UUID]*Client),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan Update),
-	}
-	board = &Board{
-		Width:  boardWidth,
-		Height: boardHeight,
-	}
+	errUsernameTaken = errors.New("username is already taken")
+	errServerFull    = errors.New("server is full")
+	errTooManyConns  = errors.New("too many connections from this address")
+	errServerBusy    = errors.New("server is busy, try again")
+	errHubStopped    = errors.New("server is shutting down")
+)
+
+var (
+	// HubInstance serves the default room.
+	HubInstance  = newHub(defaultRoom, defaultBoard, defaultBoard)
+	defaultBoard = NewBoard(defaultBoardWidth, defaultBoardHeight)
 
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		ReadBufferSize:  defaultBufferSize,
+		WriteBufferSize: defaultBufferSize,
+		CheckOrigin:     checkOrigin,
+		Subprotocols:    subprotocols,
 	}
+	// writeBufferPool is shared by every connection with
+	// config.SharedWriteBuffers.
+	writeBufferPool = &sync.Pool{}
 )
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxNoticeLength = 500
+
+var errClientOffline = errors.New("no such client is connected")
+
+// NoticeMessage is a message from the moderators to a single client.
+type NoticeMessage struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NoticeRequest names the client to notify by its username, its public id
+// as listed by /users, or its uuid.
+type NoticeRequest struct {
+	Target string `json:"target" binding:"required"`
+	Text   string `json:"text" binding:"required"`
+}
+
+// findClient returns the connected client whose username, public id or
+// uuid is target. The caller must hold h.mu.
+func (h *Hub) findClient(target string) (*Client, bool) {
+	if id, ok := h.names[target]; ok {
+		return h.clients[id], true
+	}
+	if id, err := uuid.Parse(target); err == nil {
+		if client, ok := h.clients[id]; ok {
+			return client, true
+		}
+	}
+	for _, client := range h.clients {
+		if client.publicID == target {
+			return client, true
+		}
+	}
+	return nil, false
+}
+
+// notify sends text to the client named by target. It is only called from
+// the hub's Run loop.
+func (h *Hub) notify(target, text string) error {
+	h.mu.RLock()
+	client, ok := h.findClient(target)
+	h.mu.RUnlock()
+	if !ok {
+		return errClientOffline
+	}
+	h.sendTo(client.uuid, NoticeMessage{Type: "notice", Text: text})
+	logger.Info("Sent notice", "room", h.name, "uuid", client.uuid, "username", client.Username)
+	return nil
+}
+
+// SendNotice lets moderators warn or notify a single connected user.
+func SendNotice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req NoticeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		text := strings.TrimSpace(req.Text)
+		if text == "" || len([]rune(text)) > maxNoticeLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "text must be 1 to " + strconv.Itoa(maxNoticeLength) + " characters"})
+			return
+		}
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+
+		var err error
+		if !hub.do(func() { err = hub.notify(req.Target, text) }) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"delivered": true})
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSendNotice(t *testing.T) {
+	srv := startServer(t, nil)
+	alice := dial(t, srv, "username=alice")
+	bob := dial(t, srv, "username=bob")
+	readType(t, bob, "init", &InitBoardState{})
+
+	if status := postJSON(t, srv, "/admin/message", NoticeRequest{Target: "alice", Text: "please stop"}, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("notifying alice: %d", status)
+	}
+	var notice NoticeMessage
+	if readType(t, alice, "notice", &notice); notice.Text != "please stop" {
+		t.Fatalf("alice got %+v", notice)
+	}
+
+	// Bob's first notice is the one sent to him by public id, so he never
+	// saw alice's.
+	var users []UserInfo
+	getJSON(t, srv, "/users", &users)
+	var bobID string
+	for _, u := range users {
+		if u.Username == "bob" {
+			bobID = u.ID
+		}
+	}
+	if status := postJSON(t, srv, "/admin/message", NoticeRequest{Target: bobID, Text: "hello bob"}, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("notifying bob: %d", status)
+	}
+	if readType(t, bob, "notice", &notice); notice.Text != "hello bob" {
+		t.Fatalf("bob got %+v", notice)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		req    NoticeRequest
+		header http.Header
+		status int
+	}{
+		{"offline", NoticeRequest{Target: "carol", Text: "hi"}, adminHeader(), http.StatusNotFound},
+		{"blank", NoticeRequest{Target: "alice", Text: "  "}, adminHeader(), http.StatusBadRequest},
+		{"unauthorized", NoticeRequest{Target: "alice", Text: "hi"}, nil, http.StatusUnauthorized},
+	} {
+		if status := postJSON(t, srv, "/admin/message", tc.req, tc.header, nil); status != tc.status {
+			t.Errorf("%s: got %d, want %d", tc.name, status, tc.status)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errTooLarge = errors.New("operation covers too many cells")
+
+// paint writes message's pixel to every cell as a single placement: the
+// sender is charged one cooldown for all of them. Locked cells, and cells
+// still cooling down with PlacementLimit "cell", are left untouched. If
+// storing a cell fails, the cells painted so far are returned with the
+// error. It is only called from the hub's Run loop.
+func (h *Hub) paint(message Update, cells []cell) ([]Update, error) {
+	if consensusEnabled() {
+		return nil, errConsensusOnly
+	}
+	if !inPalette(message.Pixel) {
+		return nil, errNotInPalette
+	}
+	now := h.clock()
+	trusted := isTrusted(message.SenderUUID)
+	if !trusted {
+		if remaining := h.cooldownRemaining(message.SenderUUID, now); remaining > 0 {
+			return nil, &cooldownError{remaining: remaining}
+		}
+	}
+	var unlocked []cell
+	// cooling is the shortest wait for a cell skipped because it was
+	// painted too recently.
+	var cooling time.Duration
+	for _, c := range cells {
+		if h.locked(c.X, c.Y) {
+			continue
+		}
+		if remaining := h.cellCooldownRemaining(c, now); remaining > 0 && !trusted {
+			if cooling == 0 || remaining < cooling {
+				cooling = remaining
+			}
+			continue
+		}
+		unlocked = append(unlocked, c)
+	}
+	if len(unlocked) == 0 && cooling > 0 {
+		return nil, &cooldownError{remaining: cooling}
+	}
+	if !trusted {
+		if err := h.checkPaintLimit(message.SenderUUID, unlocked, now); err != nil {
+			return nil, err
+		}
+	}
+
+	meta := PixelMeta{
+		Username: message.SenderName,
+		UUID:     message.SenderUUID,
+		PlacedAt: now,
+		Bot:      isBot(message.SenderUUID),
+	}
+	updates := make([]Update, 0, len(unlocked))
+	for _, c := range unlocked {
+		u := message
+		u.Type = "update"
+		u.X, u.Y = c.X, c.Y
+		u.Ts = now
+		if config.AlphaMode == "blend" {
+			current, _ := h.store.Get(c.X, c.Y)
+			u.Pixel = blendOver(u.Pixel, current)
+		}
+		u, err := h.write(u, meta)
+		if err != nil {
+			return updates, err
+		}
+		updates = append(updates, u)
+	}
+	if len(updates) == 0 {
+		return nil, nil
+	}
+
+	h.recordPlacement(message.SenderUUID, unlocked, now)
+	if !trusted {
+		h.recordPaint(message.SenderUUID, unlocked, now)
+	}
+	h.stats.record(now)
+	h.leaderboard.add(message.SenderName)
+	pixelsPlaced.Add(float64(len(updates)))
+	return updates, nil
+}
+
+// fill paints the area of cells connected to (message.X, message.Y) that
+// share its color. Locked cells bound the area like other colors do. It
+// fails without painting anything if the area exceeds
+// config.MaxOperationCells.
+func (h *Hub) fill(message Update) ([]Update, error) {
+	if !h.store.InBounds(message.X, message.Y) {
+		return nil, errOutOfBounds
+	}
+	if h.locked(message.X, message.Y) {
+		return nil, errRegionLocked
+	}
+	target, _ := h.store.Get(message.X, message.Y)
+	if target == message.Pixel {
+		return nil, nil
+	}
+
+	start := cell{X: message.X, Y: message.Y}
+	seen := map[cell]bool{start: true}
+	queue := []cell{start}
+	var area []cell
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		area = append(area, c)
+		if len(area) > config.MaxOperationCells {
+			return nil, errTooLarge
+		}
+		for _, n := range []cell{{c.X + 1, c.Y}, {c.X - 1, c.Y}, {c.X, c.Y + 1}, {c.X, c.Y - 1}} {
+			if seen[n] || !h.store.InBounds(n.X, n.Y) || h.locked(n.X, n.Y) {
+				continue
+			}
+			seen[n] = true
+			if p, _ := h.store.Get(n.X, n.Y); p == target {
+				queue = append(queue, n)
+			}
+		}
+	}
+	return h.paint(message, area)
+}
+
+// publishAll sends the updates of one operation to every client as a
+// single batch. It reports whether a new batch window started.
+func (h *Hub) publishAll(updates []Update) bool {
+	if len(updates) == 0 {
+		return false
+	}
+	if config.BatchWindow <= 0 {
+		h.broadcastMessage(BatchMessage{
+			Type:    "batch",
+			Updates: updates,
+		}, uuid.Nil)
+		return false
+	}
+	started := false
+	for _, u := range updates {
+		if h.batch.add(u) {
+			started = true
+		}
+	}
+	return started
+}
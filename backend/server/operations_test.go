@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+// painted lists the cells of the default room that have color, in row
+// order.
+func painted(color Pixel) []cell {
+	var cells []cell
+	for y, row := range HubInstance.store.Snapshot() {
+		for x, p := range row {
+			if p == color {
+				cells = append(cells, cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+func TestFill(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.BoardWidth, cfg.BoardHeight = 6, 4
+		cfg.MaxOperationCells = 10
+	})
+	conn := dial(t, srv, "username=a")
+	watcher := dial(t, srv, "username=watcher")
+	// A wall at x = 2 splits the board into 8 cells left and 12 right.
+	for y := range 4 {
+		place(t, conn, 2, y, "#000000")
+	}
+	readType(t, watcher, "init", &InitBoardState{})
+
+	if reply := request(t, conn, map[string]any{"type": "fill", "x": 1, "y": 2, "color": "#ff4500"}); reply.Type != "ack" {
+		t.Fatalf("fill got %s %q", reply.Type, reply.Message)
+	}
+	red := Pixel{R: 0xff, G: 0x45, A: 255}
+	want := []cell{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}, {0, 3}, {1, 3}}
+	if got := painted(red); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("fill painted %v, want %v", got, want)
+	}
+	var batch BatchMessage
+	readType(t, watcher, "batch", &batch)
+	if len(batch.Updates) != len(want) {
+		t.Fatalf("watcher got a batch of %d updates, want %d", len(batch.Updates), len(want))
+	}
+
+	reply := request(t, conn, map[string]any{"type": "fill", "x": 4, "y": 0, "color": "#2450a4"})
+	if reply.Code != "too_large" {
+		t.Fatalf("fill over the cap got %s %q, want nack too_large", reply.Type, reply.Code)
+	}
+	if got := painted(Pixel{R: 0x24, G: 0x50, B: 0xa4, A: 255}); len(got) != 0 {
+		t.Fatalf("refused fill painted %v", got)
+	}
+}
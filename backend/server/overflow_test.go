@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeliverDropOldest(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.SendOverflow = "drop_oldest" })
+	h := newTestHub(8, 8)
+	client := addTestClient(t, h, "slow", 2)
+
+	for x := range 5 {
+		if !h.deliver(client, Update{X: x}) {
+			t.Fatalf("update %d disconnected the client", x)
+		}
+	}
+	if client.overflows != 3 {
+		t.Fatalf("counted %d overflows, want 3", client.overflows)
+	}
+	for _, want := range []int{3, 4} {
+		if got := (<-client.Send).(Update).X; got != want {
+			t.Fatalf("got update %d, want the newest ones, %d", got, want)
+		}
+	}
+}
+
+func TestDeliverDisconnectsAfterLimit(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.SendOverflow = "disconnect"
+		cfg.SendOverflowLimit = 3
+	})
+	h := newTestHub(8, 8)
+	client := addTestClient(t, h, "slow", 1)
+
+	if !h.deliver(client, Update{X: 0}) {
+		t.Fatal("update into an empty buffer disconnected the client")
+	}
+	for x := 1; x <= 2; x++ {
+		if !h.deliver(client, Update{X: x}) {
+			t.Fatalf("overflow %d of 3 disconnected the client", x)
+		}
+	}
+	// Draining the buffer starts the count over.
+	<-client.Send
+	if !h.deliver(client, Update{X: 3}) || client.overflows != 0 {
+		t.Fatalf("delivery after draining left %d overflows", client.overflows)
+	}
+	for x := 4; x <= 5; x++ {
+		if !h.deliver(client, Update{X: x}) {
+			t.Fatalf("overflow %d of 3 disconnected the client", x-3)
+		}
+	}
+	if h.deliver(client, Update{X: 6}) {
+		t.Fatal("third overflow in a row kept the client")
+	}
+	if got := (<-client.Send).(Update).X; got != 3 {
+		t.Fatalf("queue holds update %d, want 3", got)
+	}
+}
+
+func TestBroadcastOverflowDrop(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.BroadcastBuffer = 1
+		cfg.BroadcastOverflow = "drop"
+	})
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+	dropped := scrape(t, srv, "rplace_updates_dropped_total")
+
+	// Hold up the Run loop so placements pile up in the buffer.
+	entered, release := make(chan struct{}), make(chan struct{})
+	go HubInstance.do(func() {
+		close(entered)
+		<-release
+	})
+	<-entered
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+
+	for x := range 5 {
+		send(t, conn, map[string]any{"type": "update", "x": x, "y": 0, "color": "#000000", "reqId": strconv.Itoa(x)})
+	}
+	for range 4 {
+		var nack ErrorMessage
+		readType(t, conn, "nack", &nack)
+		if nack.Code != "busy" || nack.ReqID == "0" {
+			t.Fatalf("got %+v, want busy for a placement after the first", nack)
+		}
+	}
+	unblock()
+	var ack AckMessage
+	if readType(t, conn, "ack", &ack); ack.ReqID != "0" {
+		t.Fatalf("got %+v, want the buffered placement acknowledged", ack)
+	}
+	if got := scrape(t, srv, "rplace_updates_dropped_total") - dropped; got != 4 {
+		t.Fatalf("counted %v dropped updates, want 4", got)
+	}
+}
+
+func TestBroadcastOverflowBlock(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.BroadcastBuffer = 2
+		cfg.BroadcastOverflow = "block"
+	})
+	h := newTestHub(8, 8)
+	for x := range 2 {
+		if err := h.enqueue(context.Background(), Update{X: x}); err != nil {
+			t.Fatalf("update %d: %v", x, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.enqueue(ctx, Update{X: 2}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("enqueueing onto a full buffer: got %v, want it to block until the deadline", err)
+	}
+
+	// It goes through once the Run loop takes one.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-h.broadcast
+	}()
+	if err := h.enqueue(context.Background(), Update{X: 3}); err != nil {
+		t.Fatalf("enqueueing once there is room: %v", err)
+	}
+}
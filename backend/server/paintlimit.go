@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errRegionRateLimited = errors.New("too many pixels placed in this region")
+
+// paintKey identifies one user's placements within one region of the board.
+type paintKey struct {
+	id     uuid.UUID
+	region cell
+}
+
+// paintRegion returns the region of the paint limit grid holding (x, y).
+func paintRegion(x, y int) cell {
+	return cell{X: x / config.PaintLimitRegion, Y: y / config.PaintLimitRegion}
+}
+
+// paintCount returns how many pixels key placed within the window ending at
+// now, forgetting older ones.
+func (h *Hub) paintCount(key paintKey, now time.Time) int {
+	times := h.paints[key]
+	cutoff := now.Add(-config.PaintLimitWindow)
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	if i == len(times) {
+		delete(h.paints, key)
+		return 0
+	}
+	h.paints[key] = times[i:]
+	return len(times) - i
+}
+
+// checkPaintLimit reports errRegionRateLimited if painting cells at now
+// would take id over config.PaintLimit in any region. It is only called
+// from the hub's Run loop.
+func (h *Hub) checkPaintLimit(id uuid.UUID, cells []cell, now time.Time) error {
+	if config.PaintLimit <= 0 {
+		return nil
+	}
+	wanted := make(map[cell]int)
+	for _, c := range cells {
+		wanted[paintRegion(c.X, c.Y)]++
+	}
+	for region, n := range wanted {
+		if h.paintCount(paintKey{id: id, region: region}, now)+n > config.PaintLimit {
+			return errRegionRateLimited
+		}
+	}
+	return nil
+}
+
+// recordPaint counts cells painted by id at now against the paint limit.
+func (h *Hub) recordPaint(id uuid.UUID, cells []cell, now time.Time) {
+	if config.PaintLimit <= 0 {
+		return
+	}
+	for _, c := range cells {
+		key := paintKey{id: id, region: paintRegion(c.X, c.Y)}
+		h.paints[key] = append(h.paints[key], now)
+	}
+}
+
+// prunePaints forgets placements that have left the paint limit window.
+func (h *Hub) prunePaints(now time.Time) {
+	for key := range h.paints {
+		h.paintCount(key, now)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPaintLimit(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.PaintLimit = 3
+		cfg.PaintLimitRegion = 4
+		cfg.PaintLimitWindow = time.Minute
+	})
+	h := newTestHub(16, 16)
+	clock := useFakeClock(h)
+	griefer, other := uuid.New(), uuid.New()
+
+	for x := range 3 {
+		if err := applyAs(h, griefer, x, 0); err != nil {
+			t.Fatalf("placement %d: %v", x, err)
+		}
+		clock.advance(time.Second)
+	}
+	if err := applyAs(h, griefer, 3, 3); !errors.Is(err, errRegionRateLimited) {
+		t.Fatalf("fourth placement in the region: got %v, want %v", err, errRegionRateLimited)
+	}
+	if code := errorCode(errRegionRateLimited); code != "region_rate_limited" {
+		t.Fatalf("limit has code %q", code)
+	}
+
+	// Other regions, and other users in the same region, are unaffected.
+	if err := applyAs(h, griefer, 4, 0); err != nil {
+		t.Fatalf("placing in the next region: %v", err)
+	}
+	if err := applyAs(h, other, 3, 3); err != nil {
+		t.Fatalf("another user placing in the region: %v", err)
+	}
+
+	// The window slides: once the first placement has left it, one more fits.
+	clock.advance(time.Minute - 3*time.Second)
+	if err := applyAs(h, griefer, 3, 3); err != nil {
+		t.Fatalf("placing after the first left the window: %v", err)
+	}
+	if err := applyAs(h, griefer, 3, 2); !errors.Is(err, errRegionRateLimited) {
+		t.Fatalf("placing again: got %v, want %v", err, errRegionRateLimited)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	errOutOfBounds  = errors.New("pixel out of bounds")
+	errNotInPalette = errors.New("color is not in the palette")
+	errStoreFailed  = errors.New("failed to store pixel")
+	errRegionLocked = errors.New("region is locked")
+)
+
+type cooldownError struct {
+	remaining time.Duration
+}
+
+func (e *cooldownError) Error() string {
+	return fmt.Sprintf("on cooldown for %s", e.remaining)
+}
+
+// placeResult is sent back to callers that need to know the outcome of an
+// update, such as the REST endpoint.
+type placeResult struct {
+	Update Update
+	Err    error
+}
+
+// inPalette reports whether p may be placed. An empty palette allows any color.
+func inPalette(p Pixel) bool {
+	if len(config.Palette) == 0 {
+		return true
+	}
+	for _, allowed := range config.Palette {
+		if p == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCode maps a rejection to the code sent to clients.
+func errorCode(err error) string {
+	var cooldown *cooldownError
+	switch {
+	case errors.As(err, &cooldown):
+		return "cooldown"
+	case errors.Is(err, errOutOfBounds):
+		return "out_of_bounds"
+	case errors.Is(err, errNotInPalette):
+		return "not_in_palette"
+	case errors.Is(err, errInvalidColor), errors.Is(err, errColorRequired):
+		return "invalid_color"
+	case errors.Is(err, errRegionLocked):
+		return "region_locked"
+	case errors.Is(err, errRegionRateLimited):
+		return "region_rate_limited"
+	case errors.Is(err, errFrozen):
+		return "frozen"
+	case errors.Is(err, errBanned):
+		return "banned"
+	case errors.Is(err, errCanvasClosed):
+		return "canvas_closed"
+	case errors.Is(err, errConsensusOnly):
+		return "consensus_only"
+	case errors.Is(err, errMissingField):
+		return "missing_field"
+	case errors.Is(err, errUnknownField):
+		return "unknown_field"
+	case errors.Is(err, errTooLarge):
+		return "too_large"
+	case errors.Is(err, errBadShape):
+		return "bad_shape"
+	case errors.Is(err, errTooManyConns):
+		return "too_many_connections"
+	case errors.Is(err, errServerBusy):
+		return "busy"
+	case errors.Is(err, errStoreFailed):
+		return "store_failed"
+	case errors.Is(err, errNothingToUndo):
+		return "nothing_to_undo"
+	case errors.Is(err, errUndoOverwritten):
+		return "undo_overwritten"
+	case errors.Is(err, errReadOnly):
+		return "read_only"
+	case errors.Is(err, errBadViewport):
+		return "bad_viewport"
+	case errors.Is(err, errUsernameTaken):
+		return "username_taken"
+	case errors.Is(err, errNameLocked):
+		return "name_locked"
+	case errors.Is(err, errServerFull):
+		return "server_full"
+	}
+	return "internal"
+}
+
+// newErrorMessage describes err to a client.
+func newErrorMessage(err error) ErrorMessage {
+	message := ErrorMessage{
+		Type:    "error",
+		Code:    errorCode(err),
+		Message: err.Error(),
+	}
+	var cooldown *cooldownError
+	if errors.As(err, &cooldown) {
+		message.RemainingMs = cooldown.remaining.Milliseconds()
+	}
+	return message
+}
+
+// reject tells the sender of message why it was not applied.
+func (h *Hub) reject(message Update, err error) {
+	var cooldown *cooldownError
+	if errors.As(err, &cooldown) {
+		logger.Debug("Client on cooldown", "uuid", message.SenderUUID, "remaining", cooldown.remaining)
+	} else {
+		logger.Warn("Rejected update", "uuid", message.SenderUUID, "x", message.X, "y", message.Y, "error", err)
+	}
+	reply := newErrorMessage(err)
+	if !errors.Is(err, errNothingToUndo) {
+		reply.X, reply.Y = &message.X, &message.Y
+	}
+	h.sendTo(message.SenderUUID, nack(reply, message.ReqID))
+}
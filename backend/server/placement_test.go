@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPlacementOutOfBounds(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+
+	for _, pos := range [][2]int{{-1, 0}, {0, -1}, {16, 0}, {0, 16}, {16, 16}} {
+		t.Run(fmt.Sprint(pos), func(t *testing.T) {
+			reply := request(t, conn, map[string]any{"type": "update", "x": pos[0], "y": pos[1], "color": "#000000"})
+			if reply.Type != "nack" || reply.Code != "out_of_bounds" {
+				t.Fatalf("got %s %q, want nack out_of_bounds", reply.Type, reply.Code)
+			}
+		})
+	}
+
+	// The server is still up and the board untouched.
+	place(t, conn, 15, 15, "#000000")
+	for y, row := range HubInstance.store.Snapshot() {
+		for x, p := range row {
+			if painted := p != config.FillColor; painted != (x == 15 && y == 15) {
+				t.Fatalf("cell (%d, %d) is %v", x, y, p)
+			}
+		}
+	}
+}
+
+func TestRejectionCodes(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Cooldown = time.Minute
+		cfg.Palette = []Pixel{{A: 255}, {R: 255, G: 255, B: 255, A: 255}}
+	})
+	if status := postJSON(t, srv, "/admin/lock", Region{X: 8, Y: 8, Width: 2, Height: 2}, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("locking: %d", status)
+	}
+	placed := dial(t, srv, "username=placed")
+	place(t, placed, 0, 0, "#000000")
+
+	for _, tc := range []struct {
+		name  string
+		conn  *websocket.Conn
+		x, y  int
+		color string
+		code  string
+	}{
+		{"bounds", dial(t, srv, "username=a"), 16, 0, "#000000", "out_of_bounds"},
+		{"palette", dial(t, srv, "username=b"), 1, 1, "#ff4500", "not_in_palette"},
+		{"malformed color", dial(t, srv, "username=c"), 1, 1, "#ggg", "invalid_color"},
+		{"locked", dial(t, srv, "username=d"), 9, 9, "#000000", "region_locked"},
+		{"cooldown", placed, 1, 1, "#000000", "cooldown"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := request(t, tc.conn, map[string]any{"type": "update", "x": tc.x, "y": tc.y, "color": tc.color})
+			if reply.Type != "nack" || reply.Code != tc.code {
+				t.Fatalf("got %s %q, want nack %q", reply.Type, reply.Code, tc.code)
+			}
+			if reply.Message == "" {
+				t.Error("nack has no message")
+			}
+			if reply.X == nil || reply.Y == nil || *reply.X != tc.x || *reply.Y != tc.y {
+				t.Errorf("nack is for (%v, %v), want (%d, %d)", reply.X, reply.Y, tc.x, tc.y)
+			}
+		})
+	}
+
+	t.Run("frozen", func(t *testing.T) {
+		if status := postJSON(t, srv, "/admin/freeze", nil, adminHeader(), nil); status != http.StatusOK {
+			t.Fatalf("freezing: %d", status)
+		}
+		t.Cleanup(func() { setFrozen(false) })
+		reply := request(t, dial(t, srv, "username=e"), map[string]any{"type": "update", "x": 1, "y": 1, "color": "#000000"})
+		if reply.Code != "frozen" {
+			t.Fatalf("got %s %q, want nack frozen", reply.Type, reply.Code)
+		}
+	})
+}
+
+func TestErrorCodesAreDistinct(t *testing.T) {
+	errs := []error{
+		&cooldownError{}, errOutOfBounds, errNotInPalette, errInvalidColor, errRegionLocked,
+		errRegionRateLimited, errFrozen, errBanned, errCanvasClosed, errConsensusOnly,
+		errMissingField, errUnknownField, errTooLarge, errBadShape, errTooManyConns,
+		errServerBusy, errStoreFailed, errNothingToUndo, errUndoOverwritten, errReadOnly,
+		errBadViewport, errUsernameTaken, errNameLocked, errServerFull,
+	}
+	seen := map[string]error{}
+	for _, err := range errs {
+		code := errorCode(fmt.Errorf("wrapped: %w", err))
+		if code == "internal" {
+			t.Errorf("%q has no code", err)
+		}
+		if other, ok := seen[code]; ok {
+			t.Errorf("%q and %q share the code %q", err, other, code)
+		}
+		seen[code] = err
+	}
+	if code := errorCode(errors.New("something else")); code != "internal" {
+		t.Errorf("unknown error got code %q, want internal", code)
+	}
+}
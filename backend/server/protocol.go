@@ -0,0 +1,151 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Websocket subprotocols, newest first. Clients that request none speak v1.
+// v2 sends colors as "#rrggbbaa" strings instead of pixel objects and
+// expects placements to carry a color string.
+const (
+	protocolV1 = "rplace.v1"
+	protocolV2 = "rplace.v2"
+)
+
+var (
+	subprotocols = []string{protocolV2, protocolV1}
+
+	errUnknownProtocol = errors.New("no supported subprotocol requested")
+	errColorRequired   = errors.New("color is required")
+)
+
+// protocolVersion returns the version negotiated for r: 1 if the client
+// requested no subprotocol, or the newest supported one it requested.
+func protocolVersion(r *http.Request) (int, error) {
+	requested := websocket.Subprotocols(r)
+	if len(requested) == 0 {
+		return 1, nil
+	}
+	switch {
+	case slices.Contains(requested, protocolV2):
+		return 2, nil
+	case slices.Contains(requested, protocolV1):
+		return 1, nil
+	}
+	return 0, errUnknownProtocol
+}
+
+type v2Update struct {
+	Type     string    `json:"type"`
+	X        int       `json:"x"`
+	Y        int       `json:"y"`
+	Color    string    `json:"color"`
+	Seq      uint64    `json:"seq"`
+	Ts       time.Time `json:"ts"`
+	Username string    `json:"username,omitempty"`
+}
+
+type v2Updates struct {
+	Type    string     `json:"type"`
+	Seq     uint64     `json:"seq,omitempty"`
+	Updates []v2Update `json:"updates"`
+}
+
+type v2Pixels struct {
+	Type   string     `json:"type"`
+	Seq    uint64     `json:"seq"`
+	Y      *int       `json:"y,omitempty"`
+	Region *Region    `json:"region,omitempty"`
+	Pixels [][]string `json:"pixels"`
+}
+
+type v2Vote struct {
+	Type   string `json:"type"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Color  string `json:"color"`
+	Votes  int    `json:"votes"`
+	Needed int    `json:"needed"`
+	ReqID  string `json:"reqId,omitempty"`
+}
+
+type v2Resize struct {
+	Type   string `json:"type"`
+	Seq    uint64 `json:"seq"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Fill   string `json:"fill"`
+}
+
+func toV2Update(u Update) v2Update {
+	return v2Update{
+		Type:     u.Type,
+		X:        u.X,
+		Y:        u.Y,
+		Color:    hexColor(u.Pixel),
+		Seq:      u.Seq,
+		Ts:       u.Ts,
+		Username: u.SenderName,
+	}
+}
+
+func toV2Updates(updates []Update) []v2Update {
+	out := make([]v2Update, len(updates))
+	for i, u := range updates {
+		out[i] = toV2Update(u)
+	}
+	return out
+}
+
+func toV2Pixels(pixels [][]Pixel) [][]string {
+	out := make([][]string, len(pixels))
+	for y, row := range pixels {
+		out[y] = make([]string, len(row))
+		for x, p := range row {
+			out[y][x] = hexColor(p)
+		}
+	}
+	return out
+}
+
+// encodeV2 converts message to its v2 shape. Messages without pixels are
+// the same in both versions.
+func encodeV2(message interface{}) interface{} {
+	switch m := message.(type) {
+	case Update:
+		return toV2Update(m)
+	case BatchMessage:
+		return v2Updates{Type: m.Type, Updates: toV2Updates(m.Updates)}
+	case DeltaMessage:
+		return v2Updates{Type: m.Type, Seq: m.Seq, Updates: toV2Updates(m.Updates)}
+	case InitBoardState:
+		return v2Pixels{Type: m.Type, Seq: m.Seq, Pixels: toV2Pixels(m.Pixels)}
+	case InitChunkMessage:
+		return v2Pixels{Type: m.Type, Seq: m.Seq, Y: &m.Y, Pixels: toV2Pixels(m.Pixels)}
+	case ResizeMessage:
+		return v2Resize{Type: m.Type, Seq: m.Seq, Width: m.Width, Height: m.Height, Fill: hexColor(m.Fill)}
+	case VoteMessage:
+		return v2Vote{Type: m.Type, X: m.X, Y: m.Y, Color: hexColor(m.Pixel), Votes: m.Votes, Needed: m.Needed, ReqID: m.ReqID}
+	case RegionMessage:
+		return v2Pixels{Type: m.Type, Seq: m.Seq, Region: &m.Region, Pixels: toV2Pixels(m.Pixels)}
+	}
+	return message
+}
+
+// write sends message to the client in the shape of its protocol version.
+func (c *Client) write(message interface{}) error {
+	typ := messageType(message)
+	if c.protocol >= 2 {
+		message = encodeV2(message)
+	}
+	if err := writeMessage(c.Socket, message); err != nil {
+		return err
+	}
+	messagesTotal.WithLabelValues("out", typ).Inc()
+	return nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialProtocol dials srv requesting the given subprotocols and checks which
+// one the server chose.
+func dialProtocol(t *testing.T, srv *httptest.Server, query string, want string, protocols ...string) *websocket.Conn {
+	t.Helper()
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = protocols
+	conn, resp := dialWith(t, srv, &dialer, query)
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != want {
+		t.Fatalf("negotiated %q for %q, want %q", got, protocols, want)
+	}
+	return conn
+}
+
+func TestProtocolV1(t *testing.T) {
+	srv := startServer(t, nil)
+	place(t, dial(t, srv, "username=painter"), 1, 0, "#ff4500")
+
+	for _, protocols := range [][]string{nil, {protocolV1}} {
+		want := ""
+		if protocols != nil {
+			want = protocolV1
+		}
+		conn := dialProtocol(t, srv, "", want, protocols...)
+		var init InitBoardState
+		readType(t, conn, "init", &init)
+		if got := init.Pixels[0][1]; got != (Pixel{R: 0xff, G: 0x45, A: 255}) {
+			t.Fatalf("v1 init has %v at (1, 0)", got)
+		}
+	}
+}
+
+func TestProtocolV2(t *testing.T) {
+	srv := startServer(t, nil)
+	place(t, dial(t, srv, "username=painter"), 1, 0, "#ff4500")
+
+	conn := dialProtocol(t, srv, "username=v2", protocolV2, protocolV1, protocolV2)
+	var init struct {
+		Seq    uint64
+		Pixels [][]string
+	}
+	readType(t, conn, "init", &init)
+	if got := init.Pixels[0][1]; got != "#ff4500" {
+		t.Fatalf("v2 init has %q at (1, 0), want #ff4500", got)
+	}
+
+	place(t, dial(t, srv, "username=other"), 2, 0, "#2450a480")
+	var update struct {
+		X, Y     int
+		Color    string
+		Username string
+		Pixel    json.RawMessage
+	}
+	readType(t, conn, "update", &update)
+	if update.X != 2 || update.Color != "#2450a480" || update.Username != "other" || update.Pixel != nil {
+		t.Fatalf("v2 client got update %+v", update)
+	}
+
+	reply := request(t, conn, map[string]any{"type": "update", "x": 3, "y": 0, "pixel": map[string]int{"r": 1, "a": 255}})
+	if reply.Code != "invalid_color" {
+		t.Fatalf("v2 placement without a color got %s %q, want nack invalid_color", reply.Type, reply.Code)
+	}
+}
+
+func TestProtocolUnknown(t *testing.T) {
+	srv := startServer(t, nil)
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{"rplace.v9"}
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	conn, resp, err := dialer.Dial(url, nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("unknown subprotocol was accepted")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown subprotocol got %v, want status %d", resp, http.StatusBadRequest)
+	}
+}
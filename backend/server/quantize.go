@@ -0,0 +1,85 @@
+package server
+
+import (
+	"image"
+	"image/color"
+)
+
+// Quantize maps every pixel of img onto the nearest palette color by
+// Euclidean distance in RGB, returning them row by row from the top left.
+// With dither, each pixel's rounding error is spread over its unvisited
+// neighbours (Floyd-Steinberg), so areas between palette colors come out as
+// a mix of them instead of bands. Fully transparent pixels are kept as they
+// are, as is every pixel if the palette is empty.
+func Quantize(img image.Image, palette []Pixel, dither bool) []Pixel {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	out := make([]Pixel, 0, width*height)
+	// cur and next hold sixteen times the error carried into this row and
+	// the next, offset by one so neighbours past either edge need no checks.
+	var cur, next [][3]int
+	if dither {
+		cur, next = make([][3]int, width+2), make([][3]int, width+2)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			p := Pixel{R: c.R, G: c.G, B: c.B, A: c.A}
+			if len(palette) == 0 || p.A == 0 {
+				out = append(out, p)
+				continue
+			}
+			if dither {
+				carried := cur[x+1]
+				p.R = clampChannel(int(p.R) + carried[0]/16)
+				p.G = clampChannel(int(p.G) + carried[1]/16)
+				p.B = clampChannel(int(p.B) + carried[2]/16)
+			}
+			q := nearestColor(p, palette)
+			out = append(out, q)
+			if dither {
+				diff := [3]int{int(p.R) - int(q.R), int(p.G) - int(q.G), int(p.B) - int(q.B)}
+				for i, d := range diff {
+					cur[x+2][i] += d * 7
+					next[x][i] += d * 3
+					next[x+1][i] += d * 5
+					next[x+2][i] += d
+				}
+			}
+		}
+		if dither {
+			cur, next = next, cur
+			clear(next)
+		}
+	}
+	return out
+}
+
+// quantizeGrid is Quantize for a board's rows of pixels, which must all be
+// as long as the first.
+func quantizeGrid(pixels [][]Pixel, palette []Pixel, dither bool) [][]Pixel {
+	flat := Quantize(renderImage(pixels, 1), palette, dither)
+	out := make([][]Pixel, len(pixels))
+	for y, row := range pixels {
+		out[y] = flat[y*len(row) : (y+1)*len(row)]
+	}
+	return out
+}
+
+// nearestColor returns the palette color closest to p in RGB. Ties go to
+// the earlier entry.
+func nearestColor(p Pixel, palette []Pixel) Pixel {
+	best, bestDist := palette[0], -1
+	for _, c := range palette {
+		dr, dg, db := int(p.R)-int(c.R), int(p.G)-int(c.G), int(p.B)-int(c.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
+
+func clampChannel(v int) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
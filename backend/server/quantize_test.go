@@ -0,0 +1,105 @@
+package server
+
+import (
+	"image"
+	"image/color"
+	"net/http"
+	"testing"
+)
+
+var (
+	black = Pixel{A: 255}
+	white = Pixel{R: 255, G: 255, B: 255, A: 255}
+	red   = Pixel{R: 255, A: 255}
+	blue  = Pixel{B: 255, A: 255}
+)
+
+func TestQuantizeNearestColor(t *testing.T) {
+	palette := []Pixel{black, white, red, blue}
+	inputs := []color.NRGBA{
+		{R: 250, G: 10, B: 10, A: 255},
+		{R: 20, G: 20, B: 40, A: 255},
+		{R: 200, G: 200, B: 240, A: 255},
+		{R: 30, G: 60, B: 200, A: 255},
+		{R: 255, A: 255},
+		{},
+	}
+	// Transparent pixels are left alone.
+	want := []Pixel{red, black, white, blue, red, {}}
+	img := image.NewNRGBA(image.Rect(0, 0, len(inputs), 1))
+	for x, c := range inputs {
+		img.SetNRGBA(x, 0, c)
+	}
+
+	for _, dither := range []bool{false, true} {
+		// The inputs are far enough from the palette's midpoints that the
+		// error carried along the row does not change their colors.
+		got := Quantize(img, palette, dither)
+		for x := range want {
+			if got[x] != want[x] {
+				t.Errorf("dither=%v: %v mapped to %v, want %v", dither, inputs[x], got[x], want[x])
+			}
+		}
+	}
+
+	if got := Quantize(img, nil, false); got[0] != (Pixel{R: 250, G: 10, B: 10, A: 255}) {
+		t.Errorf("an empty palette changed %v to %v", inputs[0], got[0])
+	}
+	// Images need not start at the origin.
+	sub := img.SubImage(image.Rect(2, 0, 4, 1)).(*image.NRGBA)
+	if got := Quantize(sub, palette, false); len(got) != 2 || got[0] != white || got[1] != blue {
+		t.Errorf("quantizing a subimage gave %v, want white and blue", got)
+	}
+}
+
+func TestQuantizeDither(t *testing.T) {
+	palette := []Pixel{black, white}
+	gray := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			gray.SetNRGBA(x, y, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+		}
+	}
+
+	count := func(pixels []Pixel) (whites int) {
+		for _, p := range pixels {
+			if p == white {
+				whites++
+			}
+		}
+		return whites
+	}
+	// Without dithering every pixel rounds the same way.
+	if whites := count(Quantize(gray, palette, false)); whites != 256 {
+		t.Fatalf("%d of 256 mid-gray pixels came out white without dithering, want all", whites)
+	}
+	// With it, the error evens out to about half and half.
+	if whites := count(Quantize(gray, palette, true)); whites < 112 || whites > 144 {
+		t.Fatalf("%d of 256 mid-gray pixels came out white with dithering, want about half", whites)
+	}
+}
+
+func TestImportQuantize(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Palette = []Pixel{black, red} })
+	backup := exportBoard(t, srv.URL)
+	backup.Pixels[0][0] = Pixel{R: 240, G: 20, A: 255}
+	backup.Pixels[0][1] = Pixel{R: 10, G: 10, B: 10, A: 255}
+
+	if status := postJSON(t, srv, "/admin/import", backup, adminHeader(), nil); status != http.StatusBadRequest {
+		t.Fatalf("importing off-palette colors: %d, want 400", status)
+	}
+	if status := postJSON(t, srv, "/admin/import?quantize=true", backup, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("importing with quantize: %d", status)
+	}
+	board := HubInstance.store.Snapshot()
+	if board[0][0] != red || board[0][1] != black {
+		t.Fatalf("imported %v and %v, want red and black", board[0][0], board[0][1])
+	}
+	// Blank cells stay blank.
+	if board[5][5] != config.FillColor {
+		t.Fatalf("blank cell imported as %v", board[5][5])
+	}
+	if status := postJSON(t, srv, "/admin/import?quantize=maybe", backup, adminHeader(), nil); status != http.StatusBadRequest {
+		t.Fatalf("importing with quantize=maybe: %d, want 400", status)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// intent is a placement a client asked for during its cooldown, held until
+// the cooldown ends. Only clients that connected with ?queue=true queue
+// placements; the others are rejected as before.
+type intent struct {
+	update Update
+	timer  *time.Timer
+}
+
+// QueuedMessage tells a client its placement will be applied once its
+// cooldown ends, in RemainingMs. A later placement replaces it. A queued
+// placement with a ReqID is acknowledged when it is applied.
+type QueuedMessage struct {
+	Type        string `json:"type"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	RemainingMs int64  `json:"remainingMs"`
+	ReqID       string `json:"reqId,omitempty"`
+}
+
+// queueIntent holds message if it was rejected only for the sender's
+// cooldown and the sender queues placements. It reports whether it did. It
+// is only called from the hub's Run loop.
+func (h *Hub) queueIntent(message Update, err error) bool {
+	var cooldown *cooldownError
+	if !errors.As(err, &cooldown) {
+		return false
+	}
+	client, ok := h.clients[message.SenderUUID]
+	if !ok || !client.queue {
+		return false
+	}
+	h.dropIntent(message.SenderUUID)
+	queued := &intent{update: message}
+	queued.timer = time.AfterFunc(cooldown.remaining, func() {
+		h.do(func() { h.applyIntent(queued) })
+	})
+	h.intents[message.SenderUUID] = queued
+	logger.Debug("Queued placement", "uuid", message.SenderUUID, "x", message.X, "y", message.Y, "remaining", cooldown.remaining)
+	h.sendTo(message.SenderUUID, QueuedMessage{
+		Type:        "queued",
+		X:           message.X,
+		Y:           message.Y,
+		RemainingMs: cooldown.remaining.Milliseconds(),
+		ReqID:       message.ReqID,
+	})
+	return true
+}
+
+// applyIntent places a queued pixel once its sender's cooldown is over and
+// sends it to every client, the sender included. Intents that were
+// replaced or whose sender left are ignored.
+func (h *Hub) applyIntent(queued *intent) {
+	id := queued.update.SenderUUID
+	if h.intents[id] != queued {
+		return
+	}
+	delete(h.intents, id)
+	if _, ok := h.clients[id]; !ok {
+		return
+	}
+	if frozen.Load() {
+		h.reject(queued.update, errFrozen)
+		return
+	}
+	if !canvasOpen(h.clock()) {
+		h.reject(queued.update, errCanvasClosed)
+		return
+	}
+	applied, err := h.applyUpdate(h.resolveSender(queued.update))
+	if err != nil {
+		var pending *voteError
+		if errors.As(err, &pending) {
+			h.sendVote(queued.update, pending.votes)
+		} else if !h.queueIntent(queued.update, err) {
+			h.reject(queued.update, err)
+		}
+		return
+	}
+	// Sent straight away rather than batched, after anything batched
+	// before it.
+	h.flushBatch()
+	h.broadcastMessage(applied, uuid.Nil)
+	h.ack(queued.update, applied.Seq)
+}
+
+// dropIntent forgets the placement id has queued, if any.
+func (h *Hub) dropIntent(id uuid.UUID) {
+	if queued, ok := h.intents[id]; ok {
+		queued.timer.Stop()
+		delete(h.intents, id)
+	}
+}
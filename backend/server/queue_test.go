@@ -0,0 +1,55 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueuedPlacementAppliesLatestIntent(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Cooldown = 200 * time.Millisecond })
+	conn := dial(t, srv, "username=a&queue=true")
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+	place(t, conn, 0, 0, "#000000")
+
+	send(t, conn, map[string]any{"type": "update", "x": 1, "y": 1, "color": "#ff0000", "reqId": "first"})
+	var queued QueuedMessage
+	readType(t, conn, "queued", &queued)
+	if queued.X != 1 || queued.Y != 1 || queued.ReqID != "first" || queued.RemainingMs <= 0 {
+		t.Fatalf("got %+v", queued)
+	}
+
+	// A second placement during the cooldown replaces the first, and is
+	// acknowledged once the cooldown is over.
+	started := time.Now()
+	reply := request(t, conn, map[string]any{"type": "update", "x": 2, "y": 2, "color": "#0000ff"})
+	if reply.Type != "ack" {
+		t.Fatalf("queued placement got %s %q", reply.Type, reply.Code)
+	}
+	if waited := time.Since(started); waited < 100*time.Millisecond {
+		t.Fatalf("queued placement was applied after %v, before the cooldown ended", waited)
+	}
+
+	var update Update
+	for update.X != 2 {
+		readType(t, watcher, "update", &update)
+		if update.X == 1 {
+			t.Fatal("the replaced placement was applied")
+		}
+	}
+	if update.Pixel != (Pixel{B: 255, A: 255}) {
+		t.Fatalf("watcher got %+v", update)
+	}
+	if p, _ := HubInstance.store.Get(1, 1); p != config.FillColor {
+		t.Fatalf("the replaced placement painted (1, 1) %v", p)
+	}
+}
+
+func TestPlacementsNotQueuedByDefault(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Cooldown = time.Minute })
+	conn := dial(t, srv, "username=a")
+	place(t, conn, 0, 0, "#000000")
+	if reply := request(t, conn, map[string]any{"type": "update", "x": 1, "y": 1, "color": "#000000"}); reply.Code != "cooldown" {
+		t.Fatalf("got %s %q, want nack cooldown", reply.Type, reply.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import "time"
+
+// maxThrottledMessages is how many messages in a row a client may send over
+// its rate before it is disconnected instead of just ignored.
+const maxThrottledMessages = 50
+
+// messageLimiter caps how many websocket messages a single connection may
+// send per second, other than cursor moves. It allows bursts of up to
+// config.MessageBurst messages. It is only used by the connection's Read
+// loop.
+type messageLimiter struct {
+	tokens    float64
+	updated   time.Time
+	throttled int
+}
+
+func newMessageLimiter(now time.Time) *messageLimiter {
+	return &messageLimiter{tokens: float64(config.MessageBurst), updated: now}
+}
+
+// allow reports whether a message received at now is within the rate. A
+// zero config.MessageRate disables the limit.
+func (l *messageLimiter) allow(now time.Time) bool {
+	if config.MessageRate <= 0 {
+		return true
+	}
+	l.tokens += now.Sub(l.updated).Seconds() * float64(config.MessageRate)
+	l.tokens = min(l.tokens, float64(config.MessageBurst))
+	l.updated = now
+	if l.tokens < 1 {
+		l.throttled++
+		return false
+	}
+	l.tokens--
+	l.throttled = 0
+	return true
+}
+
+// abusive reports whether the client kept flooding after being throttled.
+func (l *messageLimiter) abusive() bool {
+	return l.throttled >= maxThrottledMessages
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+func TestMessageLimiter(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.MessageRate, cfg.MessageBurst = 10, 3 })
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	l := newMessageLimiter(now)
+
+	for i := range 3 {
+		if !l.allow(now) {
+			t.Fatalf("message %d of the burst was throttled", i+1)
+		}
+	}
+	if l.allow(now) {
+		t.Fatal("message over the burst was allowed")
+	}
+	if !l.allow(now.Add(100 * time.Millisecond)) {
+		t.Fatal("message after a refill was throttled")
+	}
+
+	for range maxThrottledMessages - 1 {
+		l.allow(now.Add(100 * time.Millisecond))
+	}
+	if l.abusive() {
+		t.Fatalf("abusive after %d throttled messages", maxThrottledMessages-1)
+	}
+	l.allow(now.Add(100 * time.Millisecond))
+	if !l.abusive() {
+		t.Fatalf("not abusive after %d throttled messages", maxThrottledMessages)
+	}
+}
+
+func TestMessageLimiterDisabled(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.MessageRate = 0 })
+	now := time.Now()
+	l := newMessageLimiter(now)
+	for i := range 1000 {
+		if !l.allow(now) {
+			t.Fatalf("message %d was throttled with the limit disabled", i+1)
+		}
+	}
+}
+
+func TestFloodingClientIsDisconnected(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.MessageRate, cfg.MessageBurst = 1, 2 })
+	conn := dial(t, srv, "username=flood")
+	place(t, conn, 0, 0, "#000000")
+	place(t, conn, 1, 0, "#000000")
+
+	for range maxThrottledMessages + 10 {
+		message := map[string]any{"type": "update", "x": 2, "y": 0, "color": "#000000", "reqId": uuid.NewString()}
+		if err := conn.WriteJSON(message); err != nil {
+			break
+		}
+	}
+	if code := readClose(t, conn); code != websocket.ClosePolicyViolation {
+		t.Fatalf("got close code %d, want %d", code, websocket.ClosePolicyViolation)
+	}
+}
+
+func TestEnvNonNegativeInt(t *testing.T) {
+	const key = "RPLACE_TEST_LIMIT"
+	for _, tc := range []struct {
+		value string
+		want  int
+	}{
+		{"", 7},
+		{"0", 0},
+		{"12", 12},
+		{"-1", 7},
+		{"many", 7},
+	} {
+		t.Setenv(key, tc.value)
+		if got := envNonNegativeInt(key, 7); got != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps the board in a Redis hash and shares updates with other
+// instances over pub/sub. Reads are served from a local Board kept in sync
+// with Redis.
+//
+// While Redis is unreachable, writes go to the local board and are buffered,
+// the latest pixel per cell, up to config.StoreBufferSize cells. Every
+// config.StoreRetryInterval the buffer is written back and published; until
+// it has drained, later writes are buffered too so they land in order.
+type RedisStore struct {
+	client   *redis.Client
+	cache    *Board
+	key      string
+	channel  string
+	instance uuid.UUID
+	updates  chan Update
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	pending map[string]remoteUpdate
+}
+
+func NewRedisStore(addr, prefix string, cache *Board) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &RedisStore{
+		client:   client,
+		cache:    cache,
+		key:      prefix + ":board",
+		channel:  prefix + ":updates",
+		instance: uuid.New(),
+		updates:  make(chan Update, 256),
+		cancel:   cancel,
+		pending:  make(map[string]remoteUpdate),
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("connecting to redis at %s: %w", addr, err)
+	}
+	if err := s.load(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	pubsub := client.Subscribe(ctx, s.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("subscribing to %s: %w", s.channel, err)
+	}
+	go s.subscribe(ctx, pubsub)
+	go s.retry(ctx, config.StoreRetryInterval)
+	return s, nil
+}
+
+func (s *RedisStore) Size() (int, int) {
+	return s.cache.Size()
+}
+
+func (s *RedisStore) InBounds(x, y int) bool {
+	return s.cache.InBounds(x, y)
+}
+
+func (s *RedisStore) Get(x, y int) (Pixel, PixelMeta) {
+	return s.cache.Get(x, y)
+}
+
+func (s *RedisStore) Set(x, y int, p Pixel, meta PixelMeta) error {
+	cell := remoteUpdate{Instance: s.instance, X: x, Y: y, Pixel: p, Meta: meta}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		err := s.write(context.Background(), cell)
+		if err == nil {
+			return s.cache.Set(x, y, p, meta)
+		}
+		logger.Warn("Redis is unreachable, buffering writes", "error", err)
+	}
+	if err := s.buffer(cell); err != nil {
+		return err
+	}
+	return s.cache.Set(x, y, p, meta)
+}
+
+// write stores one cell in the Redis hash.
+func (s *RedisStore) write(ctx context.Context, cell remoteUpdate) error {
+	data, err := json.Marshal(remoteUpdate{X: cell.X, Y: cell.Y, Pixel: cell.Pixel, Meta: cell.Meta})
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, cellField(cell.X, cell.Y), data).Err()
+}
+
+// buffer holds cell until Redis is back. The caller must hold s.mu.
+func (s *RedisStore) buffer(cell remoteUpdate) error {
+	field := cellField(cell.X, cell.Y)
+	if _, ok := s.pending[field]; !ok && len(s.pending) >= config.StoreBufferSize {
+		return fmt.Errorf("redis is unreachable and %d cells are already buffered", len(s.pending))
+	}
+	if _, ok := s.pending[field]; !ok {
+		storeBuffered.Inc()
+	}
+	s.pending[field] = cell
+	return nil
+}
+
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore) Snapshot() [][]Pixel {
+	return s.cache.Snapshot()
+}
+
+func (s *RedisStore) Heat(since time.Time, tint Pixel) [][]Pixel {
+	return s.cache.Heat(since, tint)
+}
+
+// Reset clears the board in Redis and tells the other instances, which
+// clear their caches and send their clients the empty board.
+func (s *RedisStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.client.Del(context.Background(), s.key).Err(); err != nil {
+		return err
+	}
+	storeBuffered.Sub(float64(len(s.pending)))
+	clear(s.pending)
+	if err := s.cache.Reset(); err != nil {
+		return err
+	}
+	return s.publish(context.Background(), remoteUpdate{Type: "reset", Instance: s.instance})
+}
+
+// Publish shares a pixel with other instances. Pixels still buffered are
+// published once they have been written back instead.
+func (s *RedisStore) Publish(x, y int, p Pixel, meta PixelMeta) error {
+	s.mu.Lock()
+	_, buffered := s.pending[cellField(x, y)]
+	s.mu.Unlock()
+	if buffered {
+		return nil
+	}
+	return s.publish(context.Background(), remoteUpdate{Instance: s.instance, X: x, Y: y, Pixel: p, Meta: meta})
+}
+
+func (s *RedisStore) publish(ctx context.Context, cell remoteUpdate) error {
+	data, err := json.Marshal(cell)
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, s.channel, data).Err()
+}
+
+// Buffered returns the number of cells waiting for Redis to come back.
+func (s *RedisStore) Buffered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// retry writes buffered cells back every interval until ctx is done.
+func (s *RedisStore) retry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.flush(ctx); err != nil {
+				logger.Warn("Redis is still unreachable", "buffered", s.Buffered(), "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flush writes the buffered cells to Redis in one pipeline and publishes
+// them. The lock is not held while Redis answers, so placements are not
+// held up; cells placed again meanwhile stay buffered for the next flush.
+func (s *RedisStore) flush(ctx context.Context) error {
+	s.mu.Lock()
+	cells := maps.Clone(s.pending)
+	s.mu.Unlock()
+	if len(cells) == 0 {
+		return nil
+	}
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for field, cell := range cells {
+			data, err := json.Marshal(remoteUpdate{X: cell.X, Y: cell.Y, Pixel: cell.Pixel, Meta: cell.Meta})
+			if err != nil {
+				return err
+			}
+			pipe.HSet(ctx, s.key, field, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, cell := range cells {
+		if err := s.publish(ctx, cell); err != nil {
+			logger.Error("Failed to publish buffered pixel", "x", cell.X, "y", cell.Y, "error", err)
+		}
+	}
+
+	s.mu.Lock()
+	for field, cell := range cells {
+		if s.pending[field] == cell {
+			delete(s.pending, field)
+			storeBuffered.Dec()
+		}
+	}
+	left := len(s.pending)
+	s.mu.Unlock()
+	logger.Info("Wrote buffered cells to redis", "cells", len(cells), "buffered", left)
+	return nil
+}
+
+func (s *RedisStore) Updates() <-chan Update {
+	return s.updates
+}
+
+func (s *RedisStore) Close() error {
+	s.cancel()
+	return s.client.Close()
+}
+
+// load fills the local cache from the Redis hash.
+func (s *RedisStore) load(ctx context.Context) error {
+	cells, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return fmt.Errorf("loading board from redis: %w", err)
+	}
+	for field, value := range cells {
+		var cell remoteUpdate
+		if err := json.Unmarshal([]byte(value), &cell); err != nil {
+			logger.Warn("Skipping malformed redis cell", "field", field, "error", err)
+			continue
+		}
+		if !s.cache.InBounds(cell.X, cell.Y) {
+			logger.Warn("Skipping out of bounds redis cell", "field", field)
+			continue
+		}
+		s.cache.Set(cell.X, cell.Y, cell.Pixel, cell.Meta)
+	}
+	logger.Info("Loaded board from redis", "cells", len(cells))
+	return nil
+}
+
+// subscribe applies updates published by other instances to the cache and
+// hands them to the hub. A reset is handed on as an Update of type "reset".
+func (s *RedisStore) subscribe(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+	defer close(s.updates)
+	for msg := range pubsub.Channel() {
+		var remote remoteUpdate
+		if err := json.Unmarshal([]byte(msg.Payload), &remote); err != nil {
+			logger.Warn("Skipping malformed redis update", "error", err)
+			continue
+		}
+		if remote.Instance == s.instance {
+			continue
+		}
+		update := Update{Type: "reset"}
+		if remote.Type == "reset" {
+			if err := s.cache.Reset(); err != nil {
+				logger.Error("Failed to reset board cache", "error", err)
+				continue
+			}
+		} else {
+			if !s.cache.InBounds(remote.X, remote.Y) {
+				continue
+			}
+			s.cache.Set(remote.X, remote.Y, remote.Pixel, remote.Meta)
+			update = Update{
+				Type:       "update",
+				Pixel:      remote.Pixel,
+				X:          remote.X,
+				Y:          remote.Y,
+				SenderUUID: remote.Meta.UUID,
+				SenderName: remote.Meta.Username,
+				Ts:         remote.Meta.PlacedAt,
+			}
+		}
+		select {
+		case s.updates <- update:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func cellField(x, y int) string {
+	return strconv.Itoa(x) + "," + strconv.Itoa(y)
+}
@@ -0,0 +1,322 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis speaks just enough of the Redis protocol for RedisStore:
+// hashes, DEL and pub/sub. Clients fall back to RESP2 since HELLO is
+// refused.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu          sync.Mutex
+	hashes      map[string]map[string]string
+	subscribers map[string][]*fakeRedisConn
+	// down fails every command other than on subscribed connections, as
+	// an outage would.
+	down bool
+}
+
+type fakeRedisConn struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+	// subscribed is set once the connection has subscribed to a channel.
+	// It is only accessed under fakeRedis.mu.
+	subscribed bool
+}
+
+// startRedis serves a fakeRedis on a local port until the test ends.
+func startRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{
+		ln:          ln,
+		hashes:      make(map[string]map[string]string),
+		subscribers: make(map[string][]*fakeRedisConn),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) addr() string {
+	return r.ln.Addr().String()
+}
+
+func (r *fakeRedis) setDown(down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = down
+}
+
+// cells returns how many cells the hash at key holds.
+func (r *fakeRedis) cells(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.hashes[key])
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	c := &fakeRedisConn{w: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		r.handle(c, args)
+	}
+}
+
+func (r *fakeRedis) handle(c *fakeRedisConn, args []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down && !c.subscribed {
+		c.reply("-ERR fake outage\r\n")
+		return
+	}
+	switch strings.ToUpper(args[0]) {
+	case "HELLO":
+		c.reply("-ERR unknown command 'HELLO'\r\n")
+	case "CLIENT":
+		c.reply("+OK\r\n")
+	case "PING":
+		if c.subscribed {
+			c.reply(bulkArray("pong", ""))
+		} else {
+			c.reply("+PONG\r\n")
+		}
+	case "HSET":
+		hash := r.hashes[args[1]]
+		if hash == nil {
+			hash = make(map[string]string)
+			r.hashes[args[1]] = hash
+		}
+		hash[args[2]] = args[3]
+		c.reply(":1\r\n")
+	case "HGETALL":
+		var fields []string
+		for k, v := range r.hashes[args[1]] {
+			fields = append(fields, k, v)
+		}
+		c.reply(bulkArray(fields...))
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := r.hashes[key]; ok {
+				delete(r.hashes, key)
+				n++
+			}
+		}
+		c.reply(":" + strconv.Itoa(n) + "\r\n")
+	case "SUBSCRIBE":
+		for i, channel := range args[1:] {
+			r.subscribers[channel] = append(r.subscribers[channel], c)
+			c.subscribed = true
+			c.reply("*3\r\n" + bulk("subscribe") + bulk(channel) + ":" + strconv.Itoa(i+1) + "\r\n")
+		}
+	case "PUBLISH":
+		subs := r.subscribers[args[1]]
+		for _, sub := range subs {
+			sub.reply(bulkArray("message", args[1], args[2]))
+		}
+		c.reply(":" + strconv.Itoa(len(subs)) + "\r\n")
+	default:
+		c.reply("-ERR unknown command '" + args[0] + "'\r\n")
+	}
+}
+
+func (c *fakeRedisConn) reply(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.WriteString(s)
+	c.w.Flush()
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func bulkArray(items ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(items))
+	for _, item := range items {
+		b.WriteString(bulk(item))
+	}
+	return b.String()
+}
+
+// readCommand reads one command sent as an array of bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// startPeer starts a second hub sharing redis with the server's, as
+// another instance would, and returns it with a client listening on it.
+func startPeer(t *testing.T, redis *fakeRedis) (*Hub, *Client) {
+	t.Helper()
+	store, err := NewRedisStore(redis.addr(), config.RedisPrefix, NewBoard(config.BoardWidth, config.BoardHeight))
+	if err != nil {
+		t.Fatalf("connecting peer: %v", err)
+	}
+	peer := newHub(defaultRoom, store.cache, store)
+	go peer.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+		defer cancel()
+		peer.Shutdown(ctx)
+	})
+	return peer, addTestClient(t, peer, "peer", 16)
+}
+
+// receive waits for a message of type T on client's Send channel,
+// skipping others.
+func receive[T any](t *testing.T, client *Client) T {
+	t.Helper()
+	timeout := time.After(readTimeout)
+	for {
+		select {
+		case message := <-client.Send:
+			if m, ok := message.(T); ok {
+				return m
+			}
+		case <-timeout:
+			var zero T
+			t.Fatalf("no %T arrived", zero)
+		}
+	}
+}
+
+func TestRedisSharesUpdatesBetweenInstances(t *testing.T) {
+	redis := startRedis(t)
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Store = "redis"
+		cfg.RedisAddr = redis.addr()
+	})
+	peer, listener := startPeer(t, redis)
+
+	place(t, dial(t, srv, "username=a"), 4, 5, "#6a5cff")
+
+	update := receive[Update](t, listener)
+	want := Pixel{R: 0x6a, G: 0x5c, B: 0xff, A: 255}
+	if update.X != 4 || update.Y != 5 || update.Pixel != want || update.SenderName != "a" {
+		t.Fatalf("peer client got %+v", update)
+	}
+	if p, meta := peer.store.Get(4, 5); p != want || meta.Username != "a" {
+		t.Fatalf("peer store has %v by %q at (4, 5)", p, meta.Username)
+	}
+}
+
+func TestRedisOutageBuffersWrites(t *testing.T) {
+	redis := startRedis(t)
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Store = "redis"
+		cfg.RedisAddr = redis.addr()
+		cfg.StoreBufferSize = 2
+		cfg.StoreRetryInterval = 20 * time.Millisecond
+	})
+	_, listener := startPeer(t, redis)
+	conn := dial(t, srv, "username=a")
+
+	redis.setDown(true)
+	place(t, conn, 1, 1, "#ff0000")
+	place(t, conn, 2, 2, "#00ff00")
+	// Reads are served from the local board meanwhile.
+	if p, _ := HubInstance.store.Get(1, 1); p != (Pixel{R: 255, A: 255}) {
+		t.Fatalf("(1, 1) reads %v during the outage", p)
+	}
+	if reply := request(t, conn, map[string]any{"type": "update", "x": 3, "y": 3, "color": "#0000ff"}); reply.Code != "store_failed" {
+		t.Fatalf("placement beyond the buffer got %s %q, want nack store_failed", reply.Type, reply.Code)
+	}
+	if status := getJSON(t, srv, "/readyz", nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz with a full buffer got %d", status)
+	}
+	if redis.cells(config.RedisPrefix+":board") != 0 {
+		t.Fatal("cells reached redis during the outage")
+	}
+
+	redis.setDown(false)
+	for range 2 {
+		if update := receive[Update](t, listener); update.X != update.Y || (update.X != 1 && update.X != 2) {
+			t.Fatalf("peer got %+v after recovery", update)
+		}
+	}
+	if n := redis.cells(config.RedisPrefix + ":board"); n != 2 {
+		t.Fatalf("redis holds %d cells after recovery, want 2", n)
+	}
+	var ready struct {
+		Status string `json:"status"`
+	}
+	if getJSON(t, srv, "/readyz", &ready); ready.Status != "ok" {
+		t.Fatalf("/readyz after recovery is %q", ready.Status)
+	}
+}
+
+func TestReadyzDegradedDuringOutage(t *testing.T) {
+	redis := startRedis(t)
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Store = "redis"
+		cfg.RedisAddr = redis.addr()
+		cfg.StoreRetryInterval = time.Hour
+	})
+	redis.setDown(true)
+	place(t, dial(t, srv, "username=a"), 1, 1, "#ff0000")
+
+	var ready struct {
+		Status   string         `json:"status"`
+		Buffered map[string]int `json:"buffered"`
+	}
+	if status := getJSON(t, srv, "/readyz", &ready); status != http.StatusOK || ready.Status != "degraded" || ready.Buffered[defaultRoom] != 1 {
+		t.Fatalf("/readyz got %d %+v, want degraded with 1 buffered", status, ready)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Region is a rectangle of cells starting at (X, Y).
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width" binding:"min=1"`
+	Height int `json:"height" binding:"min=1"`
+}
+
+func (r Region) Contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+// locked reports whether (x, y) falls inside a locked region. It is only
+// called from the hub's Run loop.
+func (h *Hub) locked(x, y int) bool {
+	for _, region := range h.locks {
+		if region.Contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func LockRegion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var region Region
+		if err := c.ShouldBindJSON(&region); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+
+		var locks []Region
+		if !hub.do(func() {
+			hub.locks = append(hub.locks, region)
+			locks = slices.Clone(hub.locks)
+		}) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		logger.Info("Region locked", "room", hub.name, "region", region, "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"locks": locks})
+	}
+}
+
+// UnlockRegion removes locks matching the given coordinates exactly.
+func UnlockRegion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var region Region
+		if err := c.ShouldBindJSON(&region); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+
+		var locks []Region
+		found := false
+		if !hub.do(func() {
+			n := len(hub.locks)
+			hub.locks = slices.DeleteFunc(hub.locks, func(r Region) bool {
+				return r == region
+			})
+			found = len(hub.locks) < n
+			locks = slices.Clone(hub.locks)
+		}) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no such locked region"})
+			return
+		}
+		logger.Info("Region unlocked", "room", hub.name, "region", region, "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"locks": locks})
+	}
+}
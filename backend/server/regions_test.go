@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLockedRegion(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+
+	lock := Region{X: 2, Y: 2, Width: 3, Height: 3}
+	if status := postJSON(t, srv, "/admin/lock", lock, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("locking: %d", status)
+	}
+
+	for _, pos := range [][2]int{{2, 2}, {4, 4}, {3, 2}} {
+		reply := request(t, conn, map[string]any{"type": "update", "x": pos[0], "y": pos[1], "color": "#000000"})
+		if reply.Code != "region_locked" {
+			t.Fatalf("placing inside the lock at %v: got %s %q, want region_locked", pos, reply.Type, reply.Code)
+		}
+	}
+	for _, pos := range [][2]int{{1, 2}, {5, 4}, {3, 5}} {
+		place(t, conn, pos[0], pos[1], "#000000")
+	}
+
+	if status := postJSON(t, srv, "/admin/unlock", lock, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("unlocking: %d", status)
+	}
+	place(t, conn, 3, 3, "#000000")
+}
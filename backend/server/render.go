@@ -0,0 +1,50 @@
+package server
+
+import (
+	"image"
+	"image/color"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImageScale = 32
+	// maxImagePixels caps the pixels of an image rendered from the board,
+	// which is allocated whole. Scale 1 is always allowed, since the image
+	// is then no larger than the board.
+	maxImagePixels = 1 << 24
+)
+
+// checkImageSize responds with 400 and reports false if a width by height
+// board rendered at scale would exceed maxImagePixels.
+func checkImageSize(c *gin.Context, width, height, scale int) bool {
+	if scale == 1 || scale*scale <= maxImagePixels/max(width*height, 1) {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "image would exceed " + strconv.Itoa(maxImagePixels) + " pixels, use a smaller scale"})
+	return false
+}
+
+// renderImage draws pixels into an image, scaling each cell to a
+// scale x scale block. Transparent pixels stay transparent.
+func renderImage(pixels [][]Pixel, scale int) *image.NRGBA {
+	height := len(pixels)
+	width := 0
+	if height > 0 {
+		width = len(pixels[0])
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width*scale, height*scale))
+	for y, row := range pixels {
+		for x, p := range row {
+			c := color.NRGBA{R: p.R, G: p.G, B: p.B, A: p.A}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetNRGBA(x*scale+dx, y*scale+dy, c)
+				}
+			}
+		}
+	}
+	return img
+}
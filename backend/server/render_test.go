@@ -0,0 +1,88 @@
+package server
+
+import (
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetBoardPNG(t *testing.T) {
+	srv := startServer(t, nil)
+	place(t, dial(t, srv, "username=a"), 5, 6, "#00a368")
+
+	resp, err := http.Get(srv.URL + "/board.png?scale=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /board.png: %d", resp.StatusCode)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	if got := img.Bounds().Size(); got.X != 32 || got.Y != 32 {
+		t.Fatalf("image is %v, want 32x32", got)
+	}
+	want := color.NRGBA{G: 0xa3, B: 0x68, A: 255}
+	for _, pos := range [][2]int{{10, 12}, {11, 13}} {
+		if got := color.NRGBAModel.Convert(img.At(pos[0], pos[1])); got != want {
+			t.Fatalf("pixel at %v is %v, want %v", pos, got, want)
+		}
+	}
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Fatalf("unpainted pixel is %v, want white", got)
+	}
+}
+
+func TestCheckImageSize(t *testing.T) {
+	for _, tc := range []struct {
+		width, height, scale int
+		ok                   bool
+	}{
+		{1000, 1000, 1, true},
+		{1000, 1000, 4, true},
+		{4096, 4096, 1, true},
+		{4096, 4096, 2, false},
+		{2000, 2000, 32, false},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if ok := checkImageSize(c, tc.width, tc.height, tc.scale); ok != tc.ok {
+			t.Errorf("%dx%d at scale %d: got %v, want %v", tc.width, tc.height, tc.scale, ok, tc.ok)
+		}
+		if !tc.ok && w.Code != http.StatusBadRequest {
+			t.Errorf("%dx%d at scale %d: status %d, want 400", tc.width, tc.height, tc.scale, w.Code)
+		}
+	}
+}
+
+func TestTranslucentPixelRoundTrip(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.AlphaMode = "replace" })
+	place(t, dial(t, srv, "username=a"), 3, 3, "#ff000080")
+
+	want := Pixel{R: 255, A: 0x80}
+	var board boardSnapshot
+	getJSON(t, srv, "/board", &board)
+	if got := board.Pixels[3][3]; got != want {
+		t.Fatalf("GET /board has %v, want %v", got, want)
+	}
+
+	resp, err := http.Get(srv.URL + "/board.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(3, 3)); got != (color.NRGBA{R: 255, A: 0x80}) {
+		t.Fatalf("PNG has %v, want half-transparent red", got)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errWouldTruncate      = errors.New("resizing would remove painted cells")
+	errResizeUnsupported  = errors.New("the board store cannot be resized")
+	errBadBoardDimensions = errors.New("width and height must be positive")
+)
+
+// cellBytes estimates the memory one cell takes on a board: its pixel and
+// owner. Owner usernames are not counted.
+const cellBytes = int(unsafe.Sizeof(Pixel{}) + unsafe.Sizeof(PixelMeta{}))
+
+// checkBoardSize reports whether rooms boards of width by height stay
+// within config.MaxBoardCells and config.MaxBoardMemory.
+func checkBoardSize(width, height, rooms int) error {
+	if width < 1 || height < 1 {
+		return errBadBoardDimensions
+	}
+	if width > config.MaxBoardCells/height {
+		return fmt.Errorf("board of %dx%d exceeds the maximum of %d cells", width, height, config.MaxBoardCells)
+	}
+	if estimate := width * height * cellBytes * rooms; estimate > config.MaxBoardMemory {
+		return fmt.Errorf("boards of %dx%d in %d rooms need about %d MiB, over the maximum of %d MiB",
+			width, height, rooms, estimate>>20, config.MaxBoardMemory>>20)
+	}
+	return nil
+}
+
+// ResizeMessage tells clients the board changed size. Cells that still fit
+// keep their color and new cells have color Fill.
+type ResizeMessage struct {
+	Type   string `json:"type"`
+	Seq    uint64 `json:"seq"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Fill   Pixel  `json:"fill"`
+}
+
+type ResizeRequest struct {
+	Width  int  `json:"width" binding:"min=1"`
+	Height int  `json:"height" binding:"min=1"`
+	Force  bool `json:"force"`
+}
+
+// Resize changes the board to width by height. Cells that still fit keep
+// their pixels and new cells get config.FillColor. Shrinking fails with
+// errWouldTruncate if it would remove painted cells, unless force is set.
+func (b *Board) Resize(width, height int, force bool) error {
+	if width < 1 || height < 1 {
+		return errBadBoardDimensions
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !force {
+		// Owners are not kept in snapshots, so painted cells are told
+		// apart by their color.
+		for y := range b.Pixels {
+			for x, p := range b.Pixels[y] {
+				if (x >= width || y >= height) && p != config.FillColor {
+					return errWouldTruncate
+				}
+			}
+		}
+	}
+	b.resize(width, height)
+	b.markAllDirty()
+	return nil
+}
+
+// resize reallocates the board to width by height. The caller must hold
+// b.mu.
+func (b *Board) resize(width, height int) {
+	pixels := make([][]Pixel, height)
+	owners := make([][]PixelMeta, height)
+	for y := range pixels {
+		pixels[y] = make([]Pixel, width)
+		owners[y] = make([]PixelMeta, width)
+		for x := range pixels[y] {
+			if x < b.Width && y < b.Height {
+				pixels[y][x], owners[y][x] = b.Pixels[y][x], b.Owners[y][x]
+			} else {
+				pixels[y][x] = config.FillColor
+			}
+		}
+	}
+	b.Width, b.Height = width, height
+	b.Pixels, b.Owners = pixels, owners
+	b.recount()
+}
+
+// resize changes the size of the board and tells every client. Pending
+// batched updates are sent first, since they predate the resize. It is
+// only called from the hub's Run loop.
+func (h *Hub) resize(width, height int, force bool) (uint64, error) {
+	store, ok := h.store.(resizableStore)
+	if !ok {
+		return 0, errResizeUnsupported
+	}
+	if err := checkBoardSize(width, height, len(rooms)); err != nil {
+		return 0, err
+	}
+	h.flushBatch()
+	if err := store.Resize(width, height, force); err != nil {
+		return 0, err
+	}
+	seq := h.changes.invalidate()
+	h.record(Event{Type: "resize", Seq: seq, Width: width, Height: height, Ts: h.clock()})
+	h.broadcastMessage(ResizeMessage{
+		Type:   "resize",
+		Seq:    seq,
+		Width:  width,
+		Height: height,
+		Fill:   config.FillColor,
+	}, uuid.Nil)
+	return seq, nil
+}
+
+// ResizeBoard grows or shrinks the board while it is running.
+func ResizeBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResizeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+
+		var seq uint64
+		var err error
+		if !hub.do(func() { seq, err = hub.resize(req.Width, req.Height, req.Force) }) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		switch {
+		case errors.Is(err, errWouldTruncate):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, errResizeUnsupported):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("Board resized", "room", hub.name, "width", req.Width, "height", req.Height, "force", req.Force, "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"seq": seq, "width": req.Width, "height": req.Height})
+	}
+}
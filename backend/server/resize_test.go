@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBoardResize(t *testing.T) {
+	useConfig(t, nil)
+	red := Pixel{R: 255, A: 255}
+	b := NewBoard(4, 4)
+	b.Set(1, 1, red, PixelMeta{Username: "a"})
+	b.Set(3, 3, red, PixelMeta{Username: "a"})
+
+	if err := b.Resize(6, 5, false); err != nil {
+		t.Fatalf("growing: %v", err)
+	}
+	for y, row := range b.Snapshot() {
+		for x, p := range row {
+			want := config.FillColor
+			if (x == 1 && y == 1) || (x == 3 && y == 3) {
+				want = red
+			}
+			if p != want {
+				t.Fatalf("after growing (%d, %d) is %v, want %v", x, y, p, want)
+			}
+		}
+	}
+	if _, meta := b.Get(3, 3); meta.Username != "a" {
+		t.Fatalf("growing lost the owner of (3, 3): %+v", meta)
+	}
+
+	if err := b.Resize(3, 3, false); !errors.Is(err, errWouldTruncate) {
+		t.Fatalf("shrinking over a painted cell: got %v, want %v", err, errWouldTruncate)
+	}
+	if w, h := b.Size(); w != 6 || h != 5 {
+		t.Fatalf("refused shrink left the board %dx%d", w, h)
+	}
+	if err := b.Resize(4, 4, false); err != nil {
+		t.Fatalf("shrinking over blank cells: %v", err)
+	}
+	if err := b.Resize(2, 2, true); err != nil {
+		t.Fatalf("forced shrink: %v", err)
+	}
+	if got := b.Snapshot(); len(got) != 2 || len(got[0]) != 2 || got[1][1] != red {
+		t.Fatalf("after a forced shrink the board is %v", got)
+	}
+}
+
+func TestResizeBoardEndpoint(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	var init InitBoardState
+	readType(t, conn, "init", &init)
+	place(t, conn, 10, 10, "#ff0000")
+
+	if status := postJSON(t, srv, "/admin/resize", ResizeRequest{Width: 8, Height: 8}, adminHeader(), nil); status != http.StatusConflict {
+		t.Fatalf("truncating resize got %d, want %d", status, http.StatusConflict)
+	}
+	if status := postJSON(t, srv, "/admin/resize", ResizeRequest{Width: 32, Height: 24}, adminHeader(), nil); status != http.StatusOK {
+		t.Fatalf("growing got %d", status)
+	}
+	var resize ResizeMessage
+	readType(t, conn, "resize", &resize)
+	if resize.Width != 32 || resize.Height != 24 || resize.Fill != config.FillColor || resize.Seq <= init.Seq {
+		t.Fatalf("got %+v after init at seq %d", resize, init.Seq)
+	}
+	if p, _ := HubInstance.store.Get(10, 10); p != (Pixel{R: 255, A: 255}) {
+		t.Fatalf("growing lost (10, 10): %v", p)
+	}
+	place(t, conn, 31, 23, "#000000")
+}
+
+func TestReplayAcrossResize(t *testing.T) {
+	useConfig(t, nil)
+	h := newTestHub(8, 8)
+	useFakeClock(h)
+	var log bytes.Buffer
+	h.events = NewEventLog(&log)
+	// As restoreEventLog records at the start of a new log.
+	h.record(Event{Type: "resize", Width: 8, Height: 8, Ts: h.clock()})
+
+	id := uuid.New()
+	if err := applyAs(h, id, 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.resize(12, 12, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyAs(h, id, 11, 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyAs(h, id, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.resize(4, 4, true); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := newReplayBoard()
+	if _, err := replayed.Replay(bytes.NewReader(log.Bytes())); err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if got, want := replayed.Snapshot(), h.store.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed board differs:\ngot  %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultRoom is the room served when a request names none. It keeps the
+// configured snapshot, event log and redis paths unchanged.
+const defaultRoom = "default"
+
+var roomNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// rooms holds the hub of every configured room by name. It is built by
+// Configure and not modified afterwards.
+var rooms = map[string]*Hub{defaultRoom: HubInstance}
+
+func newHub(name string, b *Board, st BoardStore) *Hub {
+	return &Hub{
+		name:       name,
+		board:      b,
+		store:      st,
+		clients:    make(map[uuid.UUID]*Client),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan Update, config.BroadcastBuffer),
+		commands:   make(chan func()),
+		names:      make(map[string]uuid.UUID),
+		cooldowns:  make(map[uuid.UUID]time.Time),
+		hotCells:   make(map[cell]time.Time),
+		buckets:    make(map[uuid.UUID]*tokenBucket),
+		placements: make(map[uuid.UUID]placement),
+		paints:     make(map[paintKey][]time.Time),
+		intents:    make(map[uuid.UUID]*intent),
+		votes:      make(map[cell]map[Pixel]map[uuid.UUID]time.Time),
+		ips:        make(map[string]int),
+		changes:    newChangeLog(config.ChangeLogSize),
+		clock:      time.Now,
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// newRoom creates the board, store and hub of the named room.
+func newRoom(name string, cfg Config) (*Hub, error) {
+	b := NewBoard(cfg.BoardWidth, cfg.BoardHeight)
+	switch cfg.Store {
+	case "memory":
+		return newHub(name, b, b), nil
+	case "redis":
+		rs, err := NewRedisStore(cfg.RedisAddr, roomKey(cfg.RedisPrefix, name), b)
+		if err != nil {
+			return nil, err
+		}
+		return newHub(name, b, rs), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+}
+
+// roomPath derives the file a room keeps at path, so that rooms other than
+// the default one do not share files: board.json becomes board-name.json.
+func roomPath(path, room string) string {
+	if path == "" || room == defaultRoom {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + room + ext
+}
+
+// roomKey derives the redis prefix of a room from prefix.
+func roomKey(prefix, room string) string {
+	if room == defaultRoom {
+		return prefix
+	}
+	return prefix + ":" + room
+}
+
+// roomFromQuery returns the hub of the room named by the room query
+// parameter, or the default room. It replies 404 for unknown rooms.
+func roomFromQuery(c *gin.Context) (*Hub, bool) {
+	h, ok := rooms[c.DefaultQuery("room", defaultRoom)]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no such room"})
+		return nil, false
+	}
+	return h, true
+}
+
+// totalClients counts the clients connected to all rooms.
+func totalClients() int {
+	n := 0
+	for _, h := range rooms {
+		h.mu.RLock()
+		n += len(h.clients)
+		h.mu.RUnlock()
+	}
+	return n
+}
+
+// ipConnections counts the clients connected from ip across all rooms.
+func ipConnections(ip string) int {
+	n := 0
+	for _, h := range rooms {
+		h.mu.RLock()
+		n += h.ips[ip]
+		h.mu.RUnlock()
+	}
+	return n
+}
+
+// Shutdown shuts down every room. See Hub.Shutdown.
+func Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, h := range rooms {
+		if err := h.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("room %s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRoomsAreIndependent(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Rooms = []string{"a", "b"} })
+	watcherA := dial(t, srv, "room=a&username=watcher")
+	watcherB := dial(t, srv, "room=b&username=watcher")
+	readType(t, watcherA, "init", &InitBoardState{})
+	readType(t, watcherB, "init", &InitBoardState{})
+
+	place(t, dial(t, srv, "room=a&username=painter"), 2, 3, "#ff4500")
+	place(t, dial(t, srv, "room=b&username=painter"), 5, 6, "#2450a4")
+
+	// Updates arrive in order, so the first one B sees must be its own.
+	var update Update
+	readType(t, watcherA, "update", &update)
+	if update.X != 2 || update.Y != 3 {
+		t.Fatalf("room a got the update at (%d, %d)", update.X, update.Y)
+	}
+	readType(t, watcherB, "update", &update)
+	if update.X != 5 || update.Y != 6 {
+		t.Fatalf("room b got the update at (%d, %d), placed in room a", update.X, update.Y)
+	}
+
+	for _, tc := range []struct {
+		room         string
+		placed, kept [2]int
+	}{
+		{"a", [2]int{2, 3}, [2]int{5, 6}},
+		{"b", [2]int{5, 6}, [2]int{2, 3}},
+	} {
+		var board boardSnapshot
+		if status := getJSON(t, srv, "/board?room="+tc.room, &board); status != http.StatusOK {
+			t.Fatalf("GET /board?room=%s: status %d", tc.room, status)
+		}
+		if board.Pixels[tc.placed[1]][tc.placed[0]] == config.FillColor {
+			t.Errorf("room %s is missing its pixel at %v", tc.room, tc.placed)
+		}
+		if board.Pixels[tc.kept[1]][tc.kept[0]] != config.FillColor {
+			t.Errorf("room %s has the other room's pixel at %v", tc.room, tc.kept)
+		}
+	}
+	var board boardSnapshot
+	getJSON(t, srv, "/board", &board)
+	if board.Pixels[3][2] != config.FillColor || board.Pixels[6][5] != config.FillColor {
+		t.Error("the default room has pixels placed in other rooms")
+	}
+}
+
+func TestUnknownRoom(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.Rooms = []string{"a"} })
+	for _, path := range []string{"/board?room=nope", "/ws?room=nope", "/pixel?room=nope&x=0&y=0"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: got status %d, want %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestConfigureRejectsBadRooms(t *testing.T) {
+	savedConfig, savedRooms, savedHub := config, rooms, HubInstance
+	t.Cleanup(func() { config, rooms, HubInstance = savedConfig, savedRooms, savedHub })
+	for _, names := range [][]string{{"a b"}, {"a", "a"}, {"default"}} {
+		if err := Configure(testConfig(t, func(cfg *Config) { cfg.Rooms = names })); err == nil {
+			t.Errorf("rooms %q were accepted", names)
+		}
+	}
+}
+
+func TestRoomPath(t *testing.T) {
+	for _, tc := range []struct{ path, room, want string }{
+		{"board.json", defaultRoom, "board.json"},
+		{"data/board.json", "art", "data/board-art.json"},
+		{"events", "art", "events-art"},
+		{"", "art", ""},
+	} {
+		if got := roomPath(tc.path, tc.room); got != tc.want {
+			t.Errorf("roomPath(%q, %q) = %q, want %q", tc.path, tc.room, got, tc.want)
+		}
+	}
+}
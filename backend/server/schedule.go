@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+var errCanvasClosed = errors.New("the canvas is closed")
+
+// CanvasMessage tells clients whether the canvas accepts placements and
+// when that changes. It is sent when the canvas opens or closes and on
+// connect while a schedule is configured.
+type CanvasMessage struct {
+	Type     string     `json:"type"`
+	Open     bool       `json:"open"`
+	OpensAt  *time.Time `json:"opensAt,omitempty"`
+	ClosesAt *time.Time `json:"closesAt,omitempty"`
+}
+
+// scheduled reports whether the canvas only opens for a window of time.
+func scheduled() bool {
+	return !config.OpensAt.IsZero() || !config.ClosesAt.IsZero()
+}
+
+// canvasOpen reports whether placements are accepted at now. A zero
+// config.OpensAt or config.ClosesAt leaves that end of the window open.
+func canvasOpen(now time.Time) bool {
+	return (config.OpensAt.IsZero() || !now.Before(config.OpensAt)) &&
+		(config.ClosesAt.IsZero() || now.Before(config.ClosesAt))
+}
+
+// nextTransition returns how long after now the canvas opens or closes. It
+// reports false if the canvas stays as it is.
+func nextTransition(now time.Time) (time.Duration, bool) {
+	if !config.OpensAt.IsZero() && now.Before(config.OpensAt) {
+		return config.OpensAt.Sub(now), true
+	}
+	if !config.ClosesAt.IsZero() && now.Before(config.ClosesAt) {
+		return config.ClosesAt.Sub(now), true
+	}
+	return 0, false
+}
+
+func canvasMessage(now time.Time) CanvasMessage {
+	message := CanvasMessage{Type: "canvas", Open: canvasOpen(now)}
+	if !config.OpensAt.IsZero() {
+		message.OpensAt = &config.OpensAt
+	}
+	if !config.ClosesAt.IsZero() {
+		message.ClosesAt = &config.ClosesAt
+	}
+	return message
+}
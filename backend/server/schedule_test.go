@@ -0,0 +1,95 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanvasSchedule(t *testing.T) {
+	start := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	srv := startServer(t, func(cfg *Config) {
+		cfg.OpensAt = start.Add(time.Hour)
+		cfg.ClosesAt = start.Add(2 * time.Hour)
+	})
+	// Swapped on the Run loop, which reads the clock.
+	var clock *fakeClock
+	HubInstance.do(func() { clock = useFakeClock(HubInstance) })
+
+	conn := dial(t, srv, "username=a")
+	var canvas CanvasMessage
+	readType(t, conn, "canvas", &canvas)
+	if canvas.Open || canvas.OpensAt == nil || !canvas.OpensAt.Equal(start.Add(time.Hour)) {
+		t.Fatalf("got %+v on connect, want closed until 13:00", canvas)
+	}
+
+	reply := request(t, conn, map[string]any{"type": "update", "x": 0, "y": 0, "color": "#000000"})
+	if reply.Type != "nack" || reply.Code != "canvas_closed" {
+		t.Fatalf("before opening: got %s %q, want nack canvas_closed", reply.Type, reply.Code)
+	}
+	clock.advance(time.Hour)
+	place(t, conn, 0, 0, "#000000")
+	clock.advance(time.Hour)
+	reply = request(t, conn, map[string]any{"type": "update", "x": 1, "y": 0, "color": "#000000"})
+	if reply.Code != "canvas_closed" {
+		t.Fatalf("after closing: got %s %q, want nack canvas_closed", reply.Type, reply.Code)
+	}
+
+	// Viewing stays allowed.
+	var board boardSnapshot
+	getJSON(t, srv, "/board", &board)
+	if board.Pixels[0][0] != (Pixel{A: 255}) || board.Pixels[0][1] != config.FillColor {
+		t.Fatalf("board has %v and %v, want only the placement made while open", board.Pixels[0][0], board.Pixels[0][1])
+	}
+}
+
+func TestCanvasOpensOnTime(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.OpensAt = time.Now().Add(100 * time.Millisecond) })
+	conn := dial(t, srv, "username=a")
+	var canvas CanvasMessage
+	if readType(t, conn, "canvas", &canvas); canvas.Open {
+		t.Fatal("canvas was open on connect")
+	}
+	if readType(t, conn, "canvas", &canvas); !canvas.Open {
+		t.Fatal("second canvas message did not open the canvas")
+	}
+	place(t, conn, 0, 0, "#000000")
+}
+
+func TestNextTransition(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name              string
+		opensAt, closesAt time.Time
+		want              time.Duration
+		ok                bool
+	}{
+		{"unscheduled", time.Time{}, time.Time{}, 0, false},
+		{"before opening", now.Add(time.Hour), now.Add(2 * time.Hour), time.Hour, true},
+		{"while open", now.Add(-time.Hour), now.Add(30 * time.Minute), 30 * time.Minute, true},
+		{"after closing", now.Add(-2 * time.Hour), now.Add(-time.Hour), 0, false},
+		{"opened for good", now.Add(-time.Hour), time.Time{}, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			useConfig(t, func(cfg *Config) { cfg.OpensAt, cfg.ClosesAt = tc.opensAt, tc.closesAt })
+			if got, ok := nextTransition(now); got != tc.want || ok != tc.ok {
+				t.Fatalf("got %v, %v; want %v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestScheduleConfig(t *testing.T) {
+	t.Setenv("RPLACE_OPENS_AT", "2024-04-01T13:00:00Z")
+	t.Setenv("RPLACE_CLOSES_AT", "not a time")
+	cfg := LoadConfig()
+	if !cfg.OpensAt.Equal(time.Date(2024, 4, 1, 13, 0, 0, 0, time.UTC)) || !cfg.ClosesAt.IsZero() {
+		t.Fatalf("read %v to %v from the environment", cfg.OpensAt, cfg.ClosesAt)
+	}
+
+	useConfig(t, nil)
+	opensAt := time.Date(2024, 4, 1, 13, 0, 0, 0, time.UTC)
+	cfg = testConfig(t, func(cfg *Config) { cfg.OpensAt, cfg.ClosesAt = opensAt, opensAt.Add(-time.Hour) })
+	if err := Configure(cfg); err == nil {
+		t.Fatal("a canvas closing before it opens was accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import "errors"
+
+var errBadShape = errors.New("shape must have a positive size")
+
+// ShapeMessage asks the server to draw a shape in one operation:
+// draw_rect fills Width by Height cells from (X, Y), draw_line draws a line
+// from (X, Y) to (X2, Y2). The color is given as for updates.
+type ShapeMessage struct {
+	Type   string `json:"type"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	X2     int    `json:"x2"`
+	Y2     int    `json:"y2"`
+}
+
+// cells expands the shape into the cells it covers, refusing shapes larger
+// than config.MaxOperationCells before allocating them.
+func (s ShapeMessage) cells() ([]cell, error) {
+	limit := config.MaxOperationCells
+	switch s.Type {
+	case "draw_rect":
+		if s.Width < 1 || s.Height < 1 {
+			return nil, errBadShape
+		}
+		if s.Width > limit || s.Height > limit || s.Width*s.Height > limit {
+			return nil, errTooLarge
+		}
+		return rectCells(Region{X: s.X, Y: s.Y, Width: s.Width, Height: s.Height}), nil
+	case "draw_line":
+		if max(distance(s.X, s.X2), distance(s.Y, s.Y2)) >= uint(limit) {
+			return nil, errTooLarge
+		}
+		return lineCells(s.X, s.Y, s.X2, s.Y2), nil
+	}
+	return nil, errBadShape
+}
+
+func rectCells(r Region) []cell {
+	cells := make([]cell, 0, r.Width*r.Height)
+	for y := r.Y; y < r.Y+r.Height; y++ {
+		for x := r.X; x < r.X+r.Width; x++ {
+			cells = append(cells, cell{X: x, Y: y})
+		}
+	}
+	return cells
+}
+
+// lineCells returns the cells of a line from (x0, y0) to (x1, y1), both
+// included, using Bresenham's algorithm.
+func lineCells(x0, y0, x1, y1 int) []cell {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	cells := make([]cell, 0, max(dx, -dy)+1)
+	for err := dx + dy; ; {
+		cells = append(cells, cell{X: x0, Y: y0})
+		if x0 == x1 && y0 == y1 {
+			return cells
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// distance returns how far apart a and b are. Unlike abs(b-a) it cannot
+// overflow, whatever coordinates a client sends.
+func distance(a, b int) uint {
+	if a > b {
+		a, b = b, a
+	}
+	return uint(b) - uint(a)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// draw paints the cells Read expanded from a draw_rect or draw_line
+// message. The whole shape must lie on the board.
+func (h *Hub) draw(message Update) ([]Update, error) {
+	for _, c := range message.cells {
+		if !h.store.InBounds(c.X, c.Y) {
+			return nil, errOutOfBounds
+		}
+	}
+	return h.paint(message, message.cells)
+}
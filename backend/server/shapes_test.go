@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestShapeCells(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.MaxOperationCells = 12 })
+	for _, tc := range []struct {
+		shape ShapeMessage
+		want  []cell
+	}{
+		{ShapeMessage{Type: "draw_rect", X: 1, Y: 2, Width: 3, Height: 2}, []cell{{1, 2}, {2, 2}, {3, 2}, {1, 3}, {2, 3}, {3, 3}}},
+		{ShapeMessage{Type: "draw_line", X: 0, Y: 0, X2: 5, Y2: 2}, []cell{{0, 0}, {1, 0}, {2, 1}, {3, 1}, {4, 2}, {5, 2}}},
+		{ShapeMessage{Type: "draw_line", X: 3, Y: 4, X2: 3, Y2: 1}, []cell{{3, 4}, {3, 3}, {3, 2}, {3, 1}}},
+		{ShapeMessage{Type: "draw_line", X: 2, Y: 2, X2: 0, Y2: 0}, []cell{{2, 2}, {1, 1}, {0, 0}}},
+		{ShapeMessage{Type: "draw_line", X: 7, Y: 7, X2: 7, Y2: 7}, []cell{{7, 7}}},
+	} {
+		got, err := tc.shape.cells()
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%+v covers %v, %v; want %v", tc.shape, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		shape ShapeMessage
+		err   error
+	}{
+		{ShapeMessage{Type: "draw_rect", Width: 0, Height: 3}, errBadShape},
+		{ShapeMessage{Type: "draw_rect", Width: 4, Height: 4}, errTooLarge},
+		{ShapeMessage{Type: "draw_rect", Width: 1 << 40, Height: 1 << 40}, errTooLarge},
+		{ShapeMessage{Type: "draw_line", X2: 12}, errTooLarge},
+		{ShapeMessage{Type: "draw_line", X: math.MinInt}, errTooLarge},
+		{ShapeMessage{Type: "draw_line", Y: math.MaxInt, Y2: math.MinInt}, errTooLarge},
+		{ShapeMessage{Type: "draw_circle"}, errBadShape},
+	} {
+		if _, err := tc.shape.cells(); err != tc.err {
+			t.Errorf("%+v got %v, want %v", tc.shape, err, tc.err)
+		}
+	}
+}
+
+func TestDrawRect(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	shape := map[string]any{"type": "draw_rect", "x": 13, "y": 14, "width": 3, "height": 2, "color": "#000000"}
+	if reply := request(t, conn, shape); reply.Type != "ack" {
+		t.Fatalf("draw_rect got %s %q", reply.Type, reply.Message)
+	}
+	got := painted(Pixel{A: 255})
+	sort.Slice(got, func(i, j int) bool { return got[i].Y < got[j].Y || got[i].Y == got[j].Y && got[i].X < got[j].X })
+	if want := []cell{{13, 14}, {14, 14}, {15, 14}, {13, 15}, {14, 15}, {15, 15}}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("draw_rect painted %v, want %v", got, want)
+	}
+
+	shape = map[string]any{"type": "draw_rect", "x": 14, "y": 0, "width": 3, "height": 1, "color": "#ff4500"}
+	if reply := request(t, conn, shape); reply.Code != "out_of_bounds" {
+		t.Fatalf("draw_rect off the board got %s %q, want nack out_of_bounds", reply.Type, reply.Code)
+	}
+	if got := painted(Pixel{R: 0xff, G: 0x45, A: 255}); len(got) != 0 {
+		t.Fatalf("refused draw_rect painted %v", got)
+	}
+}
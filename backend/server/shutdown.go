@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+)
+
+// Shutdown stops accepting new connections, sends every client a close
+// frame, stops the Run loop and writes a final board snapshot. It returns
+// early with ctx's error if the clients do not finish closing in time.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.closing.Store(true)
+	h.shutdownOnce.Do(func() {
+		close(h.quit)
+	})
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	writersDone := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(writersDone)
+	}()
+	select {
+	case <-writersDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if remote, ok := h.store.(remoteStore); ok {
+		if err := remote.Close(); err != nil {
+			logger.Error("Failed to close store", "error", err)
+		}
+	}
+
+	if h.events != nil {
+		if err := h.events.Close(); err != nil {
+			logger.Error("Failed to close event log", "error", err)
+		}
+	}
+
+	if err := h.saveBoard(); err != nil {
+		logger.Error("Failed to save final board snapshot", "error", err)
+		return err
+	}
+	return nil
+}
+
+// closeAll disconnects every client. Closing a client makes its Write loop
+// send a close frame and exit.
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, client := range h.clients {
+		delete(h.clients, id)
+		delete(h.placements, id)
+		h.dropIntent(id)
+		h.forgetIP(client.ip)
+		delete(h.names, client.Username)
+		client.close()
+		clientsConnected.Dec()
+	}
+	h.roster = roster{}
+	logger.Info("Closed all client connections")
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShutdownSendsCloseFrames(t *testing.T) {
+	srv := startServer(t, nil)
+	var conns []*websocket.Conn
+	for _, name := range []string{"a", "b", "c"} {
+		conn := dial(t, srv, "username="+name)
+		readType(t, conn, "init", &InitBoardState{})
+		conns = append(conns, conn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+	if err := HubInstance.Shutdown(ctx); err != nil {
+		t.Fatalf("shutting down: %v", err)
+	}
+
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("client %d: got %v, want a going away close frame", i, err)
+		}
+	}
+}
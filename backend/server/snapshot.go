@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snapshotMu serialises writes so a final snapshot never races a periodic one.
+var snapshotMu sync.Mutex
+
+type boardSnapshot struct {
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Pixels [][]Pixel `json:"pixels"`
+	// Placements holds the leaderboard counts by username.
+	Placements map[string]int `json:"placements,omitempty"`
+}
+
+// SaveSnapshot writes the board to path. The file is written to a temporary
+// location first and renamed so a crash never leaves a truncated snapshot.
+// Nothing is written if the board has not changed since the last save.
+func (b *Board) SaveSnapshot(path string) error {
+	return b.saveSnapshot(path, nil)
+}
+
+// saveSnapshot writes the board to path along with the leaderboard counts.
+// It skips the write if nothing changed since the last saved snapshot.
+func (b *Board) saveSnapshot(path string, placements map[string]int) error {
+	snap, version, dirty := b.versionedSnapshot()
+	if !dirty {
+		logger.Debug("Board unchanged, skipping snapshot", "path", path)
+		return nil
+	}
+	snap.Placements = placements
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	b.markSaved(version)
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so a crash never leaves a truncated file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot replaces the board's pixels with the snapshot stored at path.
+func (b *Board) LoadSnapshot(path string) error {
+	_, err := b.loadSnapshot(path)
+	return err
+}
+
+// loadSnapshot is LoadSnapshot, also returning the leaderboard counts
+// saved with the snapshot.
+func (b *Board) loadSnapshot(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot boardSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Width < 1 || snapshot.Height < 1 || len(snapshot.Pixels) != snapshot.Height {
+		return nil, fmt.Errorf("snapshot is %dx%d but has %d rows", snapshot.Width, snapshot.Height, len(snapshot.Pixels))
+	}
+	for _, row := range snapshot.Pixels {
+		if len(row) != snapshot.Width {
+			return nil, fmt.Errorf("snapshot row has %d pixels, snapshot is %d wide", len(row), snapshot.Width)
+		}
+	}
+
+	b.mu.Lock()
+	// The board may have been resized since it was configured.
+	if snapshot.Width != b.Width || snapshot.Height != b.Height {
+		logger.Info("Taking board size from snapshot", "width", snapshot.Width, "height", snapshot.Height)
+		b.Width, b.Height = snapshot.Width, snapshot.Height
+	}
+	b.Pixels = snapshot.Pixels
+	b.recount()
+	b.Owners = make([][]PixelMeta, b.Height)
+	for y := range b.Owners {
+		b.Owners[y] = make([]PixelMeta, b.Width)
+	}
+	b.version++
+	b.savedVersion = b.version
+	b.dirty = Region{}
+	b.mu.Unlock()
+	return snapshot.Placements, nil
+}
+
+// RestoreSnapshot loads the ban list and the configured snapshot into the
+// board of every room. A missing snapshot file is not an error; the board
+// simply stays freshly initialised.
+func RestoreSnapshot() error {
+	if err := loadBans(); err != nil {
+		return err
+	}
+	for _, h := range rooms {
+		if err := h.restoreSnapshot(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) restoreSnapshot() error {
+	path := roomPath(config.SnapshotPath, h.name)
+	if path == "" {
+		return nil
+	}
+	if _, ok := h.store.(remoteStore); ok {
+		logger.Info("Board is loaded from the shared store, skipping snapshot restore", "room", h.name)
+		return nil
+	}
+	placements, err := h.board.loadSnapshot(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Info("No snapshot found, starting with an empty board", "path", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	h.leaderboard.restore(placements)
+	logger.Info("Restored board from snapshot", "path", path)
+	return nil
+}
+
+// SaveBoard writes the board of every room to its snapshot path.
+func SaveBoard() error {
+	for _, h := range rooms {
+		if err := h.saveBoard(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) saveBoard() error {
+	path := roomPath(config.SnapshotPath, h.name)
+	if path == "" {
+		return nil
+	}
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	return h.board.saveSnapshot(path, h.leaderboard.snapshot())
+}
+
+// StartSnapshots periodically saves the board in the background.
+func StartSnapshots() {
+	if config.SnapshotPath == "" || config.SnapshotInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(config.SnapshotInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SaveBoard(); err != nil {
+				logger.Error("Snapshot error", "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	useConfig(t, nil)
+	path := filepath.Join(t.TempDir(), "board.json")
+
+	b := NewBoard(6, 4)
+	b.Set(0, 0, Pixel{R: 255, A: 255}, PixelMeta{})
+	b.Set(5, 0, Pixel{G: 255, A: 255}, PixelMeta{})
+	b.Set(2, 3, Pixel{B: 255, A: 255}, PixelMeta{})
+	b.Set(3, 1, Pixel{R: 12, G: 34, B: 56, A: 78}, PixelMeta{})
+	if err := b.SaveSnapshot(path); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	loaded := NewBoard(6, 4)
+	if err := loaded.LoadSnapshot(path); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if got, want := loaded.Snapshot(), b.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded board differs:\ngot  %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSpectator(t *testing.T) {
+	srv := startServer(t, nil)
+	spectator := dial(t, srv, "mode=spectator&username=overlay")
+	var init InitBoardState
+	readType(t, spectator, "init", &init)
+
+	send(t, spectator, map[string]any{"type": "update", "x": 0, "y": 0, "color": "#000000"})
+	// The Read loop handles messages in order, so the update has been
+	// dropped, or queued ahead of the next placement, once this answers.
+	send(t, spectator, map[string]any{"type": "resync", "seq": init.Seq})
+	readType(t, spectator, "delta", &DeltaMessage{})
+
+	player := dial(t, srv, "username=player")
+	place(t, player, 1, 1, "#ff4500")
+	var update Update
+	readType(t, spectator, "update", &update)
+	if update.X != 1 || update.Y != 1 {
+		t.Fatalf("spectator got %+v, want the player's update", update)
+	}
+	if p, _ := HubInstance.store.Get(0, 0); p != config.FillColor {
+		t.Fatalf("spectator's update was applied: %v", p)
+	}
+
+	var users []UserInfo
+	if status := getJSON(t, srv, "/users", &users); status != http.StatusOK {
+		t.Fatalf("GET /users: status %d", status)
+	}
+	if len(users) != 1 || users[0].Username != "player" {
+		t.Fatalf("GET /users lists %+v, want only the player", users)
+	}
+}
+
+func TestUnknownMode(t *testing.T) {
+	srv := startServer(t, nil)
+	resp, err := http.Get(srv.URL + "/ws?mode=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var errImageTooLarge = errors.New("image is larger than the board")
+
+// stampName is recorded as the owner of cells written by StampImage.
+const stampName = "admin"
+
+// stamp writes img onto the board with its top-left corner at (x, y),
+// mapped onto the palette, optionally with dithering. Parts of the image
+// outside the board and fully transparent pixels are skipped. Locks and
+// cooldowns do not apply. It is only called from the hub's Run loop.
+func (h *Hub) stamp(img image.Image, x, y int, dither bool) ([]Update, error) {
+	now := h.clock()
+	meta := PixelMeta{Username: stampName, PlacedAt: now}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	pixels := Quantize(img, config.Palette, dither)
+	var updates []Update
+	for iy := 0; iy < height; iy++ {
+		for ix := 0; ix < width; ix++ {
+			bx, by := x+ix, y+iy
+			if !h.store.InBounds(bx, by) {
+				continue
+			}
+			p := pixels[iy*width+ix]
+			if p.A == 0 {
+				continue
+			}
+			u, err := h.write(Update{
+				Type:       "update",
+				Pixel:      p,
+				X:          bx,
+				Y:          by,
+				SenderName: stampName,
+				Ts:         now,
+			}, meta)
+			if err != nil {
+				return updates, err
+			}
+			updates = append(updates, u)
+		}
+	}
+	return updates, nil
+}
+
+// StampImage writes an uploaded PNG onto the board, with its top-left
+// corner at the x and y form values, and sends the changed cells to every
+// client as one batch. Colors are mapped to the nearest palette color, with
+// dithering if the dither form value is true.
+func StampImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		x, errX := strconv.Atoi(c.PostForm("x"))
+		y, errY := strconv.Atoi(c.PostForm("y"))
+		if errX != nil || errY != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "x and y must be integers"})
+			return
+		}
+		dither, err := strconv.ParseBool(c.DefaultPostForm("dither", "false"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dither must be true or false"})
+			return
+		}
+		header, err := c.FormFile("image")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+			return
+		}
+		img, err := decodeStampImage(header, hub)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updates []Update
+		if !hub.do(func() {
+			updates, err = hub.stamp(img, x, y, dither)
+			if len(updates) == 0 {
+				return
+			}
+			// Pending updates are older than the stamp, so they go first.
+			hub.flushBatch()
+			hub.broadcastMessage(BatchMessage{
+				Type:    "batch",
+				Updates: updates,
+			}, uuid.Nil)
+		}) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "cells": len(updates)})
+			return
+		}
+		logger.Info("Image stamped", "room", hub.name, "x", x, "y", y, "cells", len(updates), "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"cells": len(updates), "seq": hub.changes.seq()})
+	}
+}
+
+// decodeStampImage decodes an uploaded PNG, refusing images larger than
+// the board before decoding their pixels.
+func decodeStampImage(header *multipart.FileHeader, hub *Hub) (image.Image, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cfg, err := png.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	width, height := hub.store.Size()
+	if cfg.Width > width || cfg.Height > height {
+		return nil, errImageTooLarge
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+// postImage uploads img to /admin/region/fill with the given form fields.
+func postImage(t *testing.T, srv string, img image.Image, fields map[string]string, header http.Header) int {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for k, v := range fields {
+		form.WriteField(k, v)
+	}
+	part, err := form.CreateFormFile("image", "stamp.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(part, img); err != nil {
+		t.Fatal(err)
+	}
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv+"/admin/region/fill", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestStampImage(t *testing.T) {
+	red, green := Pixel{R: 255, A: 255}, Pixel{G: 255, A: 255}
+	srv := startServer(t, func(cfg *Config) {
+		cfg.Palette = []Pixel{{A: 255}, {R: 255, G: 255, B: 255, A: 255}, red, green}
+	})
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+
+	// Three columns, the last of which falls off the board, and a
+	// transparent cell that is left alone.
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.NRGBA{R: 250, G: 10, A: 255})
+	img.Set(1, 0, color.NRGBA{G: 240, B: 20, A: 255})
+	img.Set(2, 0, color.NRGBA{R: 255, A: 255})
+	img.Set(0, 1, color.NRGBA{R: 255, A: 255})
+	img.Set(1, 1, color.NRGBA{})
+	fields := map[string]string{"x": "14", "y": "3"}
+
+	if status := postImage(t, srv.URL, img, fields, nil); status != http.StatusUnauthorized {
+		t.Fatalf("stamping without the admin token: status %d", status)
+	}
+	if status := postImage(t, srv.URL, img, fields, adminHeader()); status != http.StatusOK {
+		t.Fatalf("stamping: status %d", status)
+	}
+
+	want := map[cell]Pixel{{14, 3}: red, {15, 3}: green, {14, 4}: red}
+	for y, row := range HubInstance.store.Snapshot() {
+		for x, p := range row {
+			expected, ok := want[cell{x, y}]
+			if !ok {
+				expected = config.FillColor
+			}
+			if p != expected {
+				t.Errorf("cell (%d, %d) is %v, want %v", x, y, p, expected)
+			}
+		}
+	}
+	var batch BatchMessage
+	readType(t, watcher, "batch", &batch)
+	if len(batch.Updates) != len(want) {
+		t.Fatalf("watcher got a batch of %d updates, want %d", len(batch.Updates), len(want))
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coverageCacheTTL is how long the board scan behind Stats is reused.
+const coverageCacheTTL = 10 * time.Second
+
+// hubStats counts placements for GET /stats. It is only accessed from the
+// hub's Run loop.
+type hubStats struct {
+	placed int
+	// recent holds the times of placements in the last minute, oldest first.
+	recent []time.Time
+}
+
+func (s *hubStats) record(now time.Time) {
+	s.placed++
+	s.recent = append(s.recent, now)
+	s.prune(now)
+}
+
+// prune forgets placements older than a minute.
+func (s *hubStats) prune(now time.Time) {
+	i := 0
+	for i < len(s.recent) && now.Sub(s.recent[i]) > time.Minute {
+		i++
+	}
+	s.recent = s.recent[i:]
+}
+
+// coverageCache remembers the share of cells of a board that differ from
+// the fill color.
+type coverageCache struct {
+	mu      sync.Mutex
+	value   float64
+	updated time.Time
+}
+
+// get returns the percentage of cells in st that are painted, scanning
+// the board at most once per coverageCacheTTL.
+func (c *coverageCache) get(st BoardStore, now time.Time) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.updated.IsZero() && now.Sub(c.updated) < coverageCacheTTL {
+		return c.value
+	}
+	var total, painted int
+	for _, row := range st.Snapshot() {
+		for _, p := range row {
+			total++
+			if isPainted(p) {
+				painted++
+			}
+		}
+	}
+	c.value = 0
+	if total > 0 {
+		c.value = float64(painted) * 100 / float64(total)
+	}
+	c.updated = now
+	return c.value
+}
+
+type StatsResponse struct {
+	PixelsPlaced     int        `json:"pixelsPlaced"`
+	ClientsConnected int        `json:"clientsConnected"`
+	PlacedLastMinute int        `json:"placedLastMinute"`
+	MostActiveUser   *UserCount `json:"mostActiveUser"`
+	PaintedPercent   float64    `json:"paintedPercent"`
+}
+
+type UserCount struct {
+	Username string `json:"username"`
+	Pixels   int    `json:"pixels"`
+}
+
+// GetStats summarises the activity of a room since the server started.
+func GetStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		var stats StatsResponse
+		if !hub.do(func() {
+			now := hub.clock()
+			hub.stats.prune(now)
+			stats.PixelsPlaced = hub.stats.placed
+			stats.PlacedLastMinute = len(hub.stats.recent)
+			stats.ClientsConnected = len(hub.clients)
+		}) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		if top := hub.leaderboard.top(1); len(top) > 0 {
+			stats.MostActiveUser = &top[0]
+		}
+		stats.PaintedPercent = hub.coverage.get(hub.store, hub.clock())
+		c.JSON(http.StatusOK, stats)
+	}
+}
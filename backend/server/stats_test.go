@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetStats(t *testing.T) {
+	srv := startServer(t, nil)
+	var clock *fakeClock
+	HubInstance.do(func() { clock = useFakeClock(HubInstance) })
+
+	a := dial(t, srv, "username=a")
+	b := dial(t, srv, "username=b")
+	for x := range 3 {
+		place(t, a, x, 0, "#ff4500")
+	}
+	place(t, b, 0, 1, "#2450a4")
+
+	stats := func() StatsResponse {
+		t.Helper()
+		var s StatsResponse
+		if status := getJSON(t, srv, "/stats", &s); status != http.StatusOK {
+			t.Fatalf("GET /stats: status %d", status)
+		}
+		return s
+	}
+	s := stats()
+	if s.PixelsPlaced != 4 || s.PlacedLastMinute != 4 || s.ClientsConnected != 2 {
+		t.Fatalf("stats are %+v, want 4 placed, 4 in the last minute and 2 clients", s)
+	}
+	if s.MostActiveUser == nil || s.MostActiveUser.Username != "a" || s.MostActiveUser.Pixels != 3 {
+		t.Fatalf("most active user is %+v, want a with 3", s.MostActiveUser)
+	}
+	if want := 4 * 100.0 / 256; s.PaintedPercent != want {
+		t.Fatalf("painted percent is %v, want %v", s.PaintedPercent, want)
+	}
+
+	// The board scan is cached for a while.
+	place(t, b, 1, 1, "#2450a4")
+	if s := stats(); s.PaintedPercent != 4*100.0/256 {
+		t.Fatalf("painted percent is %v within the cache period, want the cached value", s.PaintedPercent)
+	}
+
+	clock.advance(2 * time.Minute)
+	s = stats()
+	if s.PixelsPlaced != 5 || s.PlacedLastMinute != 0 {
+		t.Fatalf("stats are %+v two minutes later, want 5 placed and none in the last minute", s)
+	}
+	if want := 5 * 100.0 / 256; s.PaintedPercent != want {
+		t.Fatalf("painted percent is %v after the cache expired, want %v", s.PaintedPercent, want)
+	}
+}
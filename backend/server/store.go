@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BoardStore holds the canvas. The in-memory Board is the default
+// implementation; other stores let several server instances share a board.
+type BoardStore interface {
+	Size() (width, height int)
+	InBounds(x, y int) bool
+	Get(x, y int) (Pixel, PixelMeta)
+	Set(x, y int, p Pixel, meta PixelMeta) error
+	Snapshot() [][]Pixel
+	// Heat returns the pixels with tint blended over the cells placed at
+	// or after since.
+	Heat(since time.Time, tint Pixel) [][]Pixel
+	Reset() error
+	// Ping reports whether the store is reachable.
+	Ping(ctx context.Context) error
+}
+
+// resizableStore is implemented by stores whose board can change size.
+type resizableStore interface {
+	Resize(width, height int, force bool) error
+}
+
+// bufferedStore is implemented by stores that keep accepting writes while
+// their backend is down, holding them until it recovers.
+type bufferedStore interface {
+	Buffered() int
+}
+
+// portableStore is implemented by stores whose whole board, owners
+// included, can be exported and replaced for backups.
+type portableStore interface {
+	Export() ([][]Pixel, [][]PixelMeta)
+	Replace(pixels [][]Pixel, owners [][]PixelMeta) error
+}
+
+// remoteStore is implemented by stores shared between server instances.
+// Updates delivers pixels placed on other instances, already applied to the
+// store, so the hub only has to forward them to its own clients.
+type remoteStore interface {
+	Publish(x, y int, p Pixel, meta PixelMeta) error
+	Updates() <-chan Update
+	Close() error
+}
+
+func (b *Board) Size() (int, int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.Width, b.Height
+}
+
+// Get returns the pixel at (x, y) and who placed it. Cells out of bounds,
+// which a concurrent resize may cause, read as zero.
+func (b *Board) Get(x, y int) (Pixel, PixelMeta) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.inBounds(x, y) {
+		return Pixel{}, PixelMeta{}
+	}
+	return b.Pixels[y][x], b.Owners[y][x]
+}
+
+func (b *Board) Set(x, y int, p Pixel, meta PixelMeta) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.inBounds(x, y) {
+		return errOutOfBounds
+	}
+	b.setPixel(x, y, p)
+	b.Owners[y][x] = meta
+	b.markDirty(x, y)
+	return nil
+}
+
+func (b *Board) Reset() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.InitBoard()
+	b.markAllDirty()
+	return nil
+}
+
+func (b *Board) Ping(context.Context) error {
+	return nil
+}
+
+// remoteUpdate is the pub/sub payload. It carries the owner metadata that
+// the JSON form of Update leaves out. Type is "reset" when the board was
+// cleared and empty for a pixel.
+type remoteUpdate struct {
+	Type     string    `json:"type,omitempty"`
+	Instance uuid.UUID `json:"instance"`
+	X        int       `json:"x"`
+	Y        int       `json:"y"`
+	Pixel    Pixel     `json:"pixel"`
+	Meta     PixelMeta `json:"meta"`
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testBoardStore checks the BoardStore contract on an empty 4x3 store.
+func testBoardStore(t *testing.T, st BoardStore) {
+	if w, h := st.Size(); w != 4 || h != 3 {
+		t.Fatalf("size is %dx%d, want 4x3", w, h)
+	}
+	for _, pos := range [][2]int{{-1, 0}, {0, -1}, {4, 0}, {0, 3}} {
+		if st.InBounds(pos[0], pos[1]) {
+			t.Errorf("%v is in bounds", pos)
+		}
+	}
+	if !st.InBounds(3, 2) {
+		t.Error("(3, 2) is out of bounds")
+	}
+
+	red := Pixel{R: 255, A: 255}
+	placed := time.Now()
+	meta := PixelMeta{Username: "a", UUID: uuid.New(), PlacedAt: placed}
+	if err := st.Set(3, 2, red, meta); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if p, got := st.Get(3, 2); p != red || got.Username != "a" || got.UUID != meta.UUID || !got.PlacedAt.Equal(placed) {
+		t.Fatalf("get returned %v, %+v", p, got)
+	}
+	if p, got := st.Get(9, 9); p != (Pixel{}) || got != (PixelMeta{}) {
+		t.Fatalf("get out of bounds returned %v, %+v", p, got)
+	}
+	if err := st.Set(4, 0, red, meta); err == nil {
+		t.Fatal("set out of bounds succeeded")
+	}
+
+	snap := st.Snapshot()
+	if len(snap) != 3 || len(snap[0]) != 4 || snap[2][3] != red || snap[0][0] != config.FillColor {
+		t.Fatalf("snapshot is %v", snap)
+	}
+	snap[2][3] = Pixel{}
+	if p, _ := st.Get(3, 2); p != red {
+		t.Fatal("changing the snapshot changed the store")
+	}
+
+	heat := st.Heat(placed.Add(-time.Second), Pixel{B: 255, A: 255})
+	if heat[2][3] != (Pixel{B: 255, A: 255}) || heat[0][0] != config.FillColor {
+		t.Fatalf("heat map is %v", heat)
+	}
+	if heat := st.Heat(placed.Add(time.Second), Pixel{B: 255, A: 255}); heat[2][3] != red {
+		t.Fatalf("cell placed before the window is tinted: %v", heat[2][3])
+	}
+
+	if err := st.Reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if p, got := st.Get(3, 2); p != config.FillColor || got != (PixelMeta{}) {
+		t.Fatalf("after reset (3, 2) is %v, %+v", p, got)
+	}
+	if err := st.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+}
+
+func TestBoardStore(t *testing.T) {
+	useConfig(t, nil)
+	testBoardStore(t, NewBoard(4, 3))
+}
+
+func TestRedisBoardStore(t *testing.T) {
+	useConfig(t, nil)
+	store, err := NewRedisStore(startRedis(t).addr(), "test", NewBoard(4, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testBoardStore(t, store)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxTimelapseFrames = 500
+	// maxTimelapsePixels caps the pixels of all frames together, which are
+	// held in memory until the GIF is encoded.
+	maxTimelapsePixels = 1 << 26
+	timelapseDelay     = 10 // hundredths of a second between frames
+)
+
+var (
+	errTooManyFrames = errors.New("timelapse would be too large, use a larger step or a smaller scale")
+	// errStopReplay ends a replay early once the requested range is done.
+	errStopReplay = errors.New("stop replay")
+)
+
+// timelapseOptions selects which events become frames. A frame is taken every
+// Step events, or every Every of event time when Every is set.
+type timelapseOptions struct {
+	From  uint64
+	To    uint64
+	Step  uint64
+	Every time.Duration
+	Scale int
+}
+
+// renderTimelapse replays the event log in r onto an empty board and returns
+// an animated GIF of the board between opts.From and opts.To. It fails with
+// errTooManyFrames once the frames exceed maxTimelapseFrames or
+// maxTimelapsePixels.
+func renderTimelapse(r io.Reader, opts timelapseOptions) (*gif.GIF, error) {
+	b := newReplayBoard()
+	anim := &gif.GIF{}
+	pixels := 0
+	// The GIF is as large as the largest frame, since the board may have
+	// been resized along the way.
+	var width, height int
+	addFrame := func() error {
+		size := b.Width * b.Height * opts.Scale * opts.Scale
+		if len(anim.Image) >= maxTimelapseFrames || pixels+size > maxTimelapsePixels {
+			return errTooManyFrames
+		}
+		pixels += size
+		width, height = max(width, b.Width), max(height, b.Height)
+		img := renderImage(b.Pixels, opts.Scale)
+		frame := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.Draw(frame, frame.Bounds(), img, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, timelapseDelay)
+		return nil
+	}
+
+	var (
+		sinceFrame uint64
+		frameTime  time.Time
+		started    bool
+	)
+	err := ReadEvents(r, func(e Event) error {
+		if e.Seq > opts.To {
+			return errStopReplay
+		}
+		if err := b.applyEvent(e); err != nil {
+			return err
+		}
+		if e.Seq < opts.From {
+			return nil
+		}
+		if !started {
+			started = true
+			frameTime = e.Ts
+			return addFrame()
+		}
+
+		sinceFrame++
+		due := sinceFrame >= opts.Step
+		if opts.Every > 0 {
+			due = e.Ts.Sub(frameTime) >= opts.Every
+		}
+		if !due {
+			return nil
+		}
+		sinceFrame = 0
+		frameTime = e.Ts
+		return addFrame()
+	})
+	if err != nil && !errors.Is(err, errStopReplay) {
+		return nil, err
+	}
+	if sinceFrame > 0 || !started {
+		if err := addFrame(); err != nil {
+			return nil, err
+		}
+	}
+	anim.Config = image.Config{
+		ColorModel: color.Palette(palette.Plan9),
+		Width:      width * opts.Scale,
+		Height:     height * opts.Scale,
+	}
+	return anim, nil
+}
+
+// GetTimelapse renders the event log as an animated GIF. Query parameters:
+// from and to bound the sequence numbers, step takes a frame every N events,
+// or every takes one per duration of event time (e.g. every=10m), and scale
+// enlarges each cell.
+func GetTimelapse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		path := roomPath(config.EventLogPath, hub.name)
+		if path == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event log is not enabled"})
+			return
+		}
+
+		opts := timelapseOptions{To: hub.changes.seq(), Step: 1, Scale: 1}
+		var err error
+		if v := c.Query("from"); v != "" {
+			if opts.From, err = strconv.ParseUint(v, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a sequence number"})
+				return
+			}
+		}
+		if v := c.Query("to"); v != "" {
+			if opts.To, err = strconv.ParseUint(v, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a sequence number"})
+				return
+			}
+		}
+		if v := c.Query("step"); v != "" {
+			if opts.Step, err = strconv.ParseUint(v, 10, 64); err != nil || opts.Step == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "step must be a positive number of events"})
+				return
+			}
+		}
+		if v := c.Query("every"); v != "" {
+			if opts.Every, err = time.ParseDuration(v); err != nil || opts.Every <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "every must be a positive duration"})
+				return
+			}
+		}
+		if v := c.Query("scale"); v != "" {
+			if opts.Scale, err = strconv.Atoi(v); err != nil || opts.Scale < 1 || opts.Scale > maxImageScale {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "scale must be between 1 and " + strconv.Itoa(maxImageScale)})
+				return
+			}
+		}
+		if opts.From > opts.To {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+
+		width, height := hub.store.Size()
+		if !checkImageSize(c, width, height, opts.Scale) {
+			return
+		}
+		anim, err := renderTimelapse(f, opts)
+		if errors.Is(err, errTooManyFrames) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", "image/gif")
+		c.Status(http.StatusOK)
+		if err := gif.EncodeAll(c.Writer, anim); err != nil {
+			logger.Error("GIF encode error", "error", err)
+		}
+	}
+}
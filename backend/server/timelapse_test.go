@@ -0,0 +1,61 @@
+package server
+
+import (
+	"image/gif"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestTimelapseFrames(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.EventLogPath = filepath.Join(t.TempDir(), "events.jsonl")
+	})
+	conn := dial(t, srv, "username=a")
+	for x := range 5 {
+		place(t, conn, x, 0, "#ff0000")
+	}
+
+	// The log holds the resize it starts with at seq 0 and placements 1 to
+	// 5. Frames are taken at the start, at 2 and 4, and at the end.
+	for _, tc := range []struct {
+		query  string
+		frames int
+	}{
+		{"", 6},
+		{"?step=2", 4},
+		{"?step=2&scale=3", 4},
+		{"?from=2&to=3", 2},
+		{"?step=10", 2},
+		{"?every=1h", 2},
+	} {
+		resp, err := http.Get(srv.URL + "/timelapse.gif" + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anim, err := gif.DecodeAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: decoding GIF: %v", tc.query, err)
+		}
+		if len(anim.Image) != tc.frames {
+			t.Errorf("%s: got %d frames, want %d", tc.query, len(anim.Image), tc.frames)
+		}
+	}
+}
+
+func TestTimelapseRejectsBadRanges(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.EventLogPath = filepath.Join(t.TempDir(), "events.jsonl")
+	})
+	for _, query := range []string{"?step=0", "?from=x", "?every=-1s", "?from=3&to=1"} {
+		resp, err := http.Get(srv.URL + "/timelapse.gif" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
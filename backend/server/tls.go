@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// TLSFiles returns the configured certificate and key paths. Both are empty
+// when the server should serve plain HTTP.
+func TLSFiles() (cert, key string) {
+	return config.TLSCert, config.TLSKey
+}
+
+// RedirectAddr returns the address for the plain HTTP listener that
+// redirects to HTTPS, or "" if there should be none.
+func RedirectAddr() string {
+	return config.HTTPRedirectAddr
+}
+
+// RedirectHTTPS redirects every request to the same URL over HTTPS on the
+// server's listen port.
+func RedirectHTTPS() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if _, port, err := net.SplitHostPort(config.Addr); err == nil && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeSelfSignedCert writes a certificate for 127.0.0.1 and its key to
+// dir and returns their paths along with the certificate.
+func writeSelfSignedCert(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rplace test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath, cert
+}
+
+func TestWebSocketOverTLS(t *testing.T) {
+	certPath, keyPath, cert := writeSelfSignedCert(t, t.TempDir())
+	startServer(t, func(cfg *Config) { cfg.TLSCert, cfg.TLSKey = certPath, keyPath })
+
+	// Served as main.go does when TLS is configured.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: newTestRouter()}
+	certFile, keyFile := TLSFiles()
+	go srv.ServeTLS(ln, certFile, keyFile)
+	t.Cleanup(func() { srv.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: roots}, HandshakeTimeout: readTimeout}
+	conn, _, err := dialer.Dial("wss://"+ln.Addr().String()+"/ws?username=secure", nil)
+	if err != nil {
+		t.Fatalf("wss handshake: %v", err)
+	}
+	defer conn.Close()
+	readType(t, conn, "init", &InitBoardState{})
+	place(t, conn, 0, 0, "#000000")
+}
+
+func TestConfigureRequiresCertAndKey(t *testing.T) {
+	savedConfig, savedRooms, savedHub := config, rooms, HubInstance
+	t.Cleanup(func() { config, rooms, HubInstance = savedConfig, savedRooms, savedHub })
+	if err := Configure(testConfig(t, func(cfg *Config) { cfg.TLSCert = "cert.pem" })); err == nil {
+		t.Fatal("a certificate without a key was accepted")
+	}
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	for _, tc := range []struct{ addr, want string }{
+		{":8443", "https://example.com:8443/board?room=a"},
+		{":443", "https://example.com/board?room=a"},
+	} {
+		useConfig(t, func(cfg *Config) { cfg.Addr = tc.addr })
+		rec := httptest.NewRecorder()
+		RedirectHTTPS().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com:8080/board?room=a", nil))
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tc.want {
+			t.Errorf("listening on %s redirected with %d to %q, want %q", tc.addr, rec.Code, rec.Header().Get("Location"), tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// trustedUsers are the JWT subjects that bypass the placement cooldown. It
+// starts from config.TrustedUsers and can be replaced at runtime by admins.
+// ids holds the identity each subject places pixels as.
+var trustedUsers = struct {
+	mu    sync.RWMutex
+	names map[string]bool
+	ids   map[uuid.UUID]bool
+}{names: map[string]bool{}, ids: map[uuid.UUID]bool{}}
+
+func setTrustedUsers(subjects []string) {
+	names := make(map[string]bool, len(subjects))
+	ids := make(map[uuid.UUID]bool, len(subjects))
+	for _, subject := range subjects {
+		names[subject] = true
+		ids[subjectID(subject)] = true
+	}
+	trustedUsers.mu.Lock()
+	trustedUsers.names = names
+	trustedUsers.ids = ids
+	trustedUsers.mu.Unlock()
+}
+
+// isTrusted reports whether id belongs to a trusted JWT subject. Without
+// JWT auth identities are not authenticated, so nobody is trusted.
+func isTrusted(id uuid.UUID) bool {
+	if !authEnabled() {
+		return false
+	}
+	trustedUsers.mu.RLock()
+	defer trustedUsers.mu.RUnlock()
+	return trustedUsers.ids[id]
+}
+
+func trustedList() []string {
+	trustedUsers.mu.RLock()
+	defer trustedUsers.mu.RUnlock()
+	names := make([]string, 0, len(trustedUsers.names))
+	for name := range trustedUsers.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+type TrustedUsersRequest struct {
+	Users []string `json:"users" binding:"required"`
+}
+
+func GetTrustedUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"users": trustedList()})
+	}
+}
+
+// SetTrustedUsers replaces the JWT subjects that bypass the cooldown.
+func SetTrustedUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TrustedUsersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setTrustedUsers(req.Users)
+		logger.Info("Trusted users updated", "users", len(req.Users), "ip", c.ClientIP())
+		c.JSON(http.StatusOK, gin.H{"users": trustedList()})
+	}
+}
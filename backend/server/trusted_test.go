@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTrustedUsersBypassCooldown(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		useJWT(cfg)
+		cfg.Cooldown = time.Minute
+		cfg.TrustedUsers = []string{"mod"}
+	})
+	mod := dial(t, srv, "token="+signToken(t, "mod", "mod", time.Hour))
+	user := dial(t, srv, "token="+signToken(t, "user-1", "user", time.Hour))
+
+	for x := range 5 {
+		place(t, mod, x, 0, "#000000")
+	}
+	place(t, user, 0, 1, "#000000")
+	if reply := request(t, user, map[string]any{"type": "update", "x": 1, "y": 1, "color": "#000000"}); reply.Code != "cooldown" {
+		t.Fatalf("untrusted user's second placement got %s %q, want nack cooldown", reply.Type, reply.Code)
+	}
+
+	// Replacing the list takes effect without a restart.
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/admin/trusted", bytes.NewReader([]byte(`{"users":["user-1"]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = adminHeader()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /admin/trusted: status %d", resp.StatusCode)
+	}
+
+	for x := 2; x < 5; x++ {
+		place(t, user, x, 1, "#000000")
+	}
+	// Its earlier placements still count towards the cooldown.
+	if reply := request(t, mod, map[string]any{"type": "update", "x": 0, "y": 2, "color": "#000000"}); reply.Code != "cooldown" {
+		t.Fatalf("removed moderator's placement got %s %q, want nack cooldown", reply.Type, reply.Code)
+	}
+}
+
+func TestTrustedUsersNeedAuth(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.TrustedUsers = []string{"mod"} })
+	setTrustedUsers(config.TrustedUsers)
+	t.Cleanup(func() { setTrustedUsers(nil) })
+	if isTrusted(subjectID("mod")) {
+		t.Fatal("trusted without JWT auth, where subjects are not verified")
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errNothingToUndo   = errors.New("nothing to undo")
+	errUndoOverwritten = errors.New("pixel has been overwritten since")
+)
+
+// placement remembers a client's latest pixel and what it replaced, so the
+// client can undo it.
+type placement struct {
+	X        int
+	Y        int
+	PlacedAt time.Time
+	Previous Pixel
+	Owner    PixelMeta
+}
+
+// undo reverts the sender's latest placement to the pixel it replaced. Only
+// one placement can be undone, and only while nobody has painted over it.
+// It is only called from the hub's Run loop.
+func (h *Hub) undo(message Update) (Update, error) {
+	if consensusEnabled() {
+		return message, errConsensusOnly
+	}
+	last, ok := h.placements[message.SenderUUID]
+	if !ok {
+		return message, errNothingToUndo
+	}
+	message.X, message.Y = last.X, last.Y
+	if _, meta := h.store.Get(last.X, last.Y); meta.UUID != message.SenderUUID || !meta.PlacedAt.Equal(last.PlacedAt) {
+		delete(h.placements, message.SenderUUID)
+		return message, errUndoOverwritten
+	}
+	if h.locked(last.X, last.Y) {
+		return message, errRegionLocked
+	}
+
+	message.Type = "update"
+	message.Pixel = last.Previous
+	message.Ts = h.clock()
+	message, err := h.write(message, last.Owner)
+	if err != nil {
+		return message, err
+	}
+	delete(h.placements, message.SenderUUID)
+	logger.Info("Placement undone", "uuid", message.SenderUUID, "x", message.X, "y", message.Y)
+	return message, nil
+}
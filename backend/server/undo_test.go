@@ -0,0 +1,52 @@
+package server
+
+import "testing"
+
+func TestUndo(t *testing.T) {
+	srv := startServer(t, nil)
+	a := dial(t, srv, "username=a")
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+
+	place(t, a, 3, 3, "#00cc78")
+	place(t, a, 3, 3, "#ff4500")
+	if reply := request(t, a, map[string]any{"type": "undo"}); reply.Type != "ack" {
+		t.Fatalf("undo was refused: %s", reply.Message)
+	}
+
+	green := Pixel{G: 0xcc, B: 0x78, A: 255}
+	var update Update
+	for range 3 {
+		readType(t, watcher, "update", &update)
+	}
+	if update.X != 3 || update.Y != 3 || update.Pixel != green {
+		t.Fatalf("watcher got %+v for the undo, want green at (3, 3)", update)
+	}
+	var info PixelInfo
+	getJSON(t, srv, "/pixel?x=3&y=3", &info)
+	if info.Pixel != green || info.Owner == nil || info.Owner.Username != "a" {
+		t.Fatalf("pixel after undo is %+v", info)
+	}
+
+	if reply := request(t, a, map[string]any{"type": "undo"}); reply.Message != errNothingToUndo.Error() {
+		t.Fatalf("second undo got %q, want %q", reply.Message, errNothingToUndo)
+	}
+}
+
+func TestUndoAfterOverwrite(t *testing.T) {
+	srv := startServer(t, nil)
+	a := dial(t, srv, "username=a")
+	b := dial(t, srv, "username=b")
+
+	place(t, a, 5, 5, "#ff4500")
+	place(t, b, 5, 5, "#2450a4")
+	if reply := request(t, a, map[string]any{"type": "undo"}); reply.Message != errUndoOverwritten.Error() {
+		t.Fatalf("undo got %q, want %q", reply.Message, errUndoOverwritten)
+	}
+
+	var info PixelInfo
+	getJSON(t, srv, "/pixel?x=5&y=5", &info)
+	if info.Pixel != (Pixel{R: 0x24, G: 0x50, B: 0xa4, A: 255}) || info.Owner.Username != "b" {
+		t.Fatalf("refused undo changed the pixel to %+v", info)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// admission describes a connection allowed to upgrade to a websocket.
+type admission struct {
+	id       uuid.UUID
+	username string
+	readOnly bool
+}
+
+// upgradeError is why a websocket upgrade was refused, along with the HTTP
+// status to refuse it with.
+type upgradeError struct {
+	status int
+	reason string
+}
+
+func (e *upgradeError) Error() string {
+	return e.reason
+}
+
+// admitUpgrade decides whether r may upgrade to a websocket and as whom.
+// The origin must be allowed. A bot's API key identifies it as the bot,
+// with or without JWT auth. A valid identity token reclaims the id and,
+// unless another is given, the username of an earlier connection. When JWT
+// auth is enabled, a token must be valid and names the user; without one
+// the connection is read-only.
+func admitUpgrade(r *http.Request) (admission, error) {
+	if !checkOrigin(r) {
+		return admission{}, &upgradeError{status: http.StatusForbidden, reason: "origin not allowed"}
+	}
+	bot, ok, err := botFor(r)
+	if err != nil {
+		return admission{}, &upgradeError{status: http.StatusUnauthorized, reason: err.Error()}
+	}
+	if ok {
+		return admission{id: bot.id, username: bot.name}, nil
+	}
+	username := r.URL.Query().Get("username")
+	adm := admission{id: uuid.New()}
+	// An invalid or expired identity token just gets the client a new id.
+	if token := identityToken(r); token != "" {
+		if id, name, err := parseIdentity(token); err == nil {
+			adm.id = id
+			if username == "" {
+				username = name
+			}
+		}
+	}
+	adm.username = sanitizeUsername(username)
+	if !authEnabled() {
+		return adm, nil
+	}
+	token := bearerToken(r)
+	if token == "" {
+		adm.readOnly = true
+		return adm, nil
+	}
+	claims, err := parseToken(token)
+	if err != nil {
+		return admission{}, &upgradeError{status: http.StatusUnauthorized, reason: "invalid token: " + err.Error()}
+	}
+	adm.id, adm.username = claims.id(), claims.username()
+	return adm, nil
+}
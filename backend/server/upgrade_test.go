@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdmitUpgrade(t *testing.T) {
+	useConfig(t, func(cfg *Config) {
+		cfg.AllowedOrigins = []string{"https://place.example"}
+		useJWT(cfg)
+	})
+	valid := signToken(t, "user-1", "alice", time.Hour)
+	expired := signToken(t, "user-1", "alice", -time.Minute)
+
+	for _, tc := range []struct {
+		name     string
+		origin   string
+		query    string
+		status   int
+		readOnly bool
+	}{
+		{"no origin", "", "", 0, true},
+		{"same host", "http://example.com", "", 0, true},
+		{"allowed origin", "https://place.example", "", 0, true},
+		{"denied origin", "https://evil.example", "token=" + valid, http.StatusForbidden, false},
+		{"valid token", "", "token=" + valid, 0, false},
+		{"expired token", "", "token=" + expired, http.StatusUnauthorized, false},
+		{"garbage token", "", "token=garbage", http.StatusUnauthorized, false},
+		{"unknown API key", "", "apikey=nope", http.StatusUnauthorized, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/ws?"+tc.query, nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			adm, err := admitUpgrade(r)
+			if tc.status != 0 {
+				var refused *upgradeError
+				if !errors.As(err, &refused) || refused.status != tc.status || refused.reason == "" {
+					t.Fatalf("got %+v, %v; want a refusal with status %d", adm, err, tc.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("refused: %v", err)
+			}
+			if adm.readOnly != tc.readOnly {
+				t.Errorf("read-only is %v, want %v", adm.readOnly, tc.readOnly)
+			}
+		})
+	}
+
+	t.Run("token names the user", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/ws?username=impostor", nil)
+		r.Header.Set("Authorization", "Bearer "+valid)
+		adm, err := admitUpgrade(r)
+		if err != nil {
+			t.Fatalf("refused: %v", err)
+		}
+		if adm.username != "alice" || adm.id != subjectID("user-1") {
+			t.Fatalf("admitted as %q (%v), want alice", adm.username, adm.id)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxUsernameLength = 32
+	defaultUsername   = "anonymous"
+)
+
+// sanitizeUsername strips non-printable characters, trims surrounding
+// whitespace and caps the length of a client supplied name. Names that end up
+// empty become defaultUsername.
+func sanitizeUsername(raw string) string {
+	name := strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, raw)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxUsernameLength {
+		name = strings.TrimSpace(string(runes[:maxUsernameLength]))
+	}
+	if name == "" {
+		return defaultUsername
+	}
+	return name
+}
+
+var errNameLocked = errors.New("names come from sign-in and cannot be changed")
+
+// availableName returns name, or with config.UsernameCollision "suffix"
+// the first free name#N, if another client has it. The caller must hold
+// h.mu.
+func (h *Hub) availableName(name string) (string, error) {
+	if _, taken := h.names[name]; !taken {
+		return name, nil
+	}
+	if config.UsernameCollision == "reject" {
+		return "", errUsernameTaken
+	}
+	for n := 2; ; n++ {
+		candidate := name + "#" + strconv.Itoa(n)
+		if _, taken := h.names[candidate]; !taken {
+			return candidate, nil
+		}
+	}
+}
+
+// rename changes the display name of client and tells every client,
+// including it. Its identity, and so its cooldown, stays the same. With JWT
+// auth, names come from tokens and cannot be changed, and bots keep the
+// name of their API key. It is only called from the hub's Run loop.
+func (h *Hub) rename(client *Client, raw string) error {
+	if authEnabled() || isBot(client.uuid) {
+		return errNameLocked
+	}
+	name := sanitizeUsername(raw)
+	h.mu.Lock()
+	if name == client.Username {
+		h.mu.Unlock()
+		return nil
+	}
+	name, err := h.availableName(name)
+	if err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	if h.names[client.Username] == client.uuid {
+		delete(h.names, client.Username)
+	}
+	old := client.Username
+	client.Username = name
+	h.names[name] = client.uuid
+	h.mu.Unlock()
+
+	logger.Info("Client renamed", "uuid", client.uuid, "from", old, "to", name)
+	h.broadcastMessage(PresenceMessage{
+		Type:     "presence",
+		Event:    "rename",
+		ID:       client.publicID,
+		Username: name,
+	}, uuid.Nil)
+	return nil
+}
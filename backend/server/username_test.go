@@ -0,0 +1,109 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeUsername(t *testing.T) {
+	for _, tc := range []struct {
+		name, raw, want string
+	}{
+		{"plain", "alice", "alice"},
+		{"empty", "", defaultUsername},
+		{"blank", "   ", defaultUsername},
+		{"only control characters", "\x00\x07\x1b", defaultUsername},
+		{"control characters", "al\x00i\nce\x7f", "alice"},
+		{"surrounding space", "  bob  ", "bob"},
+		{"oversized", strings.Repeat("x", 100), strings.Repeat("x", maxUsernameLength)},
+		{"oversized runes", strings.Repeat("é", 40), strings.Repeat("é", maxUsernameLength)},
+		{"space at the cut", strings.Repeat("y", maxUsernameLength-1) + " z", strings.Repeat("y", maxUsernameLength-1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitizeUsername(tc.raw); got != tc.want {
+				t.Fatalf("sanitizeUsername(%q) = %q, want %q", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUsernameCollision(t *testing.T) {
+	t.Run("suffix", func(t *testing.T) {
+		srv := startServer(t, func(cfg *Config) { cfg.UsernameCollision = "suffix" })
+		for range 3 {
+			readType(t, dial(t, srv, "username=alice"), "init", &InitBoardState{})
+		}
+		var users []UserInfo
+		getJSON(t, srv, "/users", &users)
+		var names []string
+		for _, u := range users {
+			names = append(names, u.Username)
+		}
+		if strings.Join(names, ",") != "alice,alice#2,alice#3" {
+			t.Fatalf("users are %v", names)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		srv := startServer(t, func(cfg *Config) { cfg.UsernameCollision = "reject" })
+		readType(t, dial(t, srv, "username=alice"), "init", &InitBoardState{})
+
+		second := dial(t, srv, "username=alice")
+		var reply ErrorMessage
+		readType(t, second, "error", &reply)
+		if reply.Code != "username_taken" {
+			t.Fatalf("got error %q, want username_taken", reply.Code)
+		}
+		if code := readClose(t, second); code != closeUsernameTaken {
+			t.Fatalf("closed with %d, want %d", code, closeUsernameTaken)
+		}
+	})
+}
+
+func TestSetName(t *testing.T) {
+	srv := startServer(t, nil)
+	conn := dial(t, srv, "username=a")
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+	var before []UserInfo
+	getJSON(t, srv, "/users", &before)
+	id := clientNamed(t, HubInstance, "a").uuid
+
+	send(t, conn, map[string]any{"type": "set_name", "username": " b\x00ob "})
+	var presence PresenceMessage
+	readType(t, watcher, "presence", &presence)
+	if presence.Event != "rename" || presence.Username != "bob" {
+		t.Fatalf("watcher got %+v", presence)
+	}
+
+	var after []UserInfo
+	getJSON(t, srv, "/users", &after)
+	if len(after) != 2 || after[0].Username != "bob" || after[0].ID != before[0].ID {
+		t.Fatalf("/users went from %+v to %+v", before, after)
+	}
+	if client := clientNamed(t, HubInstance, "bob"); client.uuid != id {
+		t.Fatalf("renaming changed the identity from %v to %v", id, client.uuid)
+	}
+
+	place(t, conn, 1, 1, "#000000")
+	var update Update
+	readType(t, watcher, "update", &update)
+	if update.SenderName != "bob" {
+		t.Fatalf("placement after renaming is from %q", update.SenderName)
+	}
+}
+
+func TestSetNameLockedByAuth(t *testing.T) {
+	srv := startServer(t, useJWT)
+	conn := dial(t, srv, "token="+signToken(t, "user-1", "alice", time.Hour))
+	readType(t, conn, "init", &InitBoardState{})
+
+	send(t, conn, map[string]any{"type": "set_name", "username": "mallory"})
+	var reply ErrorMessage
+	readType(t, conn, "error", &reply)
+	if reply.Code != "name_locked" {
+		t.Fatalf("got %+v, want name_locked", reply)
+	}
+	clientNamed(t, HubInstance, "alice")
+}
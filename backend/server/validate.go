@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	errMissingField = errors.New("message is missing a required field")
+	errUnknownField = errors.New("message has an unknown field")
+)
+
+// requiredFields lists the fields each kind of placement must carry, so an
+// absent coordinate is not taken for 0. Placements also need a pixel or a
+// color.
+var requiredFields = map[string][]string{
+	"update":    {"x", "y"},
+	"fill":      {"x", "y"},
+	"draw_rect": {"x", "y", "width", "height"},
+	"draw_line": {"x", "y", "x2", "y2"},
+}
+
+// knownFields are the fields a placement may carry when
+// config.StrictMessages is set.
+var knownFields = map[string]bool{
+	"type": true, "x": true, "y": true, "pixel": true, "color": true,
+	"width": true, "height": true, "x2": true, "y2": true, "reqId": true,
+}
+
+// checkFields checks that the placement in data of type typ has the fields
+// it needs and, with config.StrictMessages, no others. Types other than the
+// operations are placed as updates.
+func checkFields(data []byte, typ string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if config.StrictMessages {
+		for name := range fields {
+			if !knownFields[name] {
+				return fmt.Errorf("%w: %s", errUnknownField, name)
+			}
+		}
+	}
+	if typ == "undo" {
+		return nil
+	}
+	required, ok := requiredFields[typ]
+	if !ok {
+		required = requiredFields["update"]
+	}
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("%w: %s", errMissingField, name)
+		}
+	}
+	_, hasPixel := fields["pixel"]
+	_, hasColor := fields["color"]
+	if !hasPixel && !hasColor {
+		return fmt.Errorf("%w: pixel or color", errMissingField)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckFields(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		data   string
+		typ    string
+		strict bool
+		want   error
+	}{
+		{"complete", `{"type":"update","x":0,"y":0,"color":"#000000"}`, "update", false, nil},
+		{"pixel instead of color", `{"type":"update","x":1,"y":2,"pixel":{"r":0,"g":0,"b":0,"a":255}}`, "update", false, nil},
+		{"missing x", `{"type":"update","y":0,"color":"#000000"}`, "update", false, errMissingField},
+		{"missing color", `{"type":"update","x":0,"y":0}`, "update", false, errMissingField},
+		{"untyped as update", `{"x":0,"color":"#000000"}`, "", false, errMissingField},
+		{"rect missing height", `{"type":"draw_rect","x":0,"y":0,"width":2,"color":"#000000"}`, "draw_rect", false, errMissingField},
+		{"undo", `{"type":"undo"}`, "undo", false, nil},
+		{"unknown field", `{"type":"update","x":0,"y":0,"color":"#000000","z":1}`, "update", false, nil},
+		{"unknown field strictly", `{"type":"update","x":0,"y":0,"color":"#000000","z":1}`, "update", true, errUnknownField},
+		{"known fields strictly", `{"type":"update","x":0,"y":0,"color":"#000000","reqId":"1"}`, "update", true, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			useConfig(t, func(cfg *Config) { cfg.StrictMessages = tc.strict })
+			if err := checkFields([]byte(tc.data), tc.typ); !errors.Is(err, tc.want) {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMissingFieldDoesNotPaint(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.StrictMessages = true })
+	conn := dial(t, srv, "username=a")
+
+	for _, tc := range []struct {
+		message map[string]any
+		code    string
+	}{
+		{map[string]any{"type": "update", "y": 0, "color": "#000000"}, "missing_field"},
+		{map[string]any{"type": "update", "x": 0, "color": "#000000"}, "missing_field"},
+		{map[string]any{"type": "update", "x": 0, "y": 0}, "missing_field"},
+		{map[string]any{"type": "update", "x": 0, "y": 0, "color": "#000000", "colour": "#ffffff"}, "unknown_field"},
+	} {
+		if reply := request(t, conn, tc.message); reply.Type != "nack" || reply.Code != tc.code {
+			t.Errorf("%v got %s %q, want nack %s", tc.message, reply.Type, reply.Code, tc.code)
+		}
+	}
+	if p, _ := HubInstance.store.Get(0, 0); p != config.FillColor {
+		t.Fatalf("(0, 0) was painted %v", p)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import "errors"
+
+var errBadViewport = errors.New("viewport must lie inside the board")
+
+// SubscribeMessage sets the viewport of the sending client. From then on it
+// only receives updates inside the region. A message without a region
+// clears the viewport, so the client receives every update again.
+type SubscribeMessage struct {
+	Type   string  `json:"type"`
+	Region *Region `json:"region"`
+}
+
+// RegionMessage carries the pixels inside a client's new viewport, row by
+// row from its top left corner.
+type RegionMessage struct {
+	Type   string    `json:"type"`
+	Seq    uint64    `json:"seq"`
+	Region Region    `json:"region"`
+	Pixels [][]Pixel `json:"pixels"`
+}
+
+// subscribe sets client's viewport and sends it the pixels inside. It is
+// only called from the hub's Run loop.
+func (h *Hub) subscribe(client *Client, region *Region) {
+	if region == nil {
+		client.viewport = nil
+		return
+	}
+	width, height := h.store.Size()
+	// Subtracted rather than added, so huge sizes cannot overflow past
+	// the check.
+	if region.Width < 1 || region.Height < 1 || region.X < 0 || region.Y < 0 ||
+		region.Width > width-region.X || region.Height > height-region.Y {
+		h.sendTo(client.uuid, newErrorMessage(errBadViewport))
+		return
+	}
+
+	client.viewport = region
+	pixels := h.store.Snapshot()
+	rows := make([][]Pixel, region.Height)
+	for i := range rows {
+		rows[i] = pixels[region.Y+i][region.X : region.X+region.Width]
+	}
+	h.sendTo(client.uuid, RegionMessage{
+		Type:   "region",
+		Seq:    h.changes.seq(),
+		Region: *region,
+		Pixels: rows,
+	})
+}
+
+// visible trims message to the client's viewport. It reports false if
+// nothing is left to send. It is only called from the hub's Run loop.
+func (c *Client) visible(message interface{}) (interface{}, bool) {
+	if c.viewport == nil {
+		return message, true
+	}
+	switch m := message.(type) {
+	case Update:
+		return m, c.viewport.Contains(m.X, m.Y)
+	case BatchMessage:
+		var updates []Update
+		for _, u := range m.Updates {
+			if c.viewport.Contains(u.X, u.Y) {
+				updates = append(updates, u)
+			}
+		}
+		m.Updates = updates
+		return m, len(updates) > 0
+	case CursorMessage:
+		return m, c.viewport.Contains(m.X, m.Y)
+	case ChecksumMessage:
+		// The checksum covers the whole board, which the client lacks.
+		return m, false
+	}
+	return message, true
+}
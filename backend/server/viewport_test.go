@@ -0,0 +1,79 @@
+package server
+
+import (
+	"math"
+	"testing"
+)
+
+func TestViewportFiltersUpdates(t *testing.T) {
+	srv := startServer(t, nil)
+	painter := dial(t, srv, "username=painter")
+	place(t, painter, 2, 1, "#ff4500")
+
+	viewer := dial(t, srv, "username=viewer")
+	readType(t, viewer, "init", &InitBoardState{})
+	send(t, viewer, SubscribeMessage{Type: "subscribe", Region: &Region{X: 1, Y: 1, Width: 4, Height: 3}})
+	var region RegionMessage
+	readType(t, viewer, "region", &region)
+	if len(region.Pixels) != 3 || len(region.Pixels[0]) != 4 {
+		t.Fatalf("region is %dx%d, want 4x3", len(region.Pixels[0]), len(region.Pixels))
+	}
+	if got, want := region.Pixels[0][1], (Pixel{R: 0xff, G: 0x45, A: 255}); got != want {
+		t.Fatalf("region has %v at (2, 1), want %v", got, want)
+	}
+
+	// Updates arrive in order, so the first one must be the one inside.
+	place(t, painter, 10, 10, "#000000")
+	place(t, painter, 4, 3, "#000000")
+	var update Update
+	readType(t, viewer, "update", &update)
+	if update.X != 4 || update.Y != 3 {
+		t.Fatalf("viewer got the update at (%d, %d), outside its viewport", update.X, update.Y)
+	}
+
+	send(t, viewer, SubscribeMessage{Type: "subscribe"})
+	// The clearing subscribe has been handled once this update arrives.
+	place(t, viewer, 0, 0, "#000000")
+	place(t, painter, 12, 12, "#000000")
+	// It arriving at all shows the viewport is gone.
+	for update.X != 12 {
+		readType(t, viewer, "update", &update)
+	}
+}
+
+func TestViewportOutsideBoard(t *testing.T) {
+	srv := startServer(t, nil)
+	viewer := dial(t, srv, "username=viewer")
+	for _, region := range []Region{
+		{X: 10, Y: 0, Width: 10, Height: 4},
+		{X: 1, Y: 0, Width: math.MaxInt, Height: 1},
+		{X: 0, Y: 1, Width: 1, Height: math.MaxInt},
+	} {
+		send(t, viewer, SubscribeMessage{Type: "subscribe", Region: &region})
+		var reply ErrorMessage
+		readType(t, viewer, "error", &reply)
+		if reply.Message != errBadViewport.Error() {
+			t.Fatalf("%+v: got error %q, want %q", region, reply.Message, errBadViewport)
+		}
+	}
+	// The Run loop survived them.
+	place(t, viewer, 0, 0, "#000000")
+}
+
+func TestVisibleTrimsBatches(t *testing.T) {
+	client := &Client{viewport: &Region{X: 0, Y: 0, Width: 2, Height: 2}}
+	batch := BatchMessage{Type: "batch", Updates: []Update{{X: 0, Y: 0}, {X: 5, Y: 5}, {X: 1, Y: 1}}}
+	message, ok := client.visible(batch)
+	if !ok {
+		t.Fatal("batch with updates inside the viewport was dropped")
+	}
+	if updates := message.(BatchMessage).Updates; len(updates) != 2 || updates[1].X != 1 {
+		t.Fatalf("trimmed batch is %+v", updates)
+	}
+	if _, ok := client.visible(BatchMessage{Type: "batch", Updates: []Update{{X: 5, Y: 5}}}); ok {
+		t.Fatal("batch with nothing inside the viewport was sent")
+	}
+	if len(batch.Updates) != 3 {
+		t.Fatal("trimming modified the batch shared with other clients")
+	}
+}
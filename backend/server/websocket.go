@@ -1,7 +1,11 @@
 package server
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,116 +13,768 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// StartHub starts the Run loop of every room's hub in the background.
+func StartHub() {
+	for _, h := range rooms {
+		go h.Run()
+	}
+}
+
 func (h *Hub) Run() {
+	defer close(h.done)
+	h.running.Store(true)
+	defer h.running.Store(false)
+
+	// flush fires once the current batch window closes; nil while no batch is pending.
+	var flush <-chan time.Time
+	// reap fires periodically to drop idle clients; nil when disabled.
+	var reap <-chan time.Time
+	if config.IdleTimeout > 0 {
+		ticker := time.NewTicker(config.IdleTimeout / 2)
+		defer ticker.Stop()
+		reap = ticker.C
+	}
+	// checksum fires periodically to send the board checksum; nil when disabled.
+	var checksum <-chan time.Time
+	if config.ChecksumInterval > 0 {
+		ticker := time.NewTicker(config.ChecksumInterval)
+		defer ticker.Stop()
+		checksum = ticker.C
+	}
+	// schedule fires when the canvas opens or closes; nil once it no longer
+	// changes.
+	var schedule <-chan time.Time
+	if d, ok := nextTransition(h.clock()); ok {
+		schedule = time.After(d)
+	}
+	// Milestones the restored board had already reached are not announced
+	// again.
+	h.milestone = h.reached()
+	// remote delivers updates placed on other instances sharing the store.
+	var remote <-chan Update
+	if rs, ok := h.store.(remoteStore); ok {
+		remote = rs.Updates()
+	}
 
-	board.InitBoard()
 	for {
 		select {
+		case <-h.quit:
+			h.closeAll()
+			return
 		case client := <-h.register:
-			log.Printf("DEBUG: Registering client %s (%s)", client.Username, client.uuid)
-			h.mu.Lock()
-			h.clients[client.uuid] = client
-			h.mu.Unlock()
-			log.Printf("Client connected: %s (%s)", client.Username, client.uuid)
+			logger.Debug("Registering client", "username", client.Username, "uuid", client.uuid)
+			// A client reconnecting with its identity takes over from its
+			// old connection.
+			if old, ok := h.clients[client.uuid]; ok {
+				logger.Info("Replacing connection of reconnected client", "uuid", client.uuid)
+				h.disconnect(old)
+			}
+			var err error
+			if isBanned(client.uuid, client.Username, client.ip) {
+				err = errBanned
+			} else if config.MaxClients > 0 && totalClients() >= config.MaxClients {
+				err = errServerFull
+			} else if config.MaxConnectionsPerIP > 0 && ipConnections(client.ip) >= config.MaxConnectionsPerIP {
+				err = errTooManyConns
+			} else {
+				err = h.addClient(client)
+			}
+			if err == nil {
+				// Counted on the Run loop, which has stopped before
+				// Shutdown waits, so no Add can race with the Wait.
+				h.writers.Add(1)
+			}
+			client.registered <- err
+			if err != nil {
+				logger.Info("Client rejected", "username", client.Username, "uuid", client.uuid, "error", err)
+				continue
+			}
+			clientsConnected.Inc()
+			logger.Info("Client connected", "username", client.Username, "uuid", client.uuid, "room", h.name, "spectator", client.spectator)
+			if client.spectator {
+				continue
+			}
+			h.broadcastMessage(PresenceMessage{
+				Type:     "presence",
+				Event:    "join",
+				ID:       client.publicID,
+				Username: client.Username,
+			}, client.uuid)
 		case client := <-h.unregister:
-			log.Printf("DEBUG: Unregistering client %s (%s)", client.Username, client.uuid)
-			h.mu.Lock()
-			if _, ok := h.clients[client.uuid]; ok {
-				delete(h.clients, client.uuid)
-				close(client.Send)
-				log.Printf("Client disconnected: %s (%s)", client.Username, client.uuid)
-			}
-			h.mu.Unlock()
+			logger.Debug("Unregistering client", "username", client.Username, "uuid", client.uuid)
+			h.disconnect(client)
 		case message := <-h.broadcast:
-			log.Printf("DEBUG: Broadcasting message from %s: %+v", message.SenderUUID, message)
-
-			h.mu.RLock()
-			for uuid, client := range h.clients {
-				if uuid != message.SenderUUID {
-					select {
-					case client.Send <- message:
-						log.Printf("DEBUG: Sent message to client %s", client.uuid)
-					default:
-						log.Printf("DEBUG: Client %s send channel blocked, unregistering", client.uuid)
-						go func(c *Client) {
-							h.unregister <- c
-						}(client)
-					}
+			logger.Debug("Broadcasting message", "uuid", message.SenderUUID, "message", message)
+			message = h.resolveSender(message)
+			var blocked error
+			if frozen.Load() {
+				blocked = errFrozen
+			} else if isBanned(message.SenderUUID, message.SenderName, "") {
+				blocked = errBanned
+			} else if !canvasOpen(h.clock()) {
+				blocked = errCanvasClosed
+			}
+			if blocked != nil {
+				if message.result != nil {
+					message.result <- placeResult{Update: message, Err: blocked}
+				}
+				h.reject(message, blocked)
+				continue
+			}
+
+			if message.Type == "fill" || message.Type == "draw_rect" || message.Type == "draw_line" {
+				var updates []Update
+				var err error
+				if message.Type == "fill" {
+					updates, err = h.fill(message)
+				} else {
+					updates, err = h.draw(message)
+				}
+				if h.publishAll(updates) {
+					flush = time.After(config.BatchWindow)
+				}
+				if err != nil {
+					h.reject(message, err)
+				} else if len(updates) > 0 {
+					h.ack(message, updates[len(updates)-1].Seq)
+				} else {
+					h.ack(message, h.changes.seq())
+				}
+				h.checkMilestones()
+				continue
+			}
+
+			// The sender already shows its own pixel, but not what an undo
+			// reverts it to.
+			except := message.SenderUUID
+			var applied Update
+			var err error
+			if message.Type == "undo" {
+				applied, err = h.undo(message)
+				except = uuid.Nil
+			} else {
+				applied, err = h.applyUpdate(message)
+			}
+			if message.result != nil {
+				message.result <- placeResult{Update: applied, Err: err}
+			}
+			if err != nil {
+				var pending *voteError
+				if errors.As(err, &pending) {
+					h.sendVote(message, pending.votes)
+				} else if message.Type == "undo" || !h.queueIntent(message, err) {
+					h.reject(message, err)
 				}
+				continue
+			}
+			// A placement that went through supersedes a queued one.
+			h.dropIntent(message.SenderUUID)
+			h.ack(message, applied.Seq)
+			message = applied
+			if h.publish(message, except) {
+				flush = time.After(config.BatchWindow)
+			}
+			h.checkMilestones()
+		case message, ok := <-remote:
+			if !ok {
+				remote = nil
+				continue
+			}
+			if message.Type == "reset" {
+				logger.Info("Board reset by another instance", "room", h.name)
+				h.resetClients()
+				continue
+			}
+			logger.Debug("Received remote update", "uuid", message.SenderUUID, "message", message)
+			message = h.changes.append(message)
+			h.record(Event{
+				Seq:      message.Seq,
+				X:        message.X,
+				Y:        message.Y,
+				Pixel:    message.Pixel,
+				Username: message.SenderName,
+				Ts:       message.Ts,
+			})
+			if h.publish(message, uuid.Nil) {
+				flush = time.After(config.BatchWindow)
 			}
-			h.mu.RUnlock()
+			h.checkMilestones()
+		case fn := <-h.commands:
+			fn()
+			h.checkMilestones()
+		case <-reap:
+			h.reapIdle(h.clock())
+		case <-schedule:
+			schedule = nil
+			now := h.clock()
+			if d, ok := nextTransition(now); ok {
+				schedule = time.After(d)
+			}
+			h.flushBatch()
+			h.broadcastMessage(canvasMessage(now), uuid.Nil)
+			logger.Info("Canvas schedule changed", "room", h.name, "open", canvasOpen(now))
+		case <-checksum:
+			h.flushBatch()
+			h.broadcastChecksum()
+		case <-flush:
+			flush = nil
+			h.flushBatch()
+		}
+	}
+}
+
+// addClient adds client to the hub, resolving username collisions according
+// to the configured policy.
+func (h *Hub) addClient(client *Client) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.spectator {
+		// Spectators are not listed, so their names cannot collide.
+		h.clients[client.uuid] = client
+		h.roster.add(client)
+		h.ips[client.ip]++
+		return nil
+	}
+	name, err := h.availableName(client.Username)
+	if err != nil {
+		return err
+	}
+	client.Username = name
+	h.names[client.Username] = client.uuid
+	h.clients[client.uuid] = client
+	h.roster.add(client)
+	h.ips[client.ip]++
+	return nil
+}
+
+// removeClient removes client from the hub and closes its connection. It
+// reports false if the client was already removed, so callers can treat a
+// second unregister of the same client as a no-op. Placement limits are
+// kept, since the client may reconnect with its identity.
+func (h *Hub) removeClient(client *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client.uuid] != client {
+		return false
+	}
+	delete(h.clients, client.uuid)
+	h.roster.remove(client)
+	delete(h.placements, client.uuid)
+	h.dropIntent(client.uuid)
+	h.forgetIP(client.ip)
+	if h.names[client.Username] == client.uuid {
+		delete(h.names, client.Username)
+	}
+	client.close()
+	return true
+}
+
+// forgetIP counts a client from ip as gone. The caller must hold h.mu.
+func (h *Hub) forgetIP(ip string) {
+	if h.ips[ip]--; h.ips[ip] <= 0 {
+		delete(h.ips, ip)
+	}
+}
+
+// disconnect removes client from the hub and tells the others it left.
+func (h *Hub) disconnect(client *Client) {
+	if !h.removeClient(client) {
+		return
+	}
+	h.pruneLimits(h.clock())
+	clientsConnected.Dec()
+	logger.Info("Client disconnected", "username", client.Username, "uuid", client.uuid, "room", h.name)
+	audit(AuditEvent{Type: "disconnect", Room: h.name, ClientID: client.uuid.String(), Username: client.Username})
+	if client.spectator {
+		return
+	}
+	h.broadcastMessage(PresenceMessage{
+		Type:     "presence",
+		Event:    "leave",
+		ID:       client.publicID,
+		Username: client.Username,
+	}, client.uuid)
+}
+
+// reapIdle disconnects clients that have sent neither a message nor a pong
+// for config.IdleTimeout at now.
+func (h *Hub) reapIdle(now time.Time) {
+	var idle []*Client
+	h.mu.RLock()
+	for _, client := range h.clients {
+		if now.Sub(time.Unix(0, client.lastActivity.Load())) > config.IdleTimeout {
+			idle = append(idle, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range idle {
+		logger.Info("Reaping idle client", "username", client.Username, "uuid", client.uuid)
+		h.disconnect(client)
+	}
+}
+
+// name returns the client's username. Renames happen on the hub's Run
+// loop, so other goroutines must read it under the hub's lock.
+func (c *Client) name() string {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	return c.Username
+}
+
+// resolveSender sets the name of message's sender to its current username
+// if it is a connected client. It is only called from the hub's Run loop.
+func (h *Hub) resolveSender(message Update) Update {
+	if client, ok := h.clients[message.SenderUUID]; ok {
+		message.SenderName = client.Username
+	}
+	return message
+}
+
+// close cancels the client's context. Its Write loop then sends a close
+// frame and closes the socket, which ends its Read loop. It is safe to call
+// more than once.
+func (c *Client) close() {
+	c.cancel()
+}
+
+// publish sends an applied update to the clients, either straight away or as
+// part of the current batch. It reports whether a new batch window started.
+func (h *Hub) publish(message Update, except uuid.UUID) bool {
+	if config.BatchWindow <= 0 {
+		h.broadcastMessage(message, except)
+		return false
+	}
+	return h.batch.add(message)
+}
+
+// flushBatch broadcasts the updates collected in the current batch window.
+func (h *Hub) flushBatch() {
+	updates := h.batch.take()
+	if len(updates) == 0 {
+		return
+	}
+	logger.Debug("Flushing batch", "updates", len(updates))
+	h.broadcastMessage(BatchMessage{
+		Type:    "batch",
+		Updates: updates,
+	}, uuid.Nil)
+}
+
+// broadcastMessage queues message for every client except the one with id
+// except, leaving out updates outside a client's viewport. Clients whose
+// send channel stays full are handled according to config.SendOverflow.
+// It never waits on a client: each client's Write loop drains its own
+// queue, so a slow connection only holds up itself. Clients are served in
+// turns, see roster.
+func (h *Hub) broadcastMessage(message interface{}, except uuid.UUID) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.roster.rotation() {
+		if client.uuid == except {
+			continue
+		}
+		visible, ok := client.visible(message)
+		if !ok {
+			continue
+		}
+		if !h.deliver(client, visible) {
+			logger.Debug("Client send channel blocked, unregistering", "uuid", client.uuid, "overflows", client.overflows)
+			broadcastDrops.Inc()
+			go h.unregisterClient(client)
+		}
+	}
+}
+
+// deliver queues message for client. When Send is full it applies
+// config.SendOverflow and reports false if the client should be
+// disconnected. It is only called from the hub's Run loop.
+func (h *Hub) deliver(client *Client, message interface{}) bool {
+	select {
+	case client.Send <- message:
+		logger.Debug("Sent message to client", "uuid", client.uuid)
+		client.overflows = 0
+		return true
+	default:
+	}
+
+	client.overflows++
+	sendOverflows.Inc()
+	if config.SendOverflow == "drop_oldest" {
+		// The Write loop may drain Send in between, so neither step blocks.
+		select {
+		case <-client.Send:
+		default:
+		}
+		select {
+		case client.Send <- message:
+		default:
 		}
+		return true
+	}
+	return client.overflows < config.SendOverflowLimit
+}
+
+// applyUpdate validates message and writes it to the board. It returns the
+// update with its sequence number assigned, or the reason it was rejected.
+func (h *Hub) applyUpdate(message Update) (Update, error) {
+	if !h.store.InBounds(message.X, message.Y) {
+		return message, errOutOfBounds
+	}
+	if !inPalette(message.Pixel) {
+		return message, errNotInPalette
+	}
+	if h.locked(message.X, message.Y) {
+		return message, errRegionLocked
+	}
+
+	now := h.clock()
+	if isTrusted(message.SenderUUID) {
+		logger.Debug("Trusted user bypasses cooldown", "uuid", message.SenderUUID, "username", message.SenderName)
+	} else if remaining := h.cooldownRemaining(message.SenderUUID, now); remaining > 0 {
+		return message, &cooldownError{remaining: remaining}
+	} else if remaining := h.cellCooldownRemaining(cell{message.X, message.Y}, now); remaining > 0 {
+		return message, &cooldownError{remaining: remaining}
+	} else if err := h.checkPaintLimit(message.SenderUUID, []cell{{message.X, message.Y}}, now); err != nil {
+		return message, err
+	}
+	if consensusEnabled() {
+		if votes := h.vote(message, now); votes < config.ConsensusVotes {
+			// A vote uses up the voter's cooldown, but leaves the cell's.
+			h.recordPlacement(message.SenderUUID, nil, now)
+			return message, &voteError{votes: votes}
+		}
+	}
+
+	previous, owner := h.store.Get(message.X, message.Y)
+	if config.AlphaMode == "blend" {
+		message.Pixel = blendOver(message.Pixel, previous)
+	}
+
+	message.Ts = now
+	meta := PixelMeta{
+		Username: message.SenderName,
+		UUID:     message.SenderUUID,
+		PlacedAt: now,
+		Bot:      isBot(message.SenderUUID),
+	}
+	message, err := h.write(message, meta)
+	if err != nil {
+		return message, err
+	}
+	h.clearVotes(cell{message.X, message.Y})
+
+	// Only connected clients can undo; REST callers never unregister.
+	if _, ok := h.clients[message.SenderUUID]; ok {
+		h.placements[message.SenderUUID] = placement{
+			X:        message.X,
+			Y:        message.Y,
+			PlacedAt: now,
+			Previous: previous,
+			Owner:    owner,
+		}
+	}
+	h.recordPlacement(message.SenderUUID, []cell{{message.X, message.Y}}, now)
+	if !isTrusted(message.SenderUUID) {
+		h.recordPaint(message.SenderUUID, []cell{{message.X, message.Y}}, now)
+	}
+	h.stats.record(now)
+	h.leaderboard.add(message.SenderName)
+	pixelsPlaced.Inc()
+	return message, nil
+}
+
+// write stores message's pixel with meta, shares it with other instances
+// and assigns it a sequence number.
+func (h *Hub) write(message Update, meta PixelMeta) (Update, error) {
+	message.ReqID = ""
+	if err := h.store.Set(message.X, message.Y, message.Pixel, meta); err != nil {
+		logger.Error("Failed to store pixel", "uuid", message.SenderUUID, "error", err)
+		return message, errStoreFailed
+	}
+	if remote, ok := h.store.(remoteStore); ok {
+		if err := remote.Publish(message.X, message.Y, message.Pixel, meta); err != nil {
+			logger.Error("Failed to publish pixel", "uuid", message.SenderUUID, "error", err)
+		}
+	}
+	message = h.changes.append(message)
+	h.record(Event{
+		Seq:      message.Seq,
+		X:        message.X,
+		Y:        message.Y,
+		Pixel:    message.Pixel,
+		Username: message.SenderName,
+		Ts:       message.Ts,
+	})
+	audit(AuditEvent{
+		Type:     "placement",
+		Time:     message.Ts,
+		Room:     h.name,
+		ClientID: message.SenderUUID.String(),
+		Username: message.SenderName,
+		X:        &message.X,
+		Y:        &message.Y,
+		Color:    hexColor(message.Pixel),
+	})
+	return message, nil
+}
+
+func (h *Hub) sendTo(id uuid.UUID, message interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	client, ok := h.clients[id]
+	if !ok {
+		return
+	}
+	select {
+	case client.Send <- message:
+	default:
+		logger.Debug("Client send channel blocked, dropping message", "uuid", id)
+	}
+}
+
+// unregisterClient hands c to the Run loop, giving up if the hub has stopped.
+func (h *Hub) unregisterClient(c *Client) {
+	select {
+	case h.unregister <- c:
+	case <-h.done:
 	}
 }
 
 func (c *Client) Read() {
 	defer func() {
-		log.Printf("DEBUG: Exiting Read loop for client %s", c.uuid)
-		HubInstance.unregister <- c
+		logger.Debug("Exiting Read loop", "uuid", c.uuid)
+		c.hub.unregisterClient(c)
 		c.Socket.Close()
 	}()
 
-	log.Printf("DEBUG: Starting Read loop for client %s", c.uuid)
+	logger.Debug("Starting Read loop", "uuid", c.uuid)
 
-	c.Socket.SetReadLimit(maxMessageSize)
-	c.Socket.SetReadDeadline(time.Now().Add(pongWait))
+	c.Socket.SetReadLimit(int64(config.MaxMessageSize))
+	c.Socket.SetReadDeadline(time.Now().Add(config.PongWait))
 	c.Socket.SetPongHandler(func(string) error {
-		log.Printf("DEBUG: Received pong from client %s", c.uuid)
-		c.Socket.SetReadDeadline(time.Now().Add(pongWait))
+		logger.Debug("Received pong", "uuid", c.uuid)
+		c.lastActivity.Store(c.hub.clock().UnixNano())
+		c.Socket.SetReadDeadline(time.Now().Add(config.PongWait))
 		return nil
 	})
 
+	limiter := newMessageLimiter(time.Now())
 	for {
-		log.Printf("DEBUG: Waiting for next message from client %s", c.uuid)
-		var msg Update
-		err := c.Socket.ReadJSON(&msg)
+		logger.Debug("Waiting for next message", "uuid", c.uuid)
+		_, data, err := c.Socket.ReadMessage()
 		if err != nil {
+			// The websocket library has already sent a 1009 close frame.
+			if errors.Is(err, websocket.ErrReadLimit) {
+				logger.Warn("Client message exceeded size limit, closing", "uuid", c.uuid, "username", c.name(), "limit", config.MaxMessageSize)
+				messagesTooLarge.Inc()
+				break
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("Client ReadPump Error (%s): %v", c.uuid, err)
+				logger.Error("Client ReadPump error", "uuid", c.uuid, "error", err)
 			} else {
-				log.Printf("Client ReadPump: Normal closure or read error for %s: %v", c.uuid, err)
+				logger.Info("Client ReadPump: normal closure or read error", "uuid", c.uuid, "error", err)
 			}
 			break
 		}
-		log.Printf("DEBUG: Received message from client %s", c.uuid)
+		var msg Update
+		if err := json.Unmarshal(data, &msg); err != nil {
+			logger.Info("Client sent invalid message", "uuid", c.uuid, "error", err)
+			break
+		}
+		logger.Debug("Received message", "uuid", c.uuid, "type", msg.Type)
+		countReceived(msg.Type)
+		c.lastActivity.Store(c.hub.clock().UnixNano())
+		if msg.Type == "cursor" {
+			// Cursors have their own throttle, so moving the mouse does not
+			// use up the message rate.
+			if c.spectator || !c.cursorAllowed(time.Now()) {
+				continue
+			}
+			if !c.hub.do(func() { c.hub.moveCursor(c, msg.X, msg.Y) }) {
+				return
+			}
+			continue
+		}
+		if !limiter.allow(time.Now()) {
+			messagesThrottled.Inc()
+			if limiter.abusive() {
+				logger.Warn("Disconnecting client flooding messages", "uuid", c.uuid, "username", c.name())
+				c.Socket.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "message rate exceeded"),
+					time.Now().Add(config.WriteWait))
+				return
+			}
+			if limiter.throttled == 1 {
+				logger.Warn("Client exceeded message rate, dropping messages", "uuid", c.uuid, "username", c.name())
+			}
+			continue
+		}
+		switch msg.Type {
+		case "subscribe":
+			var sub SubscribeMessage
+			if err := json.Unmarshal(data, &sub); err != nil {
+				logger.Info("Client sent invalid message", "uuid", c.uuid, "error", err)
+				return
+			}
+			if !c.hub.do(func() { c.hub.subscribe(c, sub.Region) }) {
+				return
+			}
+			continue
+		case "resync":
+			if !c.hub.do(func() { c.hub.resync(c, msg.Seq) }) {
+				return
+			}
+			continue
+		case "set_name":
+			if c.spectator {
+				continue
+			}
+			// The name arrives in the username field, which msg.SenderName
+			// was decoded from.
+			name := msg.SenderName
+			if !c.hub.do(func() {
+				if err := c.hub.rename(c, name); err != nil {
+					c.hub.sendTo(c.uuid, newErrorMessage(err))
+				}
+			}) {
+				return
+			}
+			continue
+		}
+		if c.spectator {
+			logger.Debug("Ignoring update from spectator", "uuid", c.uuid)
+			continue
+		}
+		// The name is filled in on the hub's Run loop, where renames happen.
 		msg.SenderUUID = c.uuid
-		msg.Type = "update"
+		msg.SenderName = ""
+		if c.readOnly {
+			if !c.hub.do(func() { c.hub.reject(msg, errReadOnly) }) {
+				return
+			}
+			continue
+		}
+		if err := checkFields(data, msg.Type); err != nil {
+			if !c.hub.do(func() { c.hub.sendTo(c.uuid, nack(newErrorMessage(err), msg.ReqID)) }) {
+				return
+			}
+			continue
+		}
+		// Clients may send the color as a hex string instead of a pixel; v2
+		// clients must.
+		var color struct {
+			Color *string `json:"color"`
+		}
+		json.Unmarshal(data, &color)
+		if color.Color == nil && c.protocol >= 2 && msg.Type != "undo" {
+			if !c.hub.do(func() { c.hub.reject(msg, errColorRequired) }) {
+				return
+			}
+			continue
+		}
+		if color.Color != nil {
+			pixel, err := parseHexColor(*color.Color)
+			if err != nil {
+				if !c.hub.do(func() { c.hub.reject(msg, err) }) {
+					return
+				}
+				continue
+			}
+			msg.Pixel = pixel
+		}
+		switch msg.Type {
+		case "undo", "fill":
+		case "draw_rect", "draw_line":
+			var shape ShapeMessage
+			json.Unmarshal(data, &shape)
+			cells, err := shape.cells()
+			if err != nil {
+				if !c.hub.do(func() { c.hub.reject(msg, err) }) {
+					return
+				}
+				continue
+			}
+			msg.cells = cells
+		default:
+			msg.Type = "update"
+		}
 
-		HubInstance.broadcast <- msg
+		if err := c.hub.enqueue(c.ctx, msg); errors.Is(err, errServerBusy) {
+			// Not through the Run loop, which is what is backed up.
+			select {
+			case c.Send <- nack(newErrorMessage(err), msg.ReqID):
+			default:
+			}
+		} else if err != nil {
+			return
+		}
+	}
+}
+
+// enqueue hands message to the hub's Run loop. Once config.BroadcastBuffer
+// messages are waiting, it blocks until there is room or ctx is done, or
+// with config.BroadcastOverflow "drop" fails with errServerBusy.
+func (h *Hub) enqueue(ctx context.Context, message Update) error {
+	if config.BroadcastOverflow == "drop" {
+		select {
+		case h.broadcast <- message:
+			return nil
+		case <-h.done:
+			return errHubStopped
+		default:
+			updatesDropped.Inc()
+			return errServerBusy
+		}
+	}
+	select {
+	case h.broadcast <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-h.done:
+		return errHubStopped
 	}
 }
 
 func (c *Client) Write() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(config.PingPeriod)
 	defer func() {
-		log.Printf("DEBUG: Exiting Write loop for client %s", c.uuid)
+		logger.Debug("Exiting Write loop", "uuid", c.uuid)
 		ticker.Stop()
 		c.Socket.Close()
+		c.hub.writers.Done()
 	}()
 
-	log.Printf("DEBUG: Starting Write loop for client %s", c.uuid)
+	logger.Debug("Starting Write loop", "uuid", c.uuid)
 
 	for {
 		select {
-		case message, ok := <-c.Send:
-			log.Printf("DEBUG: Write loop - message received for client %s", c.uuid)
-			c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				log.Printf("Client WritePump: Hub closed send channel for %s", c.uuid)
-				c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-c.ctx.Done():
+			logger.Info("Client WritePump: client closed by hub", "uuid", c.uuid)
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			if c.closeErr != nil {
+				closeMessage = websocket.FormatCloseMessage(closeCode(c.closeErr), c.closeErr.Error())
+			} else if c.hub.closing.Load() {
+				closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
 			}
-			log.Printf("DEBUG: Write message from %s: %+v", message.SenderUUID, message)
-			err := c.Socket.WriteJSON(message)
+			c.Socket.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			c.Socket.WriteMessage(websocket.CloseMessage, closeMessage)
+			return
+		case message := <-c.Send:
+			logger.Debug("Write loop message received", "uuid", c.uuid)
+			c.Socket.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			logger.Debug("Writing message", "uuid", c.uuid, "message", message)
+			err := c.write(message)
 			if err != nil {
-				log.Printf("Client WritePump Error (%s): %v", c.uuid, err)
+				logger.Error("Client WritePump error", "uuid", c.uuid, "error", err)
 				return
 			}
 		case <-ticker.C:
-			log.Printf("DEBUG: Sending ping to client %s", c.uuid)
-			c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			logger.Debug("Sending ping", "uuid", c.uuid)
+			c.Socket.SetWriteDeadline(time.Now().Add(config.WriteWait))
 			if err := c.Socket.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Printf("Client WritePump: Ping Error (%s): %v", c.uuid, err)
+				logger.Error("Client WritePump ping error", "uuid", c.uuid, "error", err)
 				return
 			}
 		}
@@ -127,34 +783,180 @@ func (c *Client) Write() {
 
 func InitWebSocket() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Printf("DEBUG: Upgrading connection to WebSocket")
-		username := c.Query("username")
-		if username == "" {
-			username = "anonymous"
+		logger.Debug("Upgrading connection to WebSocket")
+		hub, ok := roomFromQuery(c)
+		if !ok {
+			return
+		}
+		if hub.closing.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
 		}
-		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errDraining.Error()})
+			return
+		}
+		// Checked again when registering, since other clients may connect
+		// in between.
+		if config.MaxClients > 0 && totalClients() >= config.MaxClients {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": errServerFull.Error()})
+			return
+		}
+		if config.MaxConnectionsPerIP > 0 && ipConnections(c.ClientIP()) >= config.MaxConnectionsPerIP {
+			logger.Info("Rejected websocket upgrade", "reason", errTooManyConns, "ip", c.ClientIP())
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": errTooManyConns.Error()})
+			return
+		}
+		adm, err := admitUpgrade(c.Request)
 		if err != nil {
-			log.Println("Websocket upgrade error:", err)
+			var rejection *upgradeError
+			errors.As(err, &rejection)
+			logger.Info("Rejected websocket upgrade", "reason", rejection.reason, "ip", c.ClientIP())
+			c.JSON(rejection.status, gin.H{"error": rejection.reason})
+			return
+		}
+		since, err := strconv.ParseUint(c.Query("since"), 10, 64)
+		hasSince := err == nil
+		mode := c.DefaultQuery("mode", "player")
+		if mode != "player" && mode != "spectator" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be player or spectator"})
+			return
+		}
+		queue, err := strconv.ParseBool(c.DefaultQuery("queue", "false"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "queue must be true or false"})
+			return
+		}
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "binary" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or binary"})
 			return
 		}
+		protocol, err := protocolVersion(c.Request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "supported subprotocols are " + protocolV2 + " and " + protocolV1})
+			return
+		}
+		var header http.Header
+		identity, expires, err := issueIdentity(adm.id, adm.username, time.Now())
+		if err != nil {
+			logger.Error("Failed to issue identity token", "error", err)
+		} else {
+			header = identityCookieHeader(c.Request, identity, expires)
+		}
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, header)
+		if err != nil {
+			logger.Error("Websocket upgrade error", "error", err)
+			upgradeFailures.Inc()
+			return
+		}
+		// Only takes effect if the client negotiated permessage-deflate.
+		conn.EnableWriteCompression(config.Compression)
+		if config.Compression {
+			if err := conn.SetCompressionLevel(config.CompressionLevel); err != nil {
+				logger.Warn("Invalid compression level", "level", config.CompressionLevel, "error", err)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
 		client := &Client{
-			uuid:     uuid.New(),
-			Socket:   conn,
-			Send:     make(chan Update, 256),
-			Username: username,
+			hub:         hub,
+			uuid:        adm.id,
+			Socket:      conn,
+			Send:        make(chan interface{}, config.SendBuffer),
+			Username:    adm.username,
+			protocol:    protocol,
+			readOnly:    adm.readOnly || mode == "spectator",
+			spectator:   mode == "spectator",
+			queue:       queue,
+			publicID:    uuid.NewString(),
+			ip:          c.ClientIP(),
+			connectedAt: time.Now(),
+			registered:  make(chan error, 1),
+			ctx:         ctx,
+			cancel:      cancel,
+		}
+		client.lastActivity.Store(hub.clock().UnixNano())
+		logger.Debug("New client created", "username", client.Username, "uuid", client.uuid)
+		// Register before taking the snapshot so that no update applied in
+		// between is lost; anything broadcast meanwhile waits in client.Send.
+		select {
+		case hub.register <- client:
+		case <-hub.done:
+			cancel()
+			conn.Close()
+			return
+		}
+		if err := <-client.registered; err != nil {
+			cancel()
+			conn.WriteJSON(newErrorMessage(err))
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(closeCode(err), err.Error()),
+				time.Now().Add(config.WriteWait))
+			conn.Close()
+			return
 		}
-		HubInstance.clients[client.uuid] = client
-		log.Printf("DEBUG: New client created: %s (%s)", client.Username, client.uuid)
 
-		board.mu.RLock()
-		boardState := InitBoardState{
-			Type:   "init",
-			Pixels: board.Pixels,
+		audit(AuditEvent{
+			Type:     "connect",
+			Room:     hub.name,
+			ClientID: client.uuid.String(),
+			Username: client.Username,
+			IP:       c.ClientIP(),
+		})
+
+		// initial holds the messages that bring the client up to date.
+		var initial []interface{}
+		if hasSince {
+			if updates, seq, ok := hub.changes.since(since); ok {
+				logger.Debug("Sending board delta", "uuid", client.uuid, "since", since, "updates", len(updates))
+				initial = []interface{}{DeltaMessage{
+					Type:    "delta",
+					Seq:     seq,
+					Updates: updates,
+				}}
+			} else {
+				logger.Debug("Requested sequence too old, sending full board", "uuid", client.uuid, "since", since)
+			}
+		}
+		if initial == nil {
+			// Read the sequence before the snapshot so the reported seq never
+			// claims more than the pixels contain.
+			seq := hub.changes.seq()
+			switch {
+			case format == "binary":
+				initial = []interface{}{encodeBinaryBoard(seq, hub.store.Snapshot())}
+			case config.InitChunkRows > 0:
+				initial = initChunks(seq, hub.store.Snapshot(), config.InitChunkRows)
+			default:
+				initial = []interface{}{InitBoardState{
+					Type:   "init",
+					Seq:    seq,
+					Pixels: hub.store.Snapshot(),
+				}}
+			}
 		}
-		board.mu.RUnlock()
 
-		log.Printf("DEBUG: Sending initial board state to client %s", client.uuid)
-		client.Socket.WriteJSON(boardState)
+		if frozen.Load() {
+			initial = append(initial, FrozenMessage{Type: "frozen", Value: true})
+		}
+		if scheduled() {
+			initial = append(initial, canvasMessage(hub.clock()))
+		}
+		if header != nil {
+			initial = append([]interface{}{IdentityMessage{
+				Type:      "identity",
+				Token:     identity,
+				ExpiresAt: expires,
+			}}, initial...)
+		}
+
+		logger.Debug("Sending initial board state", "uuid", client.uuid)
+		for _, message := range initial {
+			if err := client.write(message); err != nil {
+				logger.Debug("Failed to send initial board state", "uuid", client.uuid, "error", err)
+				break
+			}
+		}
 
 		go client.Read()
 		go client.Write()
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestInitReflectsPlacedPixel(t *testing.T) {
+	srv := startServer(t, nil)
+
+	first := dial(t, srv, "username=first")
+	place(t, first, 3, 4, "#ff4500")
+
+	second := dial(t, srv, "username=second")
+	var init InitBoardState
+	readType(t, second, "init", &init)
+	if got, want := init.Pixels[4][3], (Pixel{R: 0xff, G: 0x45, A: 255}); got != want {
+		t.Fatalf("init has %v at (3, 4), want %v", got, want)
+	}
+	if got := init.Pixels[0][0]; got != config.FillColor {
+		t.Fatalf("init has %v at (0, 0), want the fill color", got)
+	}
+}
+
+func TestBroadcastReachesOtherClients(t *testing.T) {
+	srv := startServer(t, nil)
+	first := dial(t, srv, "username=first")
+	second := dial(t, srv, "username=second")
+	// Registration is done once the init state has arrived.
+	readType(t, second, "init", &InitBoardState{})
+
+	place(t, first, 1, 2, "#2450a4")
+
+	var update Update
+	readType(t, second, "update", &update)
+	if update.X != 1 || update.Y != 2 || update.Pixel != (Pixel{R: 0x24, G: 0x50, B: 0xa4, A: 255}) {
+		t.Fatalf("second client got %+v", update)
+	}
+	if update.SenderName != "first" {
+		t.Fatalf("update is from %q, want first", update.SenderName)
+	}
+}
+
+// Run with -race: registration must not touch the clients map outside the
+// hub's Run loop.
+func TestConcurrentConnections(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.MaxConnectionsPerIP = 0 })
+
+	const clients = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=user" + strconv.Itoa(i)
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(readTimeout))
+			// The init state comes before anything the hub broadcasts,
+			// following only the client's identity.
+			var init InitBoardState
+			err = conn.ReadJSON(&init)
+			if err == nil && init.Type == "identity" {
+				err = conn.ReadJSON(&init)
+			}
+			if err != nil || init.Type != "init" {
+				errs <- fmt.Errorf("got %q before init: %v", init.Type, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPresenceEvents(t *testing.T) {
+	srv := startServer(t, nil)
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+
+	visitor := dial(t, srv, "username=visitor")
+	var join PresenceMessage
+	readType(t, watcher, "presence", &join)
+	if join.Event != "join" || join.Username != "visitor" || join.ID == "" {
+		t.Fatalf("got %+v, want visitor joining", join)
+	}
+
+	visitor.Close()
+	var leave PresenceMessage
+	readType(t, watcher, "presence", &leave)
+	if leave.Event != "leave" || leave.Username != "visitor" || leave.ID != join.ID {
+		t.Fatalf("got %+v, want visitor leaving", leave)
+	}
+}
+
+// clientNamed returns the connected client of h with the given username.
+func clientNamed(t *testing.T, h *Hub, username string) *Client {
+	t.Helper()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, client := range h.clients {
+		if client.Username == username {
+			return client
+		}
+	}
+	t.Fatalf("no client named %s", username)
+	return nil
+}
+
+// Run with -race: a client can be unregistered by its Read loop and by a
+// broadcast that finds its queue full at the same time.
+func TestDuplicateUnregister(t *testing.T) {
+	srv := startServer(t, nil)
+	watcher := dial(t, srv, "username=watcher")
+	readType(t, watcher, "init", &InitBoardState{})
+	victim := dial(t, srv, "username=victim")
+	readType(t, victim, "init", &InitBoardState{})
+	readType(t, watcher, "presence", &PresenceMessage{})
+	client := clientNamed(t, HubInstance, "victim")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			HubInstance.unregisterClient(client)
+		}()
+	}
+	victim.Close()
+	wg.Wait()
+
+	var leave PresenceMessage
+	readType(t, watcher, "presence", &leave)
+	if leave.Event != "leave" {
+		t.Fatalf("got %+v, want victim leaving", leave)
+	}
+	// The hub is still running, and announced the leave only once.
+	place(t, watcher, 0, 0, "#000000")
+	watcher.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		var message PresenceMessage
+		if err := watcher.ReadJSON(&message); err != nil {
+			break
+		}
+		if message.Type == "presence" {
+			t.Fatalf("got a second presence event %+v", message)
+		}
+	}
+}
+
+func TestPingsKeepIdleClientConnected(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) {
+		cfg.PingPeriod = 20 * time.Millisecond
+		cfg.PongWait = 100 * time.Millisecond
+	})
+	conn := dial(t, srv, "username=idle")
+	pings := make(chan struct{}, 100)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	// Reading is what answers pings; the client sends nothing itself.
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-closed:
+		t.Fatalf("idle client was disconnected: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if len(pings) < 3 {
+		t.Fatalf("got %d pings in 500ms, want one every 20ms", len(pings))
+	}
+	clientNamed(t, HubInstance, "idle")
+}
+
+func TestUpdatesAreSequenced(t *testing.T) {
+	srv := startServer(t, nil)
+	painter := dial(t, srv, "username=painter")
+	watcher := dial(t, srv, "username=watcher")
+	var init InitBoardState
+	readType(t, watcher, "init", &init)
+
+	for x := range 5 {
+		place(t, painter, x, 0, "#000000")
+	}
+	last, lastTs := init.Seq, time.Time{}
+	for range 5 {
+		var update Update
+		readType(t, watcher, "update", &update)
+		if update.Seq <= last {
+			t.Fatalf("update at (%d, 0) has seq %d after %d", update.X, update.Seq, last)
+		}
+		if update.Ts.IsZero() || update.Ts.Before(lastTs) {
+			t.Fatalf("update at (%d, 0) has timestamp %v after %v", update.X, update.Ts, lastTs)
+		}
+		last, lastTs = update.Seq, update.Ts
+	}
+}
+
+func TestReapIdleClients(t *testing.T) {
+	useConfig(t, func(cfg *Config) { cfg.IdleTimeout = time.Minute })
+	h := newTestHub(8, 8)
+	clock := useFakeClock(h)
+	stale := addTestClient(t, h, "stale", 16)
+	active := addTestClient(t, h, "active", 16)
+	stale.lastActivity.Store(clock.Now().UnixNano())
+	active.lastActivity.Store(clock.Now().UnixNano())
+
+	clock.advance(50 * time.Second)
+	active.lastActivity.Store(clock.Now().UnixNano())
+	h.reapIdle(clock.Now())
+	if len(h.clients) != 2 {
+		t.Fatalf("%d clients left before the idle timeout, want 2", len(h.clients))
+	}
+
+	clock.advance(30 * time.Second)
+	h.reapIdle(clock.Now())
+	if _, ok := h.clients[stale.uuid]; ok {
+		t.Fatal("stale client was not reaped")
+	}
+	if _, ok := h.clients[active.uuid]; !ok {
+		t.Fatal("active client was reaped")
+	}
+}
+
+func TestSendBufferSize(t *testing.T) {
+	srv := startServer(t, func(cfg *Config) { cfg.SendBuffer = 7 })
+	readType(t, dial(t, srv, "username=a"), "init", &InitBoardState{})
+	if got := cap(clientNamed(t, HubInstance, "a").Send); got != 7 {
+		t.Fatalf("send buffer holds %d messages, want 7", got)
+	}
+}
+
+func TestSendBufferFromEnv(t *testing.T) {
+	t.Setenv("RPLACE_SEND_BUFFER", "1024")
+	if got := LoadConfig().SendBuffer; got != 1024 {
+		t.Fatalf("SendBuffer is %d, want 1024", got)
+	}
+	t.Setenv("RPLACE_SEND_BUFFER", "0")
+	if got := LoadConfig().SendBuffer; got != defaultSendBuffer {
+		t.Fatalf("SendBuffer is %d with an invalid value, want the default %d", got, defaultSendBuffer)
+	}
+}
+
+func TestUpgraderBufferSizes(t *testing.T) {
+	useConfig(t, nil)
+	if upgrader.ReadBufferSize != defaultBufferSize || upgrader.WriteBufferSize != defaultBufferSize {
+		t.Fatalf("default buffers are %d/%d, want %d", upgrader.ReadBufferSize, upgrader.WriteBufferSize, defaultBufferSize)
+	}
+	if upgrader.WriteBufferPool != nil {
+		t.Fatal("write buffers are pooled by default")
+	}
+
+	t.Setenv("RPLACE_READ_BUFFER_SIZE", "1024")
+	t.Setenv("RPLACE_WRITE_BUFFER_SIZE", "65536")
+	t.Setenv("RPLACE_SHARED_WRITE_BUFFERS", "true")
+	cfg := LoadConfig()
+	if cfg.ReadBufferSize != 1024 || cfg.WriteBufferSize != 65536 || !cfg.SharedWriteBuffers {
+		t.Fatalf("read %d/%d shared=%v from the environment", cfg.ReadBufferSize, cfg.WriteBufferSize, cfg.SharedWriteBuffers)
+	}
+
+	srv := startServer(t, func(cfg *Config) {
+		cfg.ReadBufferSize = 1024
+		cfg.WriteBufferSize = 65536
+		cfg.SharedWriteBuffers = true
+	})
+	if upgrader.ReadBufferSize != 1024 || upgrader.WriteBufferSize != 65536 {
+		t.Fatalf("upgrader buffers are %d/%d, want 1024/65536", upgrader.ReadBufferSize, upgrader.WriteBufferSize)
+	}
+	if upgrader.WriteBufferPool != writeBufferPool {
+		t.Fatal("upgrader does not use the shared write buffer pool")
+	}
+	// Connections still work with pooled buffers.
+	conn := dial(t, srv, "username=a")
+	readType(t, conn, "init", &InitBoardState{})
+	place(t, conn, 0, 0, "#000000")
+}
+
+// Run with -race: cancelling a client's context, unregistering it and
+// broadcasting to it may all happen at once.
+func TestConcurrentCancelAndUnregister(t *testing.T) {
+	srv := startServer(t, nil)
+	painter := dial(t, srv, "username=painter")
+	victim := dial(t, srv, "username=victim")
+	readType(t, victim, "init", &InitBoardState{})
+	client := clientNamed(t, HubInstance, "victim")
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				client.close()
+			} else {
+				HubInstance.unregisterClient(client)
+			}
+		}()
+	}
+	for x := range 5 {
+		place(t, painter, x, 0, "#000000")
+	}
+	wg.Wait()
+
+	select {
+	case <-client.ctx.Done():
+	case <-time.After(readTimeout):
+		t.Fatal("client context was never cancelled")
+	}
+	if code := readClose(t, victim); code != websocket.CloseNormalClosure {
+		t.Fatalf("got close code %d, want %d", code, websocket.CloseNormalClosure)
+	}
+	// The hub is still running, and has let go of the client.
+	place(t, painter, 0, 1, "#000000")
+	HubInstance.mu.RLock()
+	_, ok := HubInstance.clients[client.uuid]
+	HubInstance.mu.RUnlock()
+	if ok {
+		t.Fatal("client is still registered")
+	}
+}
+
+func TestOversizedMessageClosesConnection(t *testing.T) {
+	t.Setenv("RPLACE_MAX_MESSAGE_SIZE", "128")
+	if size := LoadConfig().MaxMessageSize; size != 128 {
+		t.Fatalf("RPLACE_MAX_MESSAGE_SIZE read as %d", size)
+	}
+	logs := captureLogs(t)
+	srv := startServer(t, func(cfg *Config) {
+		cfg.LogLevel = slog.LevelWarn
+		cfg.MaxMessageSize = 128
+	})
+	conn := dial(t, srv, "username=big")
+	readType(t, conn, "init", &InitBoardState{})
+
+	// Messages up to the limit are fine.
+	place(t, conn, 0, 0, "#000000")
+	send(t, conn, map[string]any{"type": "update", "x": 1, "y": 0, "color": strings.Repeat("f", 128)})
+	if code := readClose(t, conn); code != websocket.CloseMessageTooBig {
+		t.Fatalf("got close code %d, want %d", code, websocket.CloseMessageTooBig)
+	}
+	if !strings.Contains(logs.String(), "Client message exceeded size limit") {
+		t.Fatalf("closing was not logged; logs:\n%s", logs)
+	}
+}